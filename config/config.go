@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
 	"github.com/joho/godotenv"
 )
 
@@ -99,6 +100,68 @@ func LoadConfig(path string) IConfig {
 				return t
 			}(),
 		},
+		shop: &shop{
+			shippingFee: func() riMoney.Money {
+				if envMap["SHOP_SHIPPING_FEE"] == "" {
+					return riMoney.Zero
+				}
+				m, err := riMoney.Parse(envMap["SHOP_SHIPPING_FEE"])
+				if err != nil {
+					log.Fatalf("load shipping fee failed: %v", err)
+				}
+				return m
+			}(),
+			freeShippingMin: func() riMoney.Money {
+				if envMap["SHOP_FREE_SHIPPING_MIN"] == "" {
+					return riMoney.Zero
+				}
+				m, err := riMoney.Parse(envMap["SHOP_FREE_SHIPPING_MIN"])
+				if err != nil {
+					log.Fatalf("load free shipping min failed: %v", err)
+				}
+				return m
+			}(),
+			taxRate: func() float64 {
+				if envMap["SHOP_TAX_RATE"] == "" {
+					return 7
+				}
+				f, err := strconv.ParseFloat(envMap["SHOP_TAX_RATE"], 64)
+				if err != nil {
+					log.Fatalf("load tax rate failed: %v", err)
+				}
+				return f
+			}(),
+			vatInclusive: func() bool {
+				if envMap["SHOP_VAT_INCLUSIVE"] == "" {
+					return false
+				}
+				b, err := strconv.ParseBool(envMap["SHOP_VAT_INCLUSIVE"])
+				if err != nil {
+					log.Fatalf("load vat inclusive failed: %v", err)
+				}
+				return b
+			}(),
+			// duration such as 30m or 24h, a plain number is minutes
+			unpaidOrderTtl: func() time.Duration {
+				if envMap["SHOP_UNPAID_ORDER_TTL"] == "" {
+					return 24 * time.Hour
+				}
+				if t, err := strconv.Atoi(envMap["SHOP_UNPAID_ORDER_TTL"]); err == nil {
+					return time.Duration(t) * time.Minute
+				}
+				t, err := time.ParseDuration(envMap["SHOP_UNPAID_ORDER_TTL"])
+				if err != nil {
+					log.Fatalf("load unpaid order ttl failed: %v", err)
+				}
+				return t
+			}(),
+			promptPayId: envMap["SHOP_PROMPTPAY_ID"],
+			name:        envMap["SHOP_NAME"],
+			address:     envMap["SHOP_ADDRESS"],
+			phone:       envMap["SHOP_PHONE"],
+			taxId:       envMap["SHOP_TAX_ID"],
+			invoiceFont: envMap["SHOP_INVOICE_FONT"],
+		},
 	}
 }
 
@@ -106,12 +169,14 @@ type IConfig interface {
 	App() IAppConfig
 	Db() IDbConfig
 	Jwt() IJwtConfig
+	Shop() IShopConfig
 }
 
 type config struct {
-	app *app
-	db  *db
-	jwt *jwt
+	app  *app
+	db   *db
+	jwt  *jwt
+	shop *shop
 }
 
 type IAppConfig interface {
@@ -212,4 +277,47 @@ func (j *jwt) ApiKey() []byte             { return []byte(j.apiKey) }
 func (j *jwt) AccessExpiresAt() int       { return j.accessExpiresAt }
 func (j *jwt) RefreshExpiresAt() int      { return j.refreshExpiresAt }
 func (j *jwt) SetJwtAccessExpires(t int)  { j.accessExpiresAt = t }
-func (j *jwt) SetJwtRefreshExpires(t int) { j.refreshExpiresAt = t }
\ No newline at end of file
+func (j *jwt) SetJwtRefreshExpires(t int) { j.refreshExpiresAt = t }
+
+type IShopConfig interface {
+	ShippingFee() riMoney.Money
+	FreeShippingMin() riMoney.Money
+	TaxRate() float64
+	VatInclusive() bool
+	UnpaidOrderTtl() time.Duration
+	PromptPayId() string
+	Name() string
+	Address() string
+	Phone() string
+	TaxId() string
+	InvoiceFont() string
+}
+
+type shop struct {
+	shippingFee     riMoney.Money
+	freeShippingMin riMoney.Money //0 = never free
+	taxRate         float64       //percent of VAT
+	vatInclusive    bool          //true = product prices already include VAT
+	unpaidOrderTtl  time.Duration //waiting order without transfer slip is canceled after this, 0 = never, empty = 24h
+	promptPayId     string        //mobile number or tax id
+	name            string
+	address         string
+	phone           string
+	taxId           string
+	invoiceFont     string //path of .ttf font, required for thai text in invoice
+}
+
+func (c *config) Shop() IShopConfig {
+	return c.shop
+}
+func (s *shop) ShippingFee() riMoney.Money     { return s.shippingFee }
+func (s *shop) FreeShippingMin() riMoney.Money { return s.freeShippingMin }
+func (s *shop) TaxRate() float64               { return s.taxRate }
+func (s *shop) VatInclusive() bool             { return s.vatInclusive }
+func (s *shop) UnpaidOrderTtl() time.Duration  { return s.unpaidOrderTtl }
+func (s *shop) PromptPayId() string            { return s.promptPayId }
+func (s *shop) Name() string                   { return s.name }
+func (s *shop) Address() string                { return s.address }
+func (s *shop) Phone() string                  { return s.phone }
+func (s *shop) TaxId() string                  { return s.taxId }
+func (s *shop) InvoiceFont() string            { return s.invoiceFont }
@@ -0,0 +1,58 @@
+package carts
+
+import (
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/modules/products"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+type Cart struct {
+	Id       string        `json:"id" db:"id"`
+	UserId   string        `json:"user_id" db:"user_id"`
+	Items    []*CartItem   `json:"items"`
+	Subtotal riMoney.Money `json:"subtotal"` // available items only
+}
+
+type CartItem struct {
+	ProductId   string             `json:"product_id" db:"product_id"`
+	VariantId   *string            `json:"variant_id" db:"variant_id"`
+	Qty         int                `json:"qty" db:"qty"`
+	Stock       int                `json:"stock" db:"stock"`
+	IsAvailable bool               `json:"is_available"` // stock is enough for qty
+	Product     *products.Products `json:"product"`
+	Total       riMoney.Money      `json:"total"`
+}
+
+type CartItemReq struct {
+	ProductId string  `json:"product_id" form:"product_id"`
+	VariantId *string `json:"variant_id" form:"variant_id"` // required when the product has variants
+	Qty       int     `json:"qty" form:"qty"`
+}
+
+// CheckVariant trims variant_id, an empty variant_id is the product without variant
+func (r *CartItemReq) CheckVariant() {
+	if r.VariantId == nil {
+		return
+	}
+	variantId := strings.Trim(*r.VariantId, " ")
+	if variantId == "" {
+		r.VariantId = nil
+		return
+	}
+	r.VariantId = &variantId
+}
+
+type CartMergeReq struct {
+	Items []*CartItemReq `json:"items"`
+}
+
+type CartCheckoutReq struct {
+	AddressId   string `json:"address_id" form:"address_id"`
+	Address     string `json:"address" form:"address"`
+	Contact     string `json:"contact" form:"contact"`
+	CouponCode  string `json:"coupon_code" form:"coupon_code"`
+	BuyerTaxId  string `json:"buyer_tax_id" form:"buyer_tax_id"`
+	BuyerBranch string `json:"buyer_branch" form:"buyer_branch"`
+	Currency    string `json:"currency" form:"currency"` // prices of the order are also kept in this currency
+}
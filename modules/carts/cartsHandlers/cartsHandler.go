@@ -0,0 +1,230 @@
+package cartsHandlers
+
+import (
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/carts"
+	"github.com/NatthawutSK/ri-shop/modules/carts/cartsUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	"github.com/gofiber/fiber/v2"
+)
+
+type cartsHandlerErrCode string
+
+const (
+	findCartErr     cartsHandlerErrCode = "carts-001"
+	addCartItemErr  cartsHandlerErrCode = "carts-002"
+	updateCartErr   cartsHandlerErrCode = "carts-003"
+	deleteCartErr   cartsHandlerErrCode = "carts-004"
+	mergeCartErr    cartsHandlerErrCode = "carts-005"
+	checkoutCartErr cartsHandlerErrCode = "carts-006"
+)
+
+type ICartsHandler interface {
+	FindCart(c *fiber.Ctx) error
+	AddItem(c *fiber.Ctx) error
+	UpdateItem(c *fiber.Ctx) error
+	DeleteItem(c *fiber.Ctx) error
+	MergeCart(c *fiber.Ctx) error
+	Checkout(c *fiber.Ctx) error
+}
+
+type cartsHandler struct {
+	cfg          config.IConfig
+	cartsUsecase cartsUsecases.ICartsUsecase
+}
+
+func CartsHandler(cartsUsecase cartsUsecases.ICartsUsecase, cfg config.IConfig) ICartsHandler {
+	return &cartsHandler{
+		cartsUsecase: cartsUsecase,
+		cfg:          cfg,
+	}
+}
+
+func (h *cartsHandler) FindCart(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+
+	cart, err := h.cartsUsecase.FindCart(userId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findCartErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, cart).Res()
+}
+
+func (h *cartsHandler) AddItem(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+
+	req := &carts.CartItemReq{
+		Qty: 1,
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addCartItemErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.ProductId = strings.Trim(req.ProductId, " ")
+	if req.ProductId == "" || req.Qty < 1 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addCartItemErr),
+			"product_id is required and qty must be more than 0",
+		).Res()
+	}
+
+	cart, err := h.cartsUsecase.AddItem(userId, req)
+	if err != nil {
+		if orders.IsInvalid(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(addCartItemErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(addCartItemErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, cart).Res()
+}
+
+func (h *cartsHandler) UpdateItem(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+
+	req := new(carts.CartItemReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCartErr),
+			err.Error(),
+		).Res()
+	}
+	req.ProductId = strings.Trim(c.Params("productId"), " ")
+
+	if req.Qty < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCartErr),
+			"qty must not be negative",
+		).Res()
+	}
+
+	cart, err := h.cartsUsecase.UpdateItem(userId, req)
+	if err != nil {
+		if orders.IsInvalid(err) || err.Error() == "product is not in cart" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateCartErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateCartErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, cart).Res()
+}
+
+func (h *cartsHandler) DeleteItem(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+	productId := strings.Trim(c.Params("productId"), " ")
+
+	// the line of a variant is removed with ?variant_id=
+	var variantId *string
+	if v := strings.Trim(c.Query("variant_id"), " "); v != "" {
+		variantId = &v
+	}
+
+	cart, err := h.cartsUsecase.DeleteItem(userId, productId, variantId)
+	if err != nil {
+		if err.Error() == "product is not in cart" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(deleteCartErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteCartErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, cart).Res()
+}
+
+func (h *cartsHandler) MergeCart(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+
+	req := &carts.CartMergeReq{
+		Items: make([]*carts.CartItemReq, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(mergeCartErr),
+			err.Error(),
+		).Res()
+	}
+
+	cart, err := h.cartsUsecase.MergeCart(userId, req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(mergeCartErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, cart).Res()
+}
+
+func (h *cartsHandler) Checkout(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+
+	req := new(carts.CartCheckoutReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(checkoutCartErr),
+			err.Error(),
+		).Res()
+	}
+	if req.Currency == "" {
+		req.Currency = c.Get("X-Currency")
+	}
+
+	order, err := h.cartsUsecase.Checkout(userId, req)
+	if err != nil {
+		if orders.IsInvalid(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(checkoutCartErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(checkoutCartErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
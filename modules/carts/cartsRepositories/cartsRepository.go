@@ -0,0 +1,194 @@
+package cartsRepositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/carts"
+	"github.com/jmoiron/sqlx"
+)
+
+type ICartsRepository interface {
+	FindCart(userId string) (*carts.Cart, error)
+	AddItem(userId string, req *carts.CartItemReq) error
+	UpdateItem(userId string, req *carts.CartItemReq) error
+	DeleteItem(userId, productId string, variantId *string) error
+	MergeItems(userId string, req []*carts.CartItemReq) error
+}
+
+type cartsRepository struct {
+	db *sqlx.DB
+}
+
+func CartsRepository(db *sqlx.DB) ICartsRepository {
+	return &cartsRepository{
+		db: db,
+	}
+}
+
+// findCartId returns id of the user's cart, the cart is created on the first use
+func (r *cartsRepository) findCartId(ctx context.Context, db sqlx.QueryerContext, userId string) (string, error) {
+	query := `
+	INSERT INTO "carts" (
+		"user_id"
+	)
+	VALUES ($1)
+	ON CONFLICT ("user_id") DO UPDATE SET
+		"user_id" = EXCLUDED."user_id"
+	RETURNING "id";`
+
+	var cartId string
+	if err := db.QueryRowxContext(ctx, query, userId).Scan(&cartId); err != nil {
+		return "", fmt.Errorf("get cart failed: %v", err)
+	}
+	return cartId, nil
+}
+
+func (r *cartsRepository) FindCart(userId string) (*carts.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	cartId, err := r.findCartId(ctx, r.db, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT
+		"ci"."product_id",
+		"ci"."variant_id",
+		"ci"."qty",
+		COALESCE("v"."stock", "p"."stock") AS "stock"
+	FROM "carts_items" "ci"
+		JOIN "products" "p" ON "p"."id" = "ci"."product_id"
+		LEFT JOIN "product_variants" "v" ON "v"."id" = "ci"."variant_id"
+	WHERE "ci"."cart_id" = $1
+	ORDER BY "ci"."created_at" ASC;`
+
+	cart := &carts.Cart{
+		Id:     cartId,
+		UserId: userId,
+		Items:  make([]*carts.CartItem, 0),
+	}
+	if err := r.db.SelectContext(ctx, &cart.Items, query, cartId); err != nil {
+		return nil, fmt.Errorf("get cart items failed: %v", err)
+	}
+	return cart, nil
+}
+
+func (r *cartsRepository) AddItem(userId string, req *carts.CartItemReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	cartId, err := r.findCartId(ctx, r.db, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := r.addItem(ctx, r.db, cartId, req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *cartsRepository) addItem(ctx context.Context, db sqlx.ExecerContext, cartId string, req *carts.CartItemReq) error {
+	query := `
+	INSERT INTO "carts_items" (
+		"cart_id",
+		"product_id",
+		"variant_id",
+		"qty"
+	)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT ("cart_id", "product_id", "variant_id") DO UPDATE SET
+		"qty" = "carts_items"."qty" + EXCLUDED."qty";`
+
+	if _, err := db.ExecContext(ctx, query, cartId, req.ProductId, req.VariantId, req.Qty); err != nil {
+		return fmt.Errorf("add cart item failed: %v", err)
+	}
+	return nil
+}
+
+func (r *cartsRepository) UpdateItem(userId string, req *carts.CartItemReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	cartId, err := r.findCartId(ctx, r.db, userId)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO "carts_items" (
+		"cart_id",
+		"product_id",
+		"variant_id",
+		"qty"
+	)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT ("cart_id", "product_id", "variant_id") DO UPDATE SET
+		"qty" = EXCLUDED."qty";`
+
+	if _, err := r.db.ExecContext(ctx, query, cartId, req.ProductId, req.VariantId, req.Qty); err != nil {
+		return fmt.Errorf("update cart item failed: %v", err)
+	}
+	return nil
+}
+
+// DeleteItem removes one line of the cart, variantId is nil for the product without variant
+func (r *cartsRepository) DeleteItem(userId, productId string, variantId *string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	query := `
+	DELETE FROM "carts_items" "ci"
+	USING "carts" "c"
+	WHERE "c"."id" = "ci"."cart_id"
+	AND "c"."user_id" = $1
+	AND "ci"."product_id" = $2
+	AND "ci"."variant_id" IS NOT DISTINCT FROM $3::uuid;`
+
+	result, err := r.db.ExecContext(ctx, query, userId, productId, variantId)
+	if err != nil {
+		return fmt.Errorf("delete cart item failed: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected failed: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("product is not in cart")
+	}
+	return nil
+}
+
+// MergeItems adds items of the guest cart to the user's cart, qty of the same product is summed
+func (r *cartsRepository) MergeItems(userId string, req []*carts.CartItemReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	cartId, err := r.findCartId(ctx, tx, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, item := range req {
+		if err := r.addItem(ctx, tx, cartId, item); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return nil
+}
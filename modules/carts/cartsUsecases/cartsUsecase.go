@@ -0,0 +1,180 @@
+package cartsUsecases
+
+import (
+	"fmt"
+
+	"github.com/NatthawutSK/ri-shop/modules/carts"
+	"github.com/NatthawutSK/ri-shop/modules/carts/cartsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/products"
+	"github.com/NatthawutSK/ri-shop/modules/products/productsRepositories"
+)
+
+type ICartsUsecase interface {
+	FindCart(userId string) (*carts.Cart, error)
+	AddItem(userId string, req *carts.CartItemReq) (*carts.Cart, error)
+	UpdateItem(userId string, req *carts.CartItemReq) (*carts.Cart, error)
+	DeleteItem(userId, productId string, variantId *string) (*carts.Cart, error)
+	MergeCart(userId string, req *carts.CartMergeReq) (*carts.Cart, error)
+	Checkout(userId string, req *carts.CartCheckoutReq) (*orders.Order, error)
+}
+
+type cartsUsecase struct {
+	cartsRepository    cartsRepositories.ICartsRepository
+	productsRepository productsRepositories.IProductsRepository
+	ordersUsecase      ordersUsecases.IOrdersUsecase
+}
+
+func CartsUsecase(cartsRepo cartsRepositories.ICartsRepository, productsRepo productsRepositories.IProductsRepository, ordersUsecase ordersUsecases.IOrdersUsecase) ICartsUsecase {
+	return &cartsUsecase{
+		cartsRepository:    cartsRepo,
+		productsRepository: productsRepo,
+		ordersUsecase:      ordersUsecase,
+	}
+}
+
+// FindCart always returns the current price of products and whether the stock is still enough,
+// lines are priced like the order quote so the cart total is the same as the order
+func (u *cartsUsecase) FindCart(userId string) (*carts.Cart, error) {
+	cart, err := u.cartsRepository.FindCart(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range cart.Items {
+		product, err := u.productsRepository.FindOneProduct(item.ProductId)
+		if err != nil {
+			return nil, fmt.Errorf("find one product failed : %v", err)
+		}
+		item.Product = product
+
+		var variantId string
+		if item.VariantId != nil {
+			variantId = *item.VariantId
+		}
+		// the variant was turned off (or the product got variants) after it was added, it can't be ordered
+		if _, err := product.ApplyVariant(variantId); err != nil {
+			item.IsAvailable = false
+			continue
+		}
+
+		item.IsAvailable = item.Stock >= item.Qty
+		item.Total = product.Price.Mul(item.Qty)
+
+		if item.IsAvailable {
+			cart.Subtotal += item.Total
+		}
+	}
+
+	return cart, nil
+}
+
+// checkItem checks the product and the variant of a line like checkout does, so the cart can always be ordered
+func (u *cartsUsecase) checkItem(req *carts.CartItemReq) error {
+	req.CheckVariant()
+
+	product, err := u.productsRepository.FindOneProduct(req.ProductId)
+	if err != nil {
+		return orders.Invalidf(orders.ErrItem, "product not found")
+	}
+
+	var variantId string
+	if req.VariantId != nil {
+		variantId = *req.VariantId
+	}
+	if _, err := product.SelectVariant(variantId); err != nil {
+		return orders.Invalid(orders.ErrVariant, err)
+	}
+	return nil
+}
+
+func (u *cartsUsecase) AddItem(userId string, req *carts.CartItemReq) (*carts.Cart, error) {
+	if err := u.checkItem(req); err != nil {
+		return nil, err
+	}
+
+	if err := u.cartsRepository.AddItem(userId, req); err != nil {
+		return nil, err
+	}
+	return u.FindCart(userId)
+}
+
+// UpdateItem sets qty of the product (or its variant), qty 0 removes the line from the cart
+func (u *cartsUsecase) UpdateItem(userId string, req *carts.CartItemReq) (*carts.Cart, error) {
+	if req.Qty == 0 {
+		req.CheckVariant()
+		return u.DeleteItem(userId, req.ProductId, req.VariantId)
+	}
+
+	if err := u.checkItem(req); err != nil {
+		return nil, err
+	}
+
+	if err := u.cartsRepository.UpdateItem(userId, req); err != nil {
+		return nil, err
+	}
+	return u.FindCart(userId)
+}
+
+func (u *cartsUsecase) DeleteItem(userId, productId string, variantId *string) (*carts.Cart, error) {
+	if err := u.cartsRepository.DeleteItem(userId, productId, variantId); err != nil {
+		return nil, err
+	}
+	return u.FindCart(userId)
+}
+
+// MergeCart moves the guest cart into the user's cart after sign in, unknown products and variants are skipped
+func (u *cartsUsecase) MergeCart(userId string, req *carts.CartMergeReq) (*carts.Cart, error) {
+	items := make([]*carts.CartItemReq, 0)
+	for _, item := range req.Items {
+		if item == nil || item.Qty < 1 {
+			continue
+		}
+		if err := u.checkItem(item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if err := u.cartsRepository.MergeItems(userId, items); err != nil {
+		return nil, err
+	}
+	return u.FindCart(userId)
+}
+
+// Checkout places an order of every item in the cart, the items are removed in the same transaction as the order
+func (u *cartsUsecase) Checkout(userId string, req *carts.CartCheckoutReq) (*orders.Order, error) {
+	cart, err := u.cartsRepository.FindCart(userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, orders.Invalidf(orders.ErrItem, "cart is empty")
+	}
+
+	order := &orders.Order{
+		UserId:      userId,
+		CartId:      cart.Id,
+		AddressId:   req.AddressId,
+		Address:     req.Address,
+		Contact:     req.Contact,
+		CouponCode:  req.CouponCode,
+		BuyerTaxId:  req.BuyerTaxId,
+		BuyerBranch: req.BuyerBranch,
+		Currency:    req.Currency,
+		Status:      "waiting",
+		Products:    make([]*orders.ProductsOrder, 0),
+	}
+	for _, item := range cart.Items {
+		order.Products = append(order.Products, &orders.ProductsOrder{
+			Qty: item.Qty,
+			Product: &products.Products{
+				Id: item.ProductId,
+			},
+			VariantId: item.VariantId,
+		})
+	}
+
+	return u.ordersUsecase.InsertOrder(order)
+}
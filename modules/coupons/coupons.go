@@ -0,0 +1,126 @@
+package coupons
+
+import (
+	"fmt"
+
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+type Coupon struct {
+	Id                string        `json:"id" db:"id"`
+	Code              string        `json:"code" db:"code"`
+	Type              string        `json:"type" db:"type"`                 // percentage, fixed
+	Value             riMoney.Money `json:"value" db:"value"`               // percent of percentage coupon, baht of fixed coupon
+	MaxDiscount       riMoney.Money `json:"max_discount" db:"max_discount"` // 0 = no cap, used by percentage only
+	MinOrder          riMoney.Money `json:"min_order" db:"min_order"`
+	UsageLimit        int           `json:"usage_limit" db:"usage_limit"`                   // 0 = unlimited
+	UsageLimitPerUser int           `json:"usage_limit_per_user" db:"usage_limit_per_user"` // 0 = unlimited
+	UsedCount         int           `json:"used_count" db:"used_count"`
+	StartsAt          *string       `json:"starts_at" db:"starts_at"`
+	ExpiresAt         *string       `json:"expires_at" db:"expires_at"`
+	IsActive          bool          `json:"is_active" db:"is_active"`
+	CategoryIds       []int         `json:"category_ids"` // empty = every category
+	CreatedAt         string        `json:"created_at" db:"created_at"`
+	UpdatedAt         string        `json:"updated_at" db:"updated_at"`
+
+	// calculated by database with now(), so app and database clock can't disagree
+	IsStarted bool `json:"-" db:"is_started"`
+	IsExpired bool `json:"-" db:"is_expired"`
+}
+
+type CouponFilter struct {
+	Search string `query:"search"` // code
+}
+
+type CouponUpdate struct {
+	Id                string  `json:"-"`
+	IsActive          *bool   `json:"is_active"`
+	UsageLimit        *int    `json:"usage_limit"`
+	UsageLimitPerUser *int    `json:"usage_limit_per_user"`
+	ExpiresAt         *string `json:"expires_at"`
+}
+
+// CouponLine is an order line that the coupon may discount
+type CouponLine struct {
+	CategoryIds []int
+	Total       riMoney.Money
+}
+
+// Check returns why the coupon can't be used, userUsed is how many times the user has already used it
+func (c *Coupon) Check(userUsed int) error {
+	if !c.IsActive {
+		return fmt.Errorf("coupon %s is not active", c.Code)
+	}
+	if !c.IsStarted {
+		return fmt.Errorf("coupon %s is not started yet", c.Code)
+	}
+	if c.IsExpired {
+		return fmt.Errorf("coupon %s is expired", c.Code)
+	}
+	if c.UsageLimit > 0 && c.UsedCount >= c.UsageLimit {
+		return fmt.Errorf("coupon %s has been fully used", c.Code)
+	}
+	if c.UsageLimitPerUser > 0 && userUsed >= c.UsageLimitPerUser {
+		return fmt.Errorf("coupon %s has reached the usage limit for this user", c.Code)
+	}
+	return nil
+}
+
+// Discount calculates the discount of the order, subtotal is used to check the minimum order value
+func (c *Coupon) Discount(subtotal riMoney.Money, lines []*CouponLine) (riMoney.Money, error) {
+	if subtotal < c.MinOrder {
+		return riMoney.Zero, fmt.Errorf("coupon %s requires minimum order of %s", c.Code, c.MinOrder)
+	}
+
+	var eligible riMoney.Money
+	for _, line := range lines {
+		if c.isEligible(line) {
+			eligible += line.Total
+		}
+	}
+	if eligible <= 0 {
+		return riMoney.Zero, fmt.Errorf("coupon %s is not applicable to products in this order", c.Code)
+	}
+
+	var discount riMoney.Money
+	switch c.Type {
+	case "percentage":
+		discount = eligible.Percent(c.Value)
+		if c.MaxDiscount > 0 && discount > c.MaxDiscount {
+			discount = c.MaxDiscount
+		}
+	case "fixed":
+		discount = c.Value
+	default:
+		return riMoney.Zero, fmt.Errorf("coupon type %s is invalid", c.Type)
+	}
+
+	// discount can't be more than the products it applies to
+	if discount > eligible {
+		discount = eligible
+	}
+	return discount, nil
+}
+
+// Eligible tells which of the lines the coupon discounts
+func (c *Coupon) Eligible(lines []*CouponLine) []bool {
+	eligible := make([]bool, len(lines))
+	for i, line := range lines {
+		eligible[i] = c.isEligible(line)
+	}
+	return eligible
+}
+
+func (c *Coupon) isEligible(line *CouponLine) bool {
+	if len(c.CategoryIds) == 0 {
+		return true
+	}
+	for _, categoryId := range c.CategoryIds {
+		for _, lineCategoryId := range line.CategoryIds {
+			if categoryId == lineCategoryId {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package couponsHandlers
+
+import (
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/coupons"
+	"github.com/NatthawutSK/ri-shop/modules/coupons/couponsUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+	"github.com/gofiber/fiber/v2"
+)
+
+type couponsHandlerErrCode string
+
+const (
+	findCouponErr    couponsHandlerErrCode = "coupons-001"
+	findOneCouponErr couponsHandlerErrCode = "coupons-002"
+	insertCouponErr  couponsHandlerErrCode = "coupons-003"
+	updateCouponErr  couponsHandlerErrCode = "coupons-004"
+	deleteCouponErr  couponsHandlerErrCode = "coupons-005"
+)
+
+type ICouponsHandler interface {
+	FindCoupon(c *fiber.Ctx) error
+	FindOneCoupon(c *fiber.Ctx) error
+	InsertCoupon(c *fiber.Ctx) error
+	UpdateCoupon(c *fiber.Ctx) error
+	DeleteCoupon(c *fiber.Ctx) error
+}
+
+type couponsHandler struct {
+	cfg            config.IConfig
+	couponsUsecase couponsUsecases.ICouponsUsecase
+}
+
+func CouponsHandler(couponsUsecase couponsUsecases.ICouponsUsecase, cfg config.IConfig) ICouponsHandler {
+	return &couponsHandler{
+		couponsUsecase: couponsUsecase,
+		cfg:            cfg,
+	}
+}
+
+func (h *couponsHandler) FindCoupon(c *fiber.Ctx) error {
+	req := new(coupons.CouponFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findCouponErr),
+			err.Error(),
+		).Res()
+	}
+
+	couponsData, err := h.couponsUsecase.FindCoupon(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findCouponErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, couponsData).Res()
+}
+
+func (h *couponsHandler) FindOneCoupon(c *fiber.Ctx) error {
+	couponId := strings.Trim(c.Params("couponId"), " ")
+
+	coupon, err := h.couponsUsecase.FindOneCoupon(couponId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findOneCouponErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, coupon).Res()
+}
+
+func (h *couponsHandler) InsertCoupon(c *fiber.Ctx) error {
+	req := &coupons.Coupon{
+		IsActive:    true,
+		CategoryIds: make([]int, 0),
+	}
+
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCouponErr),
+			err.Error(),
+		).Res()
+	}
+
+	// code is case insensitive, always keep it upper case
+	req.Code = strings.ToUpper(strings.Trim(req.Code, " "))
+	if req.Code == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCouponErr),
+			"code is required",
+		).Res()
+	}
+
+	typeMap := map[string]string{
+		"percentage": "percentage",
+		"fixed":      "fixed",
+	}
+	req.Type = typeMap[strings.ToLower(req.Type)]
+	if req.Type == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCouponErr),
+			"type must be percentage or fixed",
+		).Res()
+	}
+
+	if req.Value <= 0 || (req.Type == "percentage" && req.Value > riMoney.FromFloat(100)) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCouponErr),
+			"value is invalid",
+		).Res()
+	}
+
+	if req.StartsAt != nil && *req.StartsAt == "" {
+		req.StartsAt = nil
+	}
+	if req.ExpiresAt != nil && *req.ExpiresAt == "" {
+		req.ExpiresAt = nil
+	}
+
+	coupon, err := h.couponsUsecase.InsertCoupon(req)
+	if err != nil {
+		if err.Error() == "code has been used" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertCouponErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertCouponErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, coupon).Res()
+}
+
+func (h *couponsHandler) UpdateCoupon(c *fiber.Ctx) error {
+	req := new(coupons.CouponUpdate)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCouponErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("couponId"), " ")
+
+	coupon, err := h.couponsUsecase.UpdateCoupon(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateCouponErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, coupon).Res()
+}
+
+func (h *couponsHandler) DeleteCoupon(c *fiber.Ctx) error {
+	couponId := strings.Trim(c.Params("couponId"), " ")
+
+	if err := h.couponsUsecase.DeleteCoupon(couponId); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteCouponErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK,
+		&struct {
+			CouponId string `json:"coupon_id"`
+		}{
+			CouponId: couponId,
+		},
+	).Res()
+}
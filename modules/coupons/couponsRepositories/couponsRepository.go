@@ -0,0 +1,332 @@
+package couponsRepositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/coupons"
+	"github.com/jmoiron/sqlx"
+)
+
+type ICouponsRepository interface {
+	FindCoupon(req *coupons.CouponFilter) ([]*coupons.Coupon, error)
+	FindOneCoupon(couponId string) (*coupons.Coupon, error)
+	FindOneCouponByCode(code string) (*coupons.Coupon, error)
+	CountUserUsage(couponId, userId string) (int, error)
+	InsertCoupon(req *coupons.Coupon) (string, error)
+	UpdateCoupon(req *coupons.CouponUpdate) error
+	DeleteCoupon(couponId string) error
+}
+
+type couponsRepository struct {
+	db *sqlx.DB
+}
+
+func CouponsRepository(db *sqlx.DB) ICouponsRepository {
+	return &couponsRepository{
+		db: db,
+	}
+}
+
+// is_started and is_expired are checked with now() of database
+const selectCouponQuery = `
+	SELECT
+		"id",
+		"code",
+		"type",
+		"value",
+		"max_discount",
+		"min_order",
+		"usage_limit",
+		"usage_limit_per_user",
+		"used_count",
+		"starts_at",
+		"expires_at",
+		"is_active",
+		"created_at",
+		"updated_at",
+		("starts_at" IS NULL OR "starts_at" <= now()) AS "is_started",
+		("expires_at" IS NOT NULL AND "expires_at" <= now()) AS "is_expired"
+	FROM "coupons"`
+
+func (r *couponsRepository) FindCoupon(req *coupons.CouponFilter) ([]*coupons.Coupon, error) {
+	query := selectCouponQuery
+
+	filterValues := make([]any, 0)
+	if req.Search != "" {
+		query += `
+	WHERE "code" LIKE $1`
+
+		filterValues = append(filterValues, "%"+strings.ToUpper(req.Search)+"%")
+	}
+	query += `
+	ORDER BY "created_at" DESC;`
+
+	couponsData := make([]*coupons.Coupon, 0)
+	if err := r.db.Select(&couponsData, query, filterValues...); err != nil {
+		return nil, fmt.Errorf("select coupons failed: %v", err)
+	}
+
+	// load categories of every coupon in one query
+	categories := make([]*struct {
+		CouponId   string `db:"coupon_id"`
+		CategoryId int    `db:"category_id"`
+	}, 0)
+	if err := r.db.Select(&categories, `
+	SELECT
+		"coupon_id",
+		"category_id"
+	FROM "coupons_categories";`); err != nil {
+		return nil, fmt.Errorf("select coupons categories failed: %v", err)
+	}
+
+	categoriesMap := make(map[string][]int)
+	for _, c := range categories {
+		categoriesMap[c.CouponId] = append(categoriesMap[c.CouponId], c.CategoryId)
+	}
+	for i := range couponsData {
+		couponsData[i].CategoryIds = categoriesMap[couponsData[i].Id]
+		if couponsData[i].CategoryIds == nil {
+			couponsData[i].CategoryIds = make([]int, 0)
+		}
+	}
+
+	return couponsData, nil
+}
+
+func (r *couponsRepository) FindOneCoupon(couponId string) (*coupons.Coupon, error) {
+	query := selectCouponQuery + `
+	WHERE "id" = $1;`
+
+	coupon := new(coupons.Coupon)
+	if err := r.db.Get(coupon, query, couponId); err != nil {
+		return nil, fmt.Errorf("get coupon failed: %v", err)
+	}
+
+	if err := r.findCategoryIds(coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (r *couponsRepository) FindOneCouponByCode(code string) (*coupons.Coupon, error) {
+	query := selectCouponQuery + `
+	WHERE "code" = $1;`
+
+	coupon := new(coupons.Coupon)
+	if err := r.db.Get(coupon, query, strings.ToUpper(code)); err != nil {
+		return nil, fmt.Errorf("coupon %s not found", code)
+	}
+
+	if err := r.findCategoryIds(coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (r *couponsRepository) findCategoryIds(coupon *coupons.Coupon) error {
+	query := `
+	SELECT
+		"category_id"
+	FROM "coupons_categories"
+	WHERE "coupon_id" = $1;`
+
+	coupon.CategoryIds = make([]int, 0)
+	if err := r.db.Select(&coupon.CategoryIds, query, coupon.Id); err != nil {
+		return fmt.Errorf("get coupon categories failed: %v", err)
+	}
+	return nil
+}
+
+func (r *couponsRepository) CountUserUsage(couponId, userId string) (int, error) {
+	query := `
+	SELECT
+		COUNT(*)
+	FROM "coupon_usages"
+	WHERE "coupon_id" = $1
+	AND "user_id" = $2;`
+
+	var count int
+	if err := r.db.Get(&count, query, couponId, userId); err != nil {
+		return 0, fmt.Errorf("count coupon usages failed: %v", err)
+	}
+	return count, nil
+}
+
+func (r *couponsRepository) InsertCoupon(req *coupons.Coupon) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "coupons" (
+		"code",
+		"type",
+		"value",
+		"max_discount",
+		"min_order",
+		"usage_limit",
+		"usage_limit_per_user",
+		"starts_at",
+		"expires_at",
+		"is_active"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING "id";`
+
+	if err := tx.QueryRowxContext(
+		ctx,
+		query,
+		req.Code,
+		req.Type,
+		req.Value,
+		req.MaxDiscount,
+		req.MinOrder,
+		req.UsageLimit,
+		req.UsageLimitPerUser,
+		req.StartsAt,
+		req.ExpiresAt,
+		req.IsActive,
+	).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "coupons_code_key") {
+			return "", fmt.Errorf("code has been used")
+		}
+		return "", fmt.Errorf("insert coupon failed: %v", err)
+	}
+
+	if len(req.CategoryIds) > 0 {
+		queryCategories := `
+	INSERT INTO "coupons_categories" (
+		"coupon_id",
+		"category_id"
+	)
+	VALUES`
+
+		valueStack := make([]any, 0)
+		for i, categoryId := range req.CategoryIds {
+			valueStack = append(valueStack, req.Id, categoryId)
+
+			if i != len(req.CategoryIds)-1 {
+				queryCategories += fmt.Sprintf(`
+		($%d, $%d),`, i*2+1, i*2+2)
+			} else {
+				queryCategories += fmt.Sprintf(`
+		($%d, $%d);`, i*2+1, i*2+2)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, queryCategories, valueStack...); err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("insert coupons categories failed: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return req.Id, nil
+}
+
+func (r *couponsRepository) UpdateCoupon(req *coupons.CouponUpdate) error {
+	query := `
+	UPDATE "coupons" SET`
+
+	queryWhereStack := make([]string, 0)
+	values := make([]any, 0)
+	lastIndex := 1
+
+	if req.IsActive != nil {
+		values = append(values, *req.IsActive)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"is_active" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.UsageLimit != nil {
+		values = append(values, *req.UsageLimit)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"usage_limit" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.UsageLimitPerUser != nil {
+		values = append(values, *req.UsageLimitPerUser)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"usage_limit_per_user" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.ExpiresAt != nil {
+		// empty string = never expire
+		values = append(values, *req.ExpiresAt)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"expires_at" = NULLIF($%d, '')::TIMESTAMP?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if len(queryWhereStack) == 0 {
+		return fmt.Errorf("nothing to update")
+	}
+
+	values = append(values, req.Id)
+
+	queryClose := fmt.Sprintf(`
+	WHERE "id" = $%d;`, lastIndex)
+
+	for i := range queryWhereStack {
+		if i != len(queryWhereStack)-1 {
+			query += strings.Replace(queryWhereStack[i], "?", ",", 1)
+		} else {
+			query += strings.Replace(queryWhereStack[i], "?", "", 1)
+		}
+	}
+	query += queryClose
+
+	result, err := r.db.ExecContext(context.Background(), query, values...)
+	if err != nil {
+		return fmt.Errorf("update coupon failed: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected failed: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("coupon id not found")
+	}
+	return nil
+}
+
+func (r *couponsRepository) DeleteCoupon(couponId string) error {
+	query := `
+	DELETE FROM "coupons"
+	WHERE "id" = $1;`
+
+	result, err := r.db.ExecContext(context.Background(), query, couponId)
+	if err != nil {
+		return fmt.Errorf("delete coupon failed: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected failed: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("coupon id not found")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package couponsUsecases
+
+import (
+	"github.com/NatthawutSK/ri-shop/modules/coupons"
+	"github.com/NatthawutSK/ri-shop/modules/coupons/couponsRepositories"
+)
+
+type ICouponsUsecase interface {
+	FindCoupon(req *coupons.CouponFilter) ([]*coupons.Coupon, error)
+	FindOneCoupon(couponId string) (*coupons.Coupon, error)
+	InsertCoupon(req *coupons.Coupon) (*coupons.Coupon, error)
+	UpdateCoupon(req *coupons.CouponUpdate) (*coupons.Coupon, error)
+	DeleteCoupon(couponId string) error
+}
+
+type couponsUsecase struct {
+	couponsRepository couponsRepositories.ICouponsRepository
+}
+
+func CouponsUsecase(couponsRepository couponsRepositories.ICouponsRepository) ICouponsUsecase {
+	return &couponsUsecase{
+		couponsRepository: couponsRepository,
+	}
+}
+
+func (u *couponsUsecase) FindCoupon(req *coupons.CouponFilter) ([]*coupons.Coupon, error) {
+	couponsData, err := u.couponsRepository.FindCoupon(req)
+	if err != nil {
+		return nil, err
+	}
+	return couponsData, nil
+}
+
+func (u *couponsUsecase) FindOneCoupon(couponId string) (*coupons.Coupon, error) {
+	coupon, err := u.couponsRepository.FindOneCoupon(couponId)
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (u *couponsUsecase) InsertCoupon(req *coupons.Coupon) (*coupons.Coupon, error) {
+	couponId, err := u.couponsRepository.InsertCoupon(req)
+	if err != nil {
+		return nil, err
+	}
+
+	coupon, err := u.couponsRepository.FindOneCoupon(couponId)
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (u *couponsUsecase) UpdateCoupon(req *coupons.CouponUpdate) (*coupons.Coupon, error) {
+	if err := u.couponsRepository.UpdateCoupon(req); err != nil {
+		return nil, err
+	}
+
+	coupon, err := u.couponsRepository.FindOneCoupon(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (u *couponsUsecase) DeleteCoupon(couponId string) error {
+	if err := u.couponsRepository.DeleteCoupon(couponId); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package currencies
+
+import (
+	"fmt"
+
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+// BaseCurrency is the currency of every amount in the database, orders are always paid in baht
+const BaseCurrency = "THB"
+
+// Currency is kept by admins, rate is baht of 1 unit of the currency.
+// Converted prices are only for display: every amount is converted from baht on its own and
+// rounded half away from zero to the minor unit of the currency (decimals).
+type Currency struct {
+	Code      string       `json:"code" db:"code"`
+	Name      string       `json:"name" db:"name"`
+	Symbol    string       `json:"symbol" db:"symbol"`
+	Decimals  int          `json:"decimals" db:"decimals"`
+	Rate      riMoney.Rate `json:"rate" db:"rate"`
+	IsActive  bool         `json:"is_active" db:"is_active"`
+	UpdatedBy *string      `json:"updated_by" db:"updated_by"`
+	CreatedAt string       `json:"created_at" db:"created_at"`
+	UpdatedAt string       `json:"updated_at" db:"updated_at"`
+}
+
+type CurrencyFilter struct {
+	IsActive *bool `query:"is_active"`
+}
+
+type CurrencyUpdate struct {
+	Code      string        `json:"-"`
+	Name      *string       `json:"name"`
+	Symbol    *string       `json:"symbol"`
+	Decimals  *int          `json:"decimals"`
+	Rate      *riMoney.Rate `json:"rate"`
+	IsActive  *bool         `json:"is_active"`
+	UpdatedBy string        `json:"-"`
+}
+
+// Price is an amount in baht converted to another currency
+type Price struct {
+	Currency string        `json:"currency"`
+	Symbol   string        `json:"symbol"`
+	Rate     riMoney.Rate  `json:"rate"`
+	Amount   riMoney.Money `json:"amount"`
+}
+
+// Convert changes an amount in baht to the currency with the rounding of the currency
+func (c *Currency) Convert(amount riMoney.Money) riMoney.Money {
+	if c.Code == BaseCurrency {
+		return amount
+	}
+	return amount.Convert(c.Rate, c.Decimals)
+}
+
+func (c *Currency) Price(amount riMoney.Money) *Price {
+	return &Price{
+		Currency: c.Code,
+		Symbol:   c.Symbol,
+		Rate:     c.Rate,
+		Amount:   c.Convert(amount),
+	}
+}
+
+// Check returns why prices can't be shown in the currency
+func (c *Currency) Check() error {
+	if !c.IsActive || c.Rate <= 0 {
+		return fmt.Errorf("currency %s is not supported", c.Code)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package currenciesHandlers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/currencies"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/gofiber/fiber/v2"
+)
+
+type currenciesHandlerErrCode string
+
+const (
+	findCurrencyErr    currenciesHandlerErrCode = "currencies-001"
+	findOneCurrencyErr currenciesHandlerErrCode = "currencies-002"
+	insertCurrencyErr  currenciesHandlerErrCode = "currencies-003"
+	updateCurrencyErr  currenciesHandlerErrCode = "currencies-004"
+)
+
+type ICurrenciesHandler interface {
+	FindCurrency(c *fiber.Ctx) error
+	FindOneCurrency(c *fiber.Ctx) error
+	InsertCurrency(c *fiber.Ctx) error
+	UpdateCurrency(c *fiber.Ctx) error
+}
+
+type currenciesHandler struct {
+	cfg               config.IConfig
+	currenciesUsecase currenciesUsecases.ICurrenciesUsecase
+}
+
+func CurrenciesHandler(currenciesUsecase currenciesUsecases.ICurrenciesUsecase, cfg config.IConfig) ICurrenciesHandler {
+	return &currenciesHandler{
+		currenciesUsecase: currenciesUsecase,
+		cfg:               cfg,
+	}
+}
+
+func (h *currenciesHandler) FindCurrency(c *fiber.Ctx) error {
+	req := new(currencies.CurrencyFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	currenciesData, err := h.currenciesUsecase.FindCurrency(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, currenciesData).Res()
+}
+
+func (h *currenciesHandler) FindOneCurrency(c *fiber.Ctx) error {
+	code := strings.ToUpper(strings.Trim(c.Params("code"), " "))
+
+	currency, err := h.currenciesUsecase.FindOneCurrency(code)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(findOneCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, currency).Res()
+}
+
+func (h *currenciesHandler) InsertCurrency(c *fiber.Ctx) error {
+	req := &currencies.Currency{
+		Decimals: 2,
+		IsActive: true,
+	}
+
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	// ISO 4217 code such as USD
+	req.Code = strings.ToUpper(strings.Trim(req.Code, " "))
+	if match, _ := regexp.MatchString(`^[A-Z]{3}$`, req.Code); !match {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCurrencyErr),
+			"code must be 3 letters",
+		).Res()
+	}
+
+	req.Name = strings.Trim(req.Name, " ")
+	if req.Name == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCurrencyErr),
+			"name is required",
+		).Res()
+	}
+
+	if req.Rate <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCurrencyErr),
+			"rate must be more than 0",
+		).Res()
+	}
+
+	if req.Decimals < 0 || req.Decimals > 2 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertCurrencyErr),
+			"decimals must be 0 to 2",
+		).Res()
+	}
+
+	userId := c.Locals("userId").(string)
+	req.UpdatedBy = &userId
+
+	currency, err := h.currenciesUsecase.InsertCurrency(req)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "already exists") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertCurrencyErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, currency).Res()
+}
+
+func (h *currenciesHandler) UpdateCurrency(c *fiber.Ctx) error {
+	req := new(currencies.CurrencyUpdate)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+	req.Code = strings.ToUpper(strings.Trim(c.Params("code"), " "))
+	req.UpdatedBy = c.Locals("userId").(string)
+
+	if req.Rate != nil && *req.Rate <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCurrencyErr),
+			"rate must be more than 0",
+		).Res()
+	}
+	if req.Decimals != nil && (*req.Decimals < 0 || *req.Decimals > 2) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCurrencyErr),
+			"decimals must be 0 to 2",
+		).Res()
+	}
+
+	currency, err := h.currenciesUsecase.UpdateCurrency(req)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateCurrencyErr),
+				err.Error(),
+			).Res()
+		}
+		if err.Error() == "nothing to update" || strings.HasPrefix(err.Error(), "cannot change") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateCurrencyErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, currency).Res()
+}
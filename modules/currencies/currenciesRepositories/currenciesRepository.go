@@ -0,0 +1,198 @@
+package currenciesRepositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/currencies"
+	"github.com/jmoiron/sqlx"
+)
+
+type ICurrenciesRepository interface {
+	FindCurrency(req *currencies.CurrencyFilter) ([]*currencies.Currency, error)
+	FindOneCurrency(code string) (*currencies.Currency, error)
+	InsertCurrency(req *currencies.Currency) error
+	UpdateCurrency(req *currencies.CurrencyUpdate) error
+}
+
+type currenciesRepository struct {
+	db *sqlx.DB
+}
+
+func CurrenciesRepository(db *sqlx.DB) ICurrenciesRepository {
+	return &currenciesRepository{
+		db: db,
+	}
+}
+
+const selectCurrencyQuery = `
+	SELECT
+		"code",
+		"name",
+		"symbol",
+		"decimals",
+		"rate",
+		"is_active",
+		"updated_by",
+		"created_at",
+		"updated_at"
+	FROM "currencies"`
+
+func (r *currenciesRepository) FindCurrency(req *currencies.CurrencyFilter) ([]*currencies.Currency, error) {
+	query := selectCurrencyQuery
+
+	filterValues := make([]any, 0)
+	if req.IsActive != nil {
+		query += `
+	WHERE "is_active" = $1`
+
+		filterValues = append(filterValues, *req.IsActive)
+	}
+	query += `
+	ORDER BY "code" ASC;`
+
+	currenciesData := make([]*currencies.Currency, 0)
+	if err := r.db.Select(&currenciesData, query, filterValues...); err != nil {
+		return nil, fmt.Errorf("select currencies failed: %v", err)
+	}
+	return currenciesData, nil
+}
+
+func (r *currenciesRepository) FindOneCurrency(code string) (*currencies.Currency, error) {
+	query := selectCurrencyQuery + `
+	WHERE "code" = $1;`
+
+	currency := new(currencies.Currency)
+	if err := r.db.Get(currency, query, strings.ToUpper(code)); err != nil {
+		return nil, fmt.Errorf("currency %s not found", code)
+	}
+	return currency, nil
+}
+
+func (r *currenciesRepository) InsertCurrency(req *currencies.Currency) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	query := `
+	INSERT INTO "currencies" (
+		"code",
+		"name",
+		"symbol",
+		"decimals",
+		"rate",
+		"is_active",
+		"updated_by"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);`
+
+	if _, err := r.db.ExecContext(
+		ctx,
+		query,
+		req.Code,
+		req.Name,
+		req.Symbol,
+		req.Decimals,
+		req.Rate,
+		req.IsActive,
+		req.UpdatedBy,
+	); err != nil {
+		if strings.Contains(err.Error(), "currencies_pkey") {
+			return fmt.Errorf("currency %s already exists", req.Code)
+		}
+		return fmt.Errorf("insert currency failed: %v", err)
+	}
+	return nil
+}
+
+func (r *currenciesRepository) UpdateCurrency(req *currencies.CurrencyUpdate) error {
+	query := `
+	UPDATE "currencies" SET`
+
+	queryWhereStack := make([]string, 0)
+	values := make([]any, 0)
+	lastIndex := 1
+
+	if req.Name != nil {
+		values = append(values, *req.Name)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"name" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.Symbol != nil {
+		values = append(values, *req.Symbol)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"symbol" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.Decimals != nil {
+		values = append(values, *req.Decimals)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"decimals" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.Rate != nil {
+		values = append(values, *req.Rate)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"rate" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.IsActive != nil {
+		values = append(values, *req.IsActive)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"is_active" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if len(queryWhereStack) == 0 {
+		return fmt.Errorf("nothing to update")
+	}
+
+	// who changed the rate last
+	values = append(values, req.UpdatedBy)
+	queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"updated_by" = $%d?`, lastIndex))
+	lastIndex++
+
+	values = append(values, req.Code)
+
+	queryClose := fmt.Sprintf(`
+	WHERE "code" = $%d;`, lastIndex)
+
+	for i := range queryWhereStack {
+		if i != len(queryWhereStack)-1 {
+			query += strings.Replace(queryWhereStack[i], "?", ",", 1)
+		} else {
+			query += strings.Replace(queryWhereStack[i], "?", "", 1)
+		}
+	}
+	query += queryClose
+
+	result, err := r.db.ExecContext(context.Background(), query, values...)
+	if err != nil {
+		return fmt.Errorf("update currency failed: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected failed: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("currency %s not found", req.Code)
+	}
+	return nil
+}
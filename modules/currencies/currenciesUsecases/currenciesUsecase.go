@@ -0,0 +1,72 @@
+package currenciesUsecases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/modules/currencies"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesRepositories"
+)
+
+type ICurrenciesUsecase interface {
+	FindCurrency(req *currencies.CurrencyFilter) ([]*currencies.Currency, error)
+	FindOneCurrency(code string) (*currencies.Currency, error)
+	InsertCurrency(req *currencies.Currency) (*currencies.Currency, error)
+	UpdateCurrency(req *currencies.CurrencyUpdate) (*currencies.Currency, error)
+}
+
+type currenciesUsecase struct {
+	currenciesRepository currenciesRepositories.ICurrenciesRepository
+}
+
+func CurrenciesUsecase(currenciesRepository currenciesRepositories.ICurrenciesRepository) ICurrenciesUsecase {
+	return &currenciesUsecase{
+		currenciesRepository: currenciesRepository,
+	}
+}
+
+func (u *currenciesUsecase) FindCurrency(req *currencies.CurrencyFilter) ([]*currencies.Currency, error) {
+	currenciesData, err := u.currenciesRepository.FindCurrency(req)
+	if err != nil {
+		return nil, err
+	}
+	return currenciesData, nil
+}
+
+func (u *currenciesUsecase) FindOneCurrency(code string) (*currencies.Currency, error) {
+	currency, err := u.currenciesRepository.FindOneCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+	return currency, nil
+}
+
+func (u *currenciesUsecase) InsertCurrency(req *currencies.Currency) (*currencies.Currency, error) {
+	if err := u.currenciesRepository.InsertCurrency(req); err != nil {
+		return nil, err
+	}
+
+	currency, err := u.currenciesRepository.FindOneCurrency(req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return currency, nil
+}
+
+// UpdateCurrency changes the rate used by new prices and orders, orders keep the rate of their checkout
+func (u *currenciesUsecase) UpdateCurrency(req *currencies.CurrencyUpdate) (*currencies.Currency, error) {
+	// baht is the base of every rate, its rate is always 1
+	if strings.ToUpper(req.Code) == currencies.BaseCurrency && (req.Rate != nil || (req.IsActive != nil && !*req.IsActive)) {
+		return nil, fmt.Errorf("cannot change rate of base currency %s", currencies.BaseCurrency)
+	}
+
+	if err := u.currenciesRepository.UpdateCurrency(req); err != nil {
+		return nil, err
+	}
+
+	currency, err := u.currenciesRepository.FindOneCurrency(req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return currency, nil
+}
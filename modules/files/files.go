@@ -1,6 +1,12 @@
 package files
 
-import "mime/multipart"
+import (
+	"fmt"
+	"math"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+)
 
 type FileReq struct {
 	File        *multipart.FileHeader `form:"file"`
@@ -17,3 +23,21 @@ type FileRes struct {
 type DeleteFileReq struct {
 	Destination string `json:"destination"`
 }
+
+// CheckImage validates extension and size of an uploaded image, it returns the file extension
+func CheckImage(file *multipart.FileHeader, sizeLimit int) (string, error) {
+	extMap := map[string]string{
+		"png":  "png",
+		"jpg":  "jpg",
+		"jpeg": "jpeg",
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(file.Filename), ".")
+	if extMap[ext] != ext || extMap[ext] == "" {
+		return "", fmt.Errorf("invalid file extension")
+	}
+	if file.Size > int64(sizeLimit) {
+		return "", fmt.Errorf("file size must less than %d MiB", int(math.Ceil(float64(sizeLimit)/math.Pow(1024, 2))))
+	}
+	return ext, nil
+}
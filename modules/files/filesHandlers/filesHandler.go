@@ -2,9 +2,6 @@ package filesHandlers
 
 import (
 	"fmt"
-	"math"
-	"path/filepath"
-	"strings"
 
 	"github.com/NatthawutSK/ri-shop/config"
 	"github.com/NatthawutSK/ri-shop/modules/entities"
@@ -54,27 +51,13 @@ func (h *fileHandler) UploadFiles(c *fiber.Ctx) error {
 	filesReq := form.File["files"]
 	destination := form.Value["destination"]
 
-	// files ext validation
-	extMap := map[string]string{
-		"png" : "png",
-		"jpg" : "jpg",
-		"jpeg" : "jpeg",
-	}
-
 	for _, file := range filesReq {
-		ext := strings.TrimPrefix(filepath.Ext(file.Filename), ".")
-		if extMap[ext] != ext || extMap[ext] == "" {
-			return entities.NewResponse(c).Error(
-				fiber.ErrBadRequest.Code,
-				string(uploadFilesErr),
-				"invalid file extension",
-			).Res()
-		}
-		if file.Size > int64(h.cfg.App().FileLimit()) {
+		ext, err := files.CheckImage(file, h.cfg.App().FileLimit())
+		if err != nil {
 			return entities.NewResponse(c).Error(
 				fiber.ErrBadRequest.Code,
 				string(uploadFilesErr),
-				fmt.Sprintf("file size must less than %d MiB", int(math.Ceil(float64(h.cfg.App().FileLimit())/math.Pow(1024, 2)))),
+				err.Error(),
 			).Res()
 		}
 
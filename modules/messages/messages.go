@@ -0,0 +1,46 @@
+package messages
+
+import "github.com/NatthawutSK/ri-shop/modules/files"
+
+type Message struct {
+	Id          string           `json:"id"`
+	OrderId     string           `json:"order_id"`
+	UserId      string           `json:"user_id"` // sender
+	Username    string           `json:"username"`
+	IsAdmin     bool             `json:"is_admin"`
+	IsInternal  bool             `json:"is_internal"` // admin note, never returned to customer
+	Message     string           `json:"message"`
+	Attachments []*files.FileRes `json:"attachments"`
+	CreatedAt   string           `json:"created_at"`
+}
+
+type MessageReq struct {
+	OrderId     string           `json:"-"`
+	UserId      string           `json:"-"` // owner of the order from params
+	SenderId    string           `json:"-"`
+	IsAdmin     bool             `json:"-"`
+	IsInternal  bool             `json:"is_internal" form:"is_internal"`
+	Message     string           `json:"message" form:"message"`
+	Attachments []*files.FileRes `json:"-"`
+}
+
+type MessageFilter struct {
+	OrderId  string
+	UserId   string // owner of the order from params
+	ReaderId string
+	IsAdmin  bool
+}
+
+// UnreadCount is the messages of the other side posted after the reader last opened the order
+type UnreadCount struct {
+	OrderId       string `json:"order_id" db:"order_id"`
+	UserId        string `json:"user_id" db:"user_id"`
+	Unread        int    `json:"unread" db:"unread"`
+	LastMessageAt string `json:"last_message_at" db:"last_message_at"`
+}
+
+type UnreadFilter struct {
+	UserId   string // owner of the orders, empty is every order
+	ReaderId string
+	IsAdmin  bool
+}
@@ -0,0 +1,199 @@
+package messagesHandlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/files"
+	"github.com/NatthawutSK/ri-shop/modules/files/filesUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/messages"
+	"github.com/NatthawutSK/ri-shop/modules/messages/messagesUsecases"
+	"github.com/NatthawutSK/ri-shop/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type messagesHandlerErrCode string
+
+const (
+	findMessageErr   messagesHandlerErrCode = "messages-001"
+	insertMessageErr messagesHandlerErrCode = "messages-002"
+	findUnreadErr    messagesHandlerErrCode = "messages-003"
+)
+
+const maxMessageLength = 2000
+
+type IMessagesHandler interface {
+	FindMessage(c *fiber.Ctx) error
+	InsertMessage(c *fiber.Ctx) error
+	FindUnread(c *fiber.Ctx) error
+}
+
+type messagesHandler struct {
+	cfg             config.IConfig
+	messagesUsecase messagesUsecases.IMessagesUsecase
+	fileUsecase     filesUsecases.IFilesUsecase
+}
+
+func MessagesHandler(messagesUsecase messagesUsecases.IMessagesUsecase, cfg config.IConfig, fileUsecase filesUsecases.IFilesUsecase) IMessagesHandler {
+	return &messagesHandler{
+		messagesUsecase: messagesUsecase,
+		cfg:             cfg,
+		fileUsecase:     fileUsecase,
+	}
+}
+
+func (h *messagesHandler) FindMessage(c *fiber.Ctx) error {
+	req := &messages.MessageFilter{
+		OrderId:  strings.Trim(c.Params("order_id"), " "),
+		UserId:   strings.Trim(c.Params("user_id"), " "),
+		ReaderId: c.Locals("userId").(string),
+		IsAdmin:  c.Locals("userRoleId").(int) == 2,
+	}
+
+	messagesData, err := h.messagesUsecase.FindMessage(req)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findMessageErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findMessageErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, messagesData).Res()
+}
+
+// InsertMessage accepts multipart form with "message", "is_internal" and "attachments" files,
+// a json body without attachments is accepted too
+func (h *messagesHandler) InsertMessage(c *fiber.Ctx) error {
+	req := &messages.MessageReq{
+		OrderId:     strings.Trim(c.Params("order_id"), " "),
+		UserId:      strings.Trim(c.Params("user_id"), " "),
+		SenderId:    c.Locals("userId").(string),
+		IsAdmin:     c.Locals("userRoleId").(int) == 2,
+		Attachments: make([]*files.FileRes, 0),
+	}
+
+	form, err := c.MultipartForm()
+	if err == nil {
+		if message := form.Value["message"]; len(message) > 0 {
+			req.Message = message[0]
+		}
+		if isInternal := form.Value["is_internal"]; len(isInternal) > 0 {
+			req.IsInternal = isInternal[0] == "true"
+		}
+	} else if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertMessageErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.Message = strings.Trim(req.Message, " ")
+	if len([]rune(req.Message)) > maxMessageLength {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertMessageErr),
+			fmt.Sprintf("message must not be longer than %d characters", maxMessageLength),
+		).Res()
+	}
+
+	// attachments
+	filesReq := make([]*files.FileReq, 0)
+	if form != nil {
+		for _, file := range form.File["attachments"] {
+			ext, err := files.CheckImage(file, h.cfg.App().FileLimit())
+			if err != nil {
+				return entities.NewResponse(c).Error(
+					fiber.ErrBadRequest.Code,
+					string(insertMessageErr),
+					err.Error(),
+				).Res()
+			}
+
+			filename := utils.RandFileName(ext)
+			filesReq = append(filesReq, &files.FileReq{
+				File:        file,
+				Destination: fmt.Sprintf("messages/%s/%s", req.OrderId, filename),
+				FileName:    filename,
+				Extension:   ext,
+			})
+		}
+	}
+	if req.Message == "" && len(filesReq) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertMessageErr),
+			"message or attachments is required",
+		).Res()
+	}
+	if len(filesReq) > 0 {
+		res, err := h.fileUsecase.UploadToGCP(filesReq)
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(insertMessageErr),
+				err.Error(),
+			).Res()
+		}
+		req.Attachments = res
+	}
+
+	message, err := h.messagesUsecase.InsertMessage(req)
+	if err != nil {
+		// the attachments are not attached to any message, remove them
+		if len(filesReq) > 0 {
+			deleteReq := make([]*files.DeleteFileReq, 0)
+			for _, f := range filesReq {
+				deleteReq = append(deleteReq, &files.DeleteFileReq{
+					Destination: f.Destination,
+				})
+			}
+			h.fileUsecase.DeleteFileOnGCP(deleteReq)
+		}
+
+		if err.Error() == "order not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(insertMessageErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertMessageErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, message).Res()
+}
+
+// FindUnread counts unread messages per order, orders of user_id in params or every order on the admin inbox
+func (h *messagesHandler) FindUnread(c *fiber.Ctx) error {
+	req := &messages.UnreadFilter{
+		UserId:   strings.Trim(c.Params("user_id"), " "),
+		ReaderId: c.Locals("userId").(string),
+		IsAdmin:  c.Locals("userRoleId").(int) == 2,
+	}
+
+	counts, err := h.messagesUsecase.FindUnread(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findUnreadErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, counts).Res()
+}
@@ -0,0 +1,195 @@
+package messagesRepositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/messages"
+	"github.com/jmoiron/sqlx"
+)
+
+type IMessagesRepository interface {
+	FindMessage(req *messages.MessageFilter) ([]*messages.Message, error)
+	FindOneMessage(messageId string) (*messages.Message, error)
+	InsertMessage(req *messages.MessageReq) (string, error)
+	ReadMessage(orderId, readerId string) error
+	FindUnread(req *messages.UnreadFilter) ([]*messages.UnreadCount, error)
+}
+
+type messagesRepository struct {
+	db *sqlx.DB
+}
+
+func MessagesRepository(db *sqlx.DB) IMessagesRepository {
+	return &messagesRepository{
+		db: db,
+	}
+}
+
+const selectMessageQuery = `
+	SELECT
+		"m"."id",
+		"m"."order_id",
+		"m"."user_id",
+		"u"."username",
+		"m"."is_admin",
+		"m"."is_internal",
+		"m"."message",
+		"m"."attachments",
+		"m"."created_at"
+	FROM "order_messages" "m"
+		JOIN "users" "u" ON "u"."id" = "m"."user_id"`
+
+// checkOrder makes sure the order belongs to the user in params
+func (r *messagesRepository) checkOrder(ctx context.Context, orderId, userId string) error {
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, `
+	SELECT EXISTS (
+		SELECT 1
+		FROM "orders"
+		WHERE "id" = $1
+		AND "user_id" = $2
+	);`, orderId, userId); err != nil {
+		return fmt.Errorf("get order failed: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("order not found")
+	}
+	return nil
+}
+
+func (r *messagesRepository) FindMessage(req *messages.MessageFilter) ([]*messages.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	if err := r.checkOrder(ctx, req.OrderId, req.UserId); err != nil {
+		return nil, err
+	}
+
+	// customer never sees internal notes
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (` + selectMessageQuery + `
+	WHERE "m"."order_id" = $1
+	AND ($2 OR NOT "m"."is_internal")
+	ORDER BY "m"."created_at" ASC
+	) AS "t";`
+
+	bytes := make([]byte, 0)
+	if err := r.db.GetContext(ctx, &bytes, query, req.OrderId, req.IsAdmin); err != nil {
+		return nil, fmt.Errorf("get messages failed: %v", err)
+	}
+
+	messagesData := make([]*messages.Message, 0)
+	if err := json.Unmarshal(bytes, &messagesData); err != nil {
+		return nil, fmt.Errorf("unmarshal messages failed: %v", err)
+	}
+	return messagesData, nil
+}
+
+func (r *messagesRepository) FindOneMessage(messageId string) (*messages.Message, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (` + selectMessageQuery + `
+	WHERE "m"."id" = $1
+	) AS "t";`
+
+	bytes := make([]byte, 0)
+	if err := r.db.Get(&bytes, query, messageId); err != nil {
+		return nil, fmt.Errorf("message not found")
+	}
+
+	message := new(messages.Message)
+	if err := json.Unmarshal(bytes, message); err != nil {
+		return nil, fmt.Errorf("unmarshal message failed: %v", err)
+	}
+	return message, nil
+}
+
+func (r *messagesRepository) InsertMessage(req *messages.MessageReq) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	if err := r.checkOrder(ctx, req.OrderId, req.UserId); err != nil {
+		return "", err
+	}
+
+	attachments, err := json.Marshal(req.Attachments)
+	if err != nil {
+		return "", fmt.Errorf("marshal attachments failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "order_messages" (
+		"order_id",
+		"user_id",
+		"is_admin",
+		"is_internal",
+		"message",
+		"attachments"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING "id";`
+
+	var messageId string
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.OrderId,
+		req.SenderId,
+		req.IsAdmin,
+		req.IsInternal,
+		req.Message,
+		attachments,
+	).Scan(&messageId); err != nil {
+		return "", fmt.Errorf("insert message failed: %v", err)
+	}
+	return messageId, nil
+}
+
+// ReadMessage moves the last read time of the reader to now
+func (r *messagesRepository) ReadMessage(orderId, readerId string) error {
+	query := `
+	INSERT INTO "order_message_reads" (
+		"order_id",
+		"user_id"
+	)
+	VALUES ($1, $2)
+	ON CONFLICT ("order_id", "user_id") DO UPDATE SET
+		"last_read_at" = now();`
+
+	if _, err := r.db.ExecContext(context.Background(), query, orderId, readerId); err != nil {
+		return fmt.Errorf("update last read failed: %v", err)
+	}
+	return nil
+}
+
+// FindUnread counts messages from the other side, admin counts messages of customers and customer counts replies of admins.
+// Internal notes are filtered like FindMessage, so customer never counts them
+func (r *messagesRepository) FindUnread(req *messages.UnreadFilter) ([]*messages.UnreadCount, error) {
+	query := `
+	SELECT
+		"m"."order_id",
+		"o"."user_id",
+		COUNT(*) AS "unread",
+		MAX("m"."created_at") AS "last_message_at"
+	FROM "order_messages" "m"
+		JOIN "orders" "o" ON "o"."id" = "m"."order_id"
+		LEFT JOIN "order_message_reads" "r" ON "r"."order_id" = "m"."order_id" AND "r"."user_id" = $2
+	WHERE ($1 = '' OR "o"."user_id" = $1)
+	AND "m"."is_admin" <> $3
+	AND ($3 OR NOT "m"."is_internal")
+	AND "m"."created_at" > COALESCE("r"."last_read_at", '-infinity'::TIMESTAMP)
+	GROUP BY "m"."order_id", "o"."user_id"
+	ORDER BY "last_message_at" DESC;`
+
+	counts := make([]*messages.UnreadCount, 0)
+	if err := r.db.Select(&counts, query, req.UserId, req.ReaderId, req.IsAdmin); err != nil {
+		return nil, fmt.Errorf("select unread messages failed: %v", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,59 @@
+package messagesUsecases
+
+import (
+	"github.com/NatthawutSK/ri-shop/modules/files"
+	"github.com/NatthawutSK/ri-shop/modules/messages"
+	"github.com/NatthawutSK/ri-shop/modules/messages/messagesRepositories"
+)
+
+type IMessagesUsecase interface {
+	FindMessage(req *messages.MessageFilter) ([]*messages.Message, error)
+	InsertMessage(req *messages.MessageReq) (*messages.Message, error)
+	FindUnread(req *messages.UnreadFilter) ([]*messages.UnreadCount, error)
+}
+
+type messagesUsecase struct {
+	messagesRepository messagesRepositories.IMessagesRepository
+}
+
+func MessagesUsecase(messagesRepository messagesRepositories.IMessagesRepository) IMessagesUsecase {
+	return &messagesUsecase{
+		messagesRepository: messagesRepository,
+	}
+}
+
+// FindMessage returns the thread of the order and marks it as read for the reader
+func (u *messagesUsecase) FindMessage(req *messages.MessageFilter) ([]*messages.Message, error) {
+	messagesData, err := u.messagesRepository.FindMessage(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.messagesRepository.ReadMessage(req.OrderId, req.ReaderId); err != nil {
+		return nil, err
+	}
+	return messagesData, nil
+}
+
+func (u *messagesUsecase) InsertMessage(req *messages.MessageReq) (*messages.Message, error) {
+	// only admin can write an internal note
+	if !req.IsAdmin {
+		req.IsInternal = false
+	}
+	if req.Attachments == nil {
+		req.Attachments = make([]*files.FileRes, 0)
+	}
+
+	messageId, err := u.messagesRepository.InsertMessage(req)
+	if err != nil {
+		return nil, err
+	}
+	// sender has read the thread up to their own message
+	if err := u.messagesRepository.ReadMessage(req.OrderId, req.SenderId); err != nil {
+		return nil, err
+	}
+	return u.messagesRepository.FindOneMessage(messageId)
+}
+
+func (u *messagesUsecase) FindUnread(req *messages.UnreadFilter) ([]*messages.UnreadCount, error) {
+	return u.messagesRepository.FindUnread(req)
+}
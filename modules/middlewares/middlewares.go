@@ -4,3 +4,16 @@ type Role struct {
 	Id    int    `json:"id" db:"id"`
 	Title string `json:"title" db:"title"`
 }
+
+// IdempotencyKey keeps the first response of a key, retries with the same key get this response again
+type IdempotencyKey struct {
+	Id           string `json:"id" db:"id"`
+	Key          string `json:"key" db:"key"`
+	UserId       string `json:"user_id" db:"user_id"`
+	Method       string `json:"method" db:"method"`
+	Path         string `json:"path" db:"path"`
+	RequestHash  string `json:"request_hash" db:"request_hash"`
+	StatusCode   *int   `json:"status_code" db:"status_code"` // empty while the first request is running
+	ContentType  string `json:"content_type" db:"content_type"`
+	ResponseBody string `json:"response_body" db:"response_body"`
+}
@@ -1,12 +1,16 @@
 package middlewaresHandlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/NatthawutSK/ri-shop/config"
 	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/middlewares"
 	"github.com/NatthawutSK/ri-shop/modules/middlewares/middlewaresUsecases"
 	riAuth "github.com/NatthawutSK/ri-shop/pkg/riauth"
 	"github.com/NatthawutSK/ri-shop/pkg/utils"
@@ -24,6 +28,7 @@ const (
 	paramsCheckErr middlewareHandlersErrCode = "middleware-003"
 	authorizeErr   middlewareHandlersErrCode = "middleware-004"
 	apiKeyErr      middlewareHandlersErrCode = "middleware-005"
+	idempotencyErr middlewareHandlersErrCode = "middleware-006"
 )
 
 type IMiddlewaresHandler interface {
@@ -35,6 +40,7 @@ type IMiddlewaresHandler interface {
 	Authorize(expectRoleId ...int) fiber.Handler
 	ApiKeyAuth() fiber.Handler
 	StreamingFile() fiber.Handler
+	Idempotency() fiber.Handler
 }
 
 type middlewaresHandler struct {
@@ -198,3 +204,102 @@ func (h *middlewaresHandler) ApiKeyAuth() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// Idempotency replays the first response when a request is sent again with the same Idempotency-Key header,
+// it must be after JwtAuth on routes that need login so the key belongs to the user,
+// keys of anonymous requests belong to the client ip so one client can't replay the response of another
+func (h *middlewaresHandler) Idempotency() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := strings.Trim(c.Get("Idempotency-Key"), " ")
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(idempotencyErr),
+				"idempotency key must not be longer than 255 characters",
+			).Res()
+		}
+
+		userId, _ := c.Locals("userId").(string)
+		if userId == "" {
+			userId = fmt.Sprintf("anonymous:%s", c.IP())
+		}
+
+		// method and path are in the hash, so the same key can't be used on another route
+		hash := sha256.New()
+		hash.Write([]byte(c.Method()))
+		hash.Write([]byte(c.Path()))
+		hash.Write(c.Body())
+
+		req := &middlewares.IdempotencyKey{
+			Key:         key,
+			UserId:      userId,
+			Method:      c.Method(),
+			Path:        c.Path(),
+			RequestHash: hex.EncodeToString(hash.Sum(nil)),
+		}
+
+		isNew, err := h.middlewaresUsecase.InsertIdempotencyKey(req)
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(idempotencyErr),
+				err.Error(),
+			).Res()
+		}
+
+		if !isNew {
+			saved, err := h.middlewaresUsecase.FindOneIdempotencyKey(userId, key)
+			if err != nil {
+				return entities.NewResponse(c).Error(
+					fiber.ErrInternalServerError.Code,
+					string(idempotencyErr),
+					err.Error(),
+				).Res()
+			}
+			if saved.RequestHash != req.RequestHash {
+				return entities.NewResponse(c).Error(
+					fiber.ErrConflict.Code,
+					string(idempotencyErr),
+					"idempotency key has been used with another request",
+				).Res()
+			}
+			if saved.StatusCode == nil {
+				return entities.NewResponse(c).Error(
+					fiber.ErrConflict.Code,
+					string(idempotencyErr),
+					"request with this idempotency key is in progress",
+				).Res()
+			}
+
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, saved.ContentType)
+			return c.Status(*saved.StatusCode).SendString(saved.ResponseBody)
+		}
+
+		if err := c.Next(); err != nil {
+			// error is not a response yet, the request can be tried again
+			h.middlewaresUsecase.DeleteIdempotencyKey(req.Id)
+			return err
+		}
+
+		// server errors are not kept, so the retry is run again
+		statusCode := c.Response().StatusCode()
+		if statusCode >= fiber.StatusInternalServerError {
+			if err := h.middlewaresUsecase.DeleteIdempotencyKey(req.Id); err != nil {
+				log.Printf("delete idempotency key failed: %v\n", err)
+			}
+			return nil
+		}
+
+		req.StatusCode = &statusCode
+		req.ContentType = string(c.Response().Header.ContentType())
+		req.ResponseBody = string(c.Response().Body())
+		if err := h.middlewaresUsecase.UpdateIdempotencyKey(req); err != nil {
+			log.Printf("save idempotency key failed: %v\n", err)
+		}
+		return nil
+	}
+}
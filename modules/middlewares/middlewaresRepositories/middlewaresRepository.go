@@ -1,6 +1,7 @@
 package middlewaresRepositories
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/NatthawutSK/ri-shop/modules/middlewares"
@@ -10,6 +11,10 @@ import (
 type IMiddlewaresRepository interface {
 	FindAccessToken(userId, accessToken string) bool
 	FindRole() ([]*middlewares.Role, error)
+	InsertIdempotencyKey(req *middlewares.IdempotencyKey) (bool, error)
+	FindOneIdempotencyKey(userId, key string) (*middlewares.IdempotencyKey, error)
+	UpdateIdempotencyKey(req *middlewares.IdempotencyKey) error
+	DeleteIdempotencyKey(id string) error
 }
 
 type middlewaresRepository struct {
@@ -53,4 +58,91 @@ func (r *middlewaresRepository) FindRole() ([]*middlewares.Role, error) {
 		return nil, fmt.Errorf("role are empty")
 	}
 	return roles, nil
-}
\ No newline at end of file
+}
+
+// InsertIdempotencyKey returns false when the key is already used, a key older than 24 hours can be used again
+func (r *middlewaresRepository) InsertIdempotencyKey(req *middlewares.IdempotencyKey) (bool, error) {
+	query := `
+	INSERT INTO "idempotency_keys" (
+		"key",
+		"user_id",
+		"method",
+		"path",
+		"request_hash"
+	)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT ("user_id", "key") DO UPDATE SET
+		"method" = EXCLUDED."method",
+		"path" = EXCLUDED."path",
+		"request_hash" = EXCLUDED."request_hash",
+		"status_code" = NULL,
+		"content_type" = '',
+		"response_body" = '',
+		"created_at" = now()
+	WHERE "idempotency_keys"."created_at" < now() - INTERVAL '24 hours'
+	RETURNING "id";`
+
+	err := r.db.QueryRowx(
+		query,
+		req.Key,
+		req.UserId,
+		req.Method,
+		req.Path,
+		req.RequestHash,
+	).Scan(&req.Id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("insert idempotency key failed: %v", err)
+	}
+	return true, nil
+}
+
+func (r *middlewaresRepository) FindOneIdempotencyKey(userId, key string) (*middlewares.IdempotencyKey, error) {
+	query := `
+	SELECT
+		"id",
+		"key",
+		"user_id",
+		"method",
+		"path",
+		"request_hash",
+		"status_code",
+		"content_type",
+		"response_body"
+	FROM "idempotency_keys"
+	WHERE "user_id" = $1
+	AND "key" = $2;`
+
+	idempotencyKey := new(middlewares.IdempotencyKey)
+	if err := r.db.Get(idempotencyKey, query, userId, key); err != nil {
+		return nil, fmt.Errorf("idempotency key not found")
+	}
+	return idempotencyKey, nil
+}
+
+func (r *middlewaresRepository) UpdateIdempotencyKey(req *middlewares.IdempotencyKey) error {
+	query := `
+	UPDATE "idempotency_keys" SET
+		"status_code" = $1,
+		"content_type" = $2,
+		"response_body" = $3
+	WHERE "id" = $4;`
+
+	if _, err := r.db.Exec(query, req.StatusCode, req.ContentType, req.ResponseBody, req.Id); err != nil {
+		return fmt.Errorf("update idempotency key failed: %v", err)
+	}
+	return nil
+}
+
+func (r *middlewaresRepository) DeleteIdempotencyKey(id string) error {
+	query := `
+	DELETE FROM "idempotency_keys"
+	WHERE "id" = $1;`
+
+	if _, err := r.db.Exec(query, id); err != nil {
+		return fmt.Errorf("delete idempotency key failed: %v", err)
+	}
+	return nil
+}
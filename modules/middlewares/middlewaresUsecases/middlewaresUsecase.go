@@ -8,6 +8,10 @@ import (
 type IMiddlewaresUsecase interface {
 	FindAccessToken(userId, accessToken string) bool
 	FindRole() ([]*middlewares.Role, error)
+	InsertIdempotencyKey(req *middlewares.IdempotencyKey) (bool, error)
+	FindOneIdempotencyKey(userId, key string) (*middlewares.IdempotencyKey, error)
+	UpdateIdempotencyKey(req *middlewares.IdempotencyKey) error
+	DeleteIdempotencyKey(id string) error
 }
 
 type middlewaresUsecase struct {
@@ -30,4 +34,20 @@ func (u *middlewaresUsecase) FindRole() ([]*middlewares.Role, error) {
 		return nil, err
 	}
 	return role, nil
-}
\ No newline at end of file
+}
+
+func (u *middlewaresUsecase) InsertIdempotencyKey(req *middlewares.IdempotencyKey) (bool, error) {
+	return u.middlewareRepository.InsertIdempotencyKey(req)
+}
+
+func (u *middlewaresUsecase) FindOneIdempotencyKey(userId, key string) (*middlewares.IdempotencyKey, error) {
+	return u.middlewareRepository.FindOneIdempotencyKey(userId, key)
+}
+
+func (u *middlewaresUsecase) UpdateIdempotencyKey(req *middlewares.IdempotencyKey) error {
+	return u.middlewareRepository.UpdateIdempotencyKey(req)
+}
+
+func (u *middlewaresUsecase) DeleteIdempotencyKey(id string) error {
+	return u.middlewareRepository.DeleteIdempotencyKey(id)
+}
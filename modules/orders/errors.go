@@ -0,0 +1,52 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of order errors the client can fix, they are matched with errors.Is
+var (
+	ErrOutOfStock = errors.New("out of stock")
+	ErrCoupon     = errors.New("coupon is invalid")
+	ErrVariant    = errors.New("variant is invalid")
+	ErrAddress    = errors.New("address is invalid")
+	ErrCurrency   = errors.New("currency is invalid")
+	ErrBuyer      = errors.New("buyer is invalid")
+	ErrItem       = errors.New("item is invalid")
+	ErrStatus     = errors.New("status does not allow the change")
+	ErrChanged    = errors.New("order has been changed")
+)
+
+// InvalidError keeps the message of Err and tells errors.Is which kind of error it is
+type InvalidError struct {
+	Kind error
+	Err  error
+}
+
+func (e *InvalidError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Invalid marks err as a mistake of the order the client can fix
+func Invalid(kind error, err error) error {
+	return &InvalidError{
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+// Invalidf is Invalid with a new message
+func Invalidf(kind error, format string, args ...any) error {
+	return Invalid(kind, fmt.Errorf(format, args...))
+}
+
+// IsInvalid reports whether err or an error it wraps is an InvalidError, the handlers answer it with 400
+func IsInvalid(err error) bool {
+	var invalid *InvalidError
+	return errors.As(err, &invalid)
+}
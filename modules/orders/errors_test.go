@@ -0,0 +1,64 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type testInvalid struct {
+	name      string
+	err       error
+	isInvalid bool
+	kind      error
+	expected  string
+}
+
+func TestIsInvalid(t *testing.T) {
+	tests := []testInvalid{
+		{
+			name:      "new message",
+			err:       Invalidf(ErrOutOfStock, "product %s is out of stock", "P000001"),
+			isInvalid: true,
+			kind:      ErrOutOfStock,
+			expected:  "product P000001 is out of stock",
+		},
+		{
+			name:      "error of another module keeps its message",
+			err:       Invalid(ErrCoupon, fmt.Errorf("coupon SALE is expired")),
+			isInvalid: true,
+			kind:      ErrCoupon,
+			expected:  "coupon SALE is expired",
+		},
+		{
+			name:      "wrapped by a caller",
+			err:       fmt.Errorf("checkout: %w", Invalidf(ErrChanged, "cart has been changed, please try again")),
+			isInvalid: true,
+			kind:      ErrChanged,
+			expected:  "checkout: cart has been changed, please try again",
+		},
+		{
+			name:     "server error",
+			err:      fmt.Errorf("get product stock: %w", errors.New("connection refused")),
+			expected: "get product stock: connection refused",
+		},
+	}
+
+	for _, test := range tests {
+		if IsInvalid(test.err) != test.isInvalid {
+			t.Errorf("%s expected: %v, got: %v", test.name, test.isInvalid, IsInvalid(test.err))
+		}
+		if test.kind != nil && !errors.Is(test.err, test.kind) {
+			t.Errorf("%s expected: %v, got: %v", test.name, test.kind, test.err)
+		}
+		if test.err.Error() != test.expected {
+			t.Errorf("%s expected: %v, got: %v", test.name, test.expected, test.err.Error())
+		}
+	}
+
+	// the wrapped error is still found
+	inner := errors.New("variant is required for product P000001")
+	if err := Invalid(ErrVariant, inner); !errors.Is(err, inner) || errors.Is(err, ErrCoupon) {
+		t.Errorf("expected: %v and not %v, got: %v", inner, ErrCoupon, err)
+	}
+}
@@ -1,21 +1,66 @@
 package orders
 
 import (
+	"regexp"
+
 	"github.com/NatthawutSK/ri-shop/modules/entities"
 	"github.com/NatthawutSK/ri-shop/modules/products"
+	"github.com/NatthawutSK/ri-shop/modules/shipments"
+	"github.com/NatthawutSK/ri-shop/modules/users"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
 )
 
 type Order struct {
-	Id           string           `json:"id" db:"id"`
-	UserId       string           `json:"user_id" db:"user_id"`
-	TransferSlip *TransferSlip    `json:"transfer_slip" db:"transfer_slip"`
-	Products     []*ProductsOrder `json:"products"`
-	Address      string           `json:"address" db:"address"`
-	Contact      string           `json:"contact" db:"contact"`
-	Status       string           `json:"status" db:"status"`
-	TotalPaid    float64          `json:"total_paid" db:"total_paid"`
-	CreatedAt    string           `json:"created_at" db:"created_at"`
-	UpdatedAt    string           `json:"updated_at" db:"updated_at"`
+	Id            string           `json:"id" db:"id"`
+	UserId        string           `json:"user_id" db:"user_id"`
+	TransferSlip  *TransferSlip    `json:"transfer_slip" db:"transfer_slip"`
+	Products      []*ProductsOrder `json:"products"`
+	Address       string           `json:"address" db:"address"`
+	AddressId     string           `json:"address_id" db:"address_id"`
+	Contact       string           `json:"contact" db:"contact"`
+	Status        string           `json:"status" db:"status"`
+	CouponCode    string           `json:"coupon_code" db:"coupon_code"`
+	Subtotal      riMoney.Money    `json:"subtotal" db:"subtotal"`
+	Discount      riMoney.Money    `json:"discount" db:"discount"`
+	ShippingFee   riMoney.Money    `json:"shipping_fee" db:"shipping_fee"`
+	Tax           riMoney.Money    `json:"tax" db:"tax"`
+	TotalPaid     riMoney.Money    `json:"total_paid" db:"total_paid"`
+	Refunded      riMoney.Money    `json:"refunded" db:"refunded"`
+	BuyerTaxId    string           `json:"buyer_tax_id" db:"buyer_tax_id"`
+	BuyerBranch   string           `json:"buyer_branch" db:"buyer_branch"` // 00000 is head office
+	Vat           *OrderVat        `json:"vat"`
+	Currency      string           `json:"currency" db:"currency"`             // currency the customer checked out with, amounts above are baht
+	ExchangeRate  riMoney.Rate     `json:"exchange_rate" db:"exchange_rate"`   // baht of 1 unit of the currency at checkout
+	CurrencyTotal riMoney.Money    `json:"currency_total" db:"currency_total"` // total_paid in the currency
+	CreatedAt     string           `json:"created_at" db:"created_at"`
+	UpdatedAt     string           `json:"updated_at" db:"updated_at"`
+	CartId        string           `json:"-"` // set when the order is checked out from a cart
+	Payment       *OrderPayment    `json:"payment"`
+
+	ShippingAddress *users.UserAddress    `json:"shipping_address"` // copy of the saved address when the order is placed
+	StatusHistory   []*OrderStatusHistory `json:"status_history,omitempty"`
+	ItemChanges     []*OrderItemChange    `json:"item_changes,omitempty"`
+	Shipments       []*shipments.Shipment `json:"shipments"`
+}
+
+// CheckBuyer validates the buyer tax id for the tax invoice, branch is head office when it is empty
+func (o *Order) CheckBuyer() error {
+	if o.BuyerTaxId == "" {
+		if o.BuyerBranch != "" {
+			return Invalidf(ErrBuyer, "buyer_tax_id is required with buyer_branch")
+		}
+		return nil
+	}
+	if match, _ := regexp.MatchString(`^\d{13}$`, o.BuyerTaxId); !match {
+		return Invalidf(ErrBuyer, "buyer_tax_id must be 13 digits")
+	}
+	if o.BuyerBranch == "" {
+		o.BuyerBranch = "00000"
+	}
+	if match, _ := regexp.MatchString(`^\d{5}$`, o.BuyerBranch); !match {
+		return Invalidf(ErrBuyer, "buyer_branch must be 5 digits")
+	}
+	return nil
 }
 
 type TransferSlip struct {
@@ -25,13 +70,116 @@ type TransferSlip struct {
 	CreatedAt string `json:"created_at"`
 }
 
+// OrderPayment is the review result of the transfer slip
+type OrderPayment struct {
+	Status     string  `json:"status"` // unpaid, pending_review, approved, rejected
+	Note       *string `json:"note"`   // reason when the slip is rejected
+	ReviewedBy *string `json:"reviewed_by"`
+	ReviewedAt *string `json:"reviewed_at"`
+
+	PromptPayPayload *string        `json:"promptpay_payload"` // last generated QR, used to match incoming payments
+	PromptPayAmount  *riMoney.Money `json:"promptpay_amount"`
+}
+
+// PromptPay is the QR code customer scans to pay an order
+type PromptPay struct {
+	OrderId string        `json:"order_id"`
+	Payload string        `json:"payload"`
+	Amount  riMoney.Money `json:"amount"`
+	QrCode  string        `json:"qr_code"` // base64 PNG
+	Png     []byte        `json:"-"`
+}
+
+type PaymentReviewReq struct {
+	OrderId    string `json:"-"`
+	IsApproved bool   `json:"-"`
+	Reason     string `json:"reason" form:"reason"`
+	ReviewedBy string `json:"-"`
+}
+
+// Invoice number is given once per order, so the same invoice is rendered every time
+type Invoice struct {
+	Id           string  `json:"id" db:"id"`
+	InvoiceNo    string  `json:"invoice_no" db:"invoice_no"`
+	TaxInvoiceNo *string `json:"tax_invoice_no" db:"tax_invoice_no"` // empty when the order has no VAT
+	OrderId      string  `json:"order_id" db:"order_id"`
+	CreatedAt    string  `json:"created_at" db:"created_at"`
+	Pdf          []byte  `json:"-"`
+}
+
 type ProductsOrder struct {
-	Id      string             `json:"id" db:"id"`
-	Qty     int                `json:"qty" db:"qty"`
-	Product *products.Products `json:"product" db:"product"`
+	Id        string             `json:"id" db:"id"`
+	Qty       int                `json:"qty" db:"qty"`
+	Product   *products.Products `json:"product" db:"product"`
+	VariantId *string            `json:"variant_id" db:"variant_id"` // required when the product has variants, the snapshot keeps the variant in product.variant
+	Vat       *LineVat           `json:"vat"`
+}
+
+// OrderVat is the VAT breakdown of the order, vat is the same as "tax" of the order
+type OrderVat struct {
+	Rate         float64       `json:"rate"`
+	Inclusive    bool          `json:"inclusive"`
+	Net          riMoney.Money `json:"net"`
+	Vat          riMoney.Money `json:"vat"`
+	Gross        riMoney.Money `json:"gross"`
+	TaxInvoiceNo *string       `json:"tax_invoice_no"`
+}
+
+// LineVat is the VAT of one order line after its part of the discount
+type LineVat struct {
+	Discount riMoney.Money `json:"discount"`
+	Net      riMoney.Money `json:"net"`
+	Vat      riMoney.Money `json:"vat"`
+	Gross    riMoney.Money `json:"gross"`
+}
+
+// OrderQuote is the price breakdown of an order, every price comes from the database
+type OrderQuote struct {
+	Lines       []*QuoteLine  `json:"lines"`
+	CouponCode  string        `json:"coupon_code,omitempty"`
+	Subtotal    riMoney.Money `json:"subtotal"`
+	Discount    riMoney.Money `json:"discount"`
+	ShippingFee riMoney.Money `json:"shipping_fee"`
+	Tax         riMoney.Money `json:"tax"`
+	Total       riMoney.Money `json:"total"`
+	Vat         *OrderVat     `json:"vat"`
+
+	Currency *QuoteCurrency `json:"currency,omitempty"` // amounts in the currency of the request, baht when no currency is sent
+}
+
+// QuoteCurrency is the quote converted from baht, every amount is converted on its own with the rounding of the currency
+type QuoteCurrency struct {
+	Code        string               `json:"code"`
+	Symbol      string               `json:"symbol"`
+	Rate        riMoney.Rate         `json:"rate"`
+	Lines       []*QuoteLineCurrency `json:"lines"`
+	Subtotal    riMoney.Money        `json:"subtotal"`
+	Discount    riMoney.Money        `json:"discount"`
+	ShippingFee riMoney.Money        `json:"shipping_fee"`
+	Tax         riMoney.Money        `json:"tax"`
+	Total       riMoney.Money        `json:"total"`
+}
+
+type QuoteLineCurrency struct {
+	ProductId string        `json:"product_id"`
+	VariantId *string       `json:"variant_id,omitempty"`
+	UnitPrice riMoney.Money `json:"unit_price"`
+	Total     riMoney.Money `json:"total"`
+}
+
+type QuoteLine struct {
+	ProductId string        `json:"product_id"`
+	VariantId *string       `json:"variant_id,omitempty"`
+	Sku       string        `json:"sku,omitempty"`
+	Title     string        `json:"title"`
+	UnitPrice riMoney.Money `json:"unit_price"`
+	Qty       int           `json:"qty"`
+	Total     riMoney.Money `json:"total"`
+	Vat       *LineVat      `json:"vat"`
 }
 
 type OrderFilter struct {
+	UserId    string `query:"-"`      // set from params on customer listing, never from query
 	Search    string `query:"search"` // user_id, address, contact
 	Status    string `query:"status"`
 	StartDate string `query:"start_date"`
@@ -40,8 +188,106 @@ type OrderFilter struct {
 	*entities.SortReq
 }
 
+// OrderExportRow is one line of an order in the export, order columns are repeated on every line
+type OrderExportRow struct {
+	OrderId       string        `db:"order_id"`
+	UserId        string        `db:"user_id"`
+	Status        string        `db:"status"`
+	PaymentStatus string        `db:"payment_status"`
+	Contact       string        `db:"contact"`
+	Address       string        `db:"address"`
+	CouponCode    string        `db:"coupon_code"`
+	Subtotal      riMoney.Money `db:"subtotal"`
+	Discount      riMoney.Money `db:"discount"`
+	ShippingFee   riMoney.Money `db:"shipping_fee"`
+	Tax           riMoney.Money `db:"tax"`
+	TotalPaid     riMoney.Money `db:"total_paid"`
+	Refunded      riMoney.Money `db:"refunded"`
+	CreatedAt     string        `db:"created_at"`
+	ProductId     string        `db:"product_id"`
+	Title         string        `db:"title"`
+	UnitPrice     riMoney.Money `db:"unit_price"`
+	Qty           int           `db:"qty"`
+	LineTotal     riMoney.Money `db:"line_total"`
+}
+
 type OrderUpdate struct {
 	Id           string        `json:"id" db:"id"`
-	TransferSlip *TransferSlip `json:"transfer_slip" db:"transfer_slip"`
+	UserId       string        `json:"-"`
+	TransferSlip *TransferSlip `json:"transfer_slip" db:"transfer_slip"` // not accepted anymore, the slip is uploaded as a file
 	Status       string        `json:"status" db:"status"`
+	UpdatedBy    string        `json:"-"`
+	IsAdmin      bool          `json:"-"`
+	Note         string        `json:"-"` // reason of the status change
+	UnpaidOnly   bool          `json:"-"` // the order is not changed when a transfer slip has been uploaded
+}
+
+// OrderItemsUpdate replaces the lines of a waiting order, a line that is not sent is removed
+type OrderItemsUpdate struct {
+	Id         string           `json:"-"`
+	UserId     string           `json:"-"`
+	Products   []*ProductsOrder `json:"products"`
+	Note       string           `json:"note"`
+	UpdatedBy  string           `json:"-"`
+	CouponCode string           `json:"-"` // coupon of the order when it was priced again
+	Quote      *OrderQuote      `json:"-"`
+}
+
+// OrderItemChange is one edit of the order lines
+type OrderItemChange struct {
+	Id          string           `json:"id"`
+	ChangedBy   *string          `json:"changed_by"`
+	Note        *string          `json:"note"`
+	ItemsBefore []*OrderItemLine `json:"items_before"`
+	ItemsAfter  []*OrderItemLine `json:"items_after"`
+	TotalBefore riMoney.Money    `json:"total_before"`
+	TotalAfter  riMoney.Money    `json:"total_after"`
+	CreatedAt   string           `json:"created_at"`
+}
+
+type OrderItemLine struct {
+	ProductId string        `json:"product_id"`
+	Sku       string        `json:"sku,omitempty"`
+	Title     string        `json:"title"`
+	UnitPrice riMoney.Money `json:"unit_price"`
+	Qty       int           `json:"qty"`
+}
+
+// ExpiredOrder is a waiting order which is not paid in time
+type ExpiredOrder struct {
+	Id     string `db:"id"`
+	UserId string `db:"user_id"`
+}
+
+type OrderStatusHistory struct {
+	Id         string  `json:"id" db:"id"`
+	FromStatus *string `json:"from_status" db:"from_status"` // null when the order is created
+	ToStatus   string  `json:"to_status" db:"to_status"`
+	ChangedBy  *string `json:"changed_by" db:"changed_by"` // null when it is changed by the system
+	Note       *string `json:"note" db:"note"`
+	CreatedAt  string  `json:"created_at" db:"created_at"`
+}
+
+// StatusTransitions maps the current status to the statuses an order can move to
+var StatusTransitions = map[string][]string{
+	"waiting":   {"shipping", "canceled"},
+	"shipping":  {"completed"},
+	"completed": {},
+	"canceled":  {},
+	// return statuses are only changed by the returns module
+	"return_requested": {},
+	"refunded":         {},
+}
+
+// CanChangeStatus checks the transition table, customer can only cancel their order
+func CanChangeStatus(from, to string, isAdmin bool) bool {
+	if !isAdmin && to != "canceled" {
+		return false
+	}
+	for _, next := range StatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
 }
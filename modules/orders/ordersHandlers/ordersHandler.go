@@ -1,15 +1,25 @@
 package ordersHandlers
 
 import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/NatthawutSK/ri-shop/config"
 	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/files"
+	"github.com/NatthawutSK/ri-shop/modules/files/filesUsecases"
 	"github.com/NatthawutSK/ri-shop/modules/orders"
 	"github.com/NatthawutSK/ri-shop/modules/orders/ordersUsecases"
+	"github.com/NatthawutSK/ri-shop/pkg/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
 )
 
 type ordersHandlerErrCode string
@@ -19,24 +29,43 @@ const (
 	findOrderErr    ordersHandlerErrCode = "orders-002"
 	insertOrderErr  ordersHandlerErrCode = "orders-003"
 	updateOrderErr  ordersHandlerErrCode = "orders-004"
+	orderStatusErr  ordersHandlerErrCode = "orders-005"
+	quoteOrderErr   ordersHandlerErrCode = "orders-006"
+	uploadSlipErr   ordersHandlerErrCode = "orders-007"
+	reviewSlipErr   ordersHandlerErrCode = "orders-008"
+	promptPayErr    ordersHandlerErrCode = "orders-009"
+	invoiceErr      ordersHandlerErrCode = "orders-010"
+	exportOrderErr  ordersHandlerErrCode = "orders-011"
+	updateItemsErr  ordersHandlerErrCode = "orders-012"
 )
 
 type IOrdersHandler interface {
 	FindOneOrder(c *fiber.Ctx) error
 	FindOrder(c *fiber.Ctx) error
+	FindMyOrder(c *fiber.Ctx) error
+	ExportOrder(c *fiber.Ctx) error
 	InsertOrder(c *fiber.Ctx) error
 	UpdateOrder(c *fiber.Ctx) error
+	UpdateOrderItems(c *fiber.Ctx) error
+	QuoteOrder(c *fiber.Ctx) error
+	UploadTransferSlip(c *fiber.Ctx) error
+	ApprovePayment(c *fiber.Ctx) error
+	RejectPayment(c *fiber.Ctx) error
+	GeneratePromptPay(c *fiber.Ctx) error
+	GenerateInvoice(c *fiber.Ctx) error
 }
 
 type ordersHandler struct {
 	orderUsecase ordersUsecases.IOrdersUsecase
 	cfg          config.IConfig
+	fileUsecase  filesUsecases.IFilesUsecase
 }
 
-func OrdersHandler(orderUsecase ordersUsecases.IOrdersUsecase, cfg config.IConfig) IOrdersHandler {
+func OrdersHandler(orderUsecase ordersUsecases.IOrdersUsecase, cfg config.IConfig, fileUsecase filesUsecases.IFilesUsecase) IOrdersHandler {
 	return &ordersHandler{
 		orderUsecase: orderUsecase,
 		cfg:          cfg,
+		fileUsecase:  fileUsecase,
 	}
 }
 
@@ -52,6 +81,15 @@ func (h *ordersHandler) FindOneOrder(c *fiber.Ctx) error {
 		).Res()
 	}
 
+	// ParamsCheck only checks user_id, the order must belong to that user too
+	if order.UserId != strings.Trim(c.Params("user_id"), " ") {
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(findOneOrderErr),
+			"order not found",
+		).Res()
+	}
+
 	return entities.NewResponse(c).Success(
 		fiber.StatusOK,
 		order,
@@ -60,6 +98,16 @@ func (h *ordersHandler) FindOneOrder(c *fiber.Ctx) error {
 }
 
 func (h *ordersHandler) FindOrder(c *fiber.Ctx) error {
+	return h.findOrder(c, "")
+}
+
+// FindMyOrder lists orders of the user in params only
+func (h *ordersHandler) FindMyOrder(c *fiber.Ctx) error {
+	return h.findOrder(c, strings.Trim(c.Params("user_id"), " "))
+}
+
+// findOrder lists orders of every user when userId is empty
+func (h *ordersHandler) findOrder(c *fiber.Ctx, userId string) error {
 	req := &orders.OrderFilter{
 		SortReq:       &entities.SortReq{},
 		PaginationReq: &entities.PaginationReq{},
@@ -82,14 +130,212 @@ func (h *ordersHandler) FindOrder(c *fiber.Ctx) error {
 		req.Limit = 3
 	}
 
+	if err := checkOrderFilter(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findOrderErr),
+			err.Error(),
+		).Res()
+	}
+
+	// set หลัง parse เสมอ เพื่อไม่ให้ query string เปลี่ยน user ได้
+	req.UserId = userId
+
+	orders := h.orderUsecase.FindOrder(req)
+
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		orders,
+	).Res()
+}
+
+var exportHeader = []string{
+	"order_id",
+	"user_id",
+	"status",
+	"payment_status",
+	"contact",
+	"address",
+	"coupon_code",
+	"subtotal",
+	"discount",
+	"shipping_fee",
+	"tax",
+	"total_paid",
+	"refunded",
+	"created_at",
+	"product_id",
+	"title",
+	"unit_price",
+	"qty",
+	"line_total",
+}
+
+// ExportOrder streams every order line matching the filter, the rows are written while they are read from database
+func (h *ordersHandler) ExportOrder(c *fiber.Ctx) error {
+	req := &orders.OrderFilter{
+		SortReq:       &entities.SortReq{},
+		PaginationReq: &entities.PaginationReq{},
+	}
+
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportOrderErr),
+			err.Error(),
+		).Res()
+	}
+
+	if err := checkOrderFilter(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportOrderErr),
+			err.Error(),
+		).Res()
+	}
+
+	format := strings.ToLower(c.Query("format", "csv"))
+	filename := fmt.Sprintf("orders_%s.%s", time.Now().Format("20060102150405"), format)
+
+	switch format {
+	case "csv":
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			// BOM ให้ excel อ่านภาษาไทยได้
+			w.WriteString("\uFEFF")
+			writer := csv.NewWriter(w)
+			writer.Write(exportHeader)
+
+			count := 0
+			if err := h.orderUsecase.ExportOrder(req, func(row *orders.OrderExportRow) error {
+				if err := writer.Write(exportRecord(row)); err != nil {
+					return err
+				}
+				count++
+				if count%500 == 0 {
+					writer.Flush()
+					return w.Flush()
+				}
+				return nil
+			}); err != nil {
+				log.Printf("export orders failed: %v\n", err)
+			}
+			writer.Flush()
+			w.Flush()
+		})
+		return nil
+
+	case "xlsx":
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			// stream writer keeps the rows in a temp file instead of memory
+			file := excelize.NewFile()
+			defer file.Close()
+
+			sheet := "Sheet1"
+			sw, err := file.NewStreamWriter(sheet)
+			if err != nil {
+				log.Printf("export orders failed: %v\n", err)
+				return
+			}
+
+			header := make([]any, 0, len(exportHeader))
+			for _, col := range exportHeader {
+				header = append(header, col)
+			}
+			if err := sw.SetRow("A1", header); err != nil {
+				log.Printf("export orders failed: %v\n", err)
+				return
+			}
+
+			rowIndex := 1
+			if err := h.orderUsecase.ExportOrder(req, func(row *orders.OrderExportRow) error {
+				rowIndex++
+				cell, err := excelize.CoordinatesToCellName(1, rowIndex)
+				if err != nil {
+					return err
+				}
+				return sw.SetRow(cell, []any{
+					row.OrderId,
+					row.UserId,
+					row.Status,
+					row.PaymentStatus,
+					row.Contact,
+					row.Address,
+					row.CouponCode,
+					row.Subtotal.Float(),
+					row.Discount.Float(),
+					row.ShippingFee.Float(),
+					row.Tax.Float(),
+					row.TotalPaid.Float(),
+					row.Refunded.Float(),
+					row.CreatedAt,
+					row.ProductId,
+					row.Title,
+					row.UnitPrice.Float(),
+					row.Qty,
+					row.LineTotal.Float(),
+				})
+			}); err != nil {
+				log.Printf("export orders failed: %v\n", err)
+			}
+
+			if err := sw.Flush(); err != nil {
+				log.Printf("export orders failed: %v\n", err)
+				return
+			}
+			if err := file.Write(w); err != nil {
+				log.Printf("export orders failed: %v\n", err)
+			}
+			w.Flush()
+		})
+		return nil
+	}
+
+	return entities.NewResponse(c).Error(
+		fiber.ErrBadRequest.Code,
+		string(exportOrderErr),
+		"format must be csv or xlsx",
+	).Res()
+}
+
+func exportRecord(row *orders.OrderExportRow) []string {
+	return []string{
+		row.OrderId,
+		row.UserId,
+		row.Status,
+		row.PaymentStatus,
+		row.Contact,
+		row.Address,
+		row.CouponCode,
+		row.Subtotal.String(),
+		row.Discount.String(),
+		row.ShippingFee.String(),
+		row.Tax.String(),
+		row.TotalPaid.String(),
+		row.Refunded.String(),
+		row.CreatedAt,
+		row.ProductId,
+		row.Title,
+		row.UnitPrice.String(),
+		strconv.Itoa(row.Qty),
+		row.LineTotal.String(),
+	}
+}
+
+// checkOrderFilter normalizes order by, sort and dates of the filter shared by find and export
+func checkOrderFilter(req *orders.OrderFilter) error {
 	// order by
 	orderByMap := map[string]string{
 		"id":         `"o"."id"`,
 		"created_at": `"o"."created_at"`,
 	}
 	if orderByMap[req.OrderBy] == "" {
-		req.OrderBy = orderByMap["id"]
+		req.OrderBy = "id"
 	}
+	req.OrderBy = orderByMap[req.OrderBy]
 
 	// sort
 	req.Sort = strings.ToUpper(req.Sort)
@@ -105,32 +351,18 @@ func (h *ordersHandler) FindOrder(c *fiber.Ctx) error {
 	if req.StartDate != "" {
 		start, err := time.Parse("2006-01-02", req.StartDate)
 		if err != nil {
-			return entities.NewResponse(c).Error(
-				fiber.ErrBadRequest.Code,
-				string(findOrderErr),
-				"start date is invalid",
-			).Res()
+			return fmt.Errorf("start date is invalid")
 		}
 		req.StartDate = start.Format("2006-01-02")
 	}
 	if req.EndDate != "" {
 		end, err := time.Parse("2006-01-02", req.EndDate)
 		if err != nil {
-			return entities.NewResponse(c).Error(
-				fiber.ErrBadRequest.Code,
-				string(findOrderErr),
-				"end date is invalid",
-			).Res()
+			return fmt.Errorf("end date is invalid")
 		}
 		req.EndDate = end.Format("2006-01-02")
 	}
-
-	orders := h.orderUsecase.FindOrder(req)
-
-	return entities.NewResponse(c).Success(
-		fiber.StatusOK,
-		orders,
-	).Res()
+	return nil
 }
 
 func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
@@ -161,11 +393,22 @@ func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
 		req.UserId = userId
 	}
 
+	// currency can be sent in body or X-Currency header, the order is still paid in baht
+	if req.Currency == "" {
+		req.Currency = c.Get("X-Currency")
+	}
+
 	req.Status = "waiting"
-	req.TotalPaid = 0
 
 	order, err := h.orderUsecase.InsertOrder(req)
 	if err != nil {
+		if orders.IsInvalid(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertOrderErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(insertOrderErr),
@@ -192,6 +435,7 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 	}
 
 	req.Id = orderId
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
 
 	statusMap := map[string]string{
 		"waiting":   "waiting",
@@ -200,40 +444,235 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 		"canceled":  "canceled",
 	}
 
-	// ถ้าเป็น admin จะสามารถเปลี่ยนสถานะได้ทั้งหมด แต่ถ้าเป็น user จะสามารถเปลี่ยนเป็น canceled ได้เท่านั้น
-	if c.Locals("userRoleId").(int) == 2 {
+	if req.Status != "" {
 		req.Status = statusMap[strings.ToLower(req.Status)]
-	} else if strings.ToLower(req.Status) != statusMap["canceled"] {
-		req.Status = statusMap["canceled"]
+		if req.Status == "" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(orderStatusErr),
+				"status is invalid",
+			).Res()
+		}
 	}
 
+	// ถ้าเป็น admin จะเปลี่ยนสถานะได้ตาม orders.StatusTransitions แต่ถ้าเป็น user จะสามารถเปลี่ยนเป็น canceled ได้เท่านั้น
+	req.UpdatedBy = c.Locals("userId").(string)
+	req.IsAdmin = c.Locals("userRoleId").(int) == 2
+
+	// slip ต้อง upload เป็นไฟล์ที่ /orders/:user_id/:order_id/transfer-slip เพื่อให้ admin ตรวจสอบ
 	if req.TransferSlip != nil {
-		if req.TransferSlip.Id == "" {
-			req.TransferSlip.Id = uuid.NewString()
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateOrderErr),
+			"transfer slip must be uploaded to /orders/:user_id/:order_id/transfer-slip",
+		).Res()
+	}
+
+	order, err := h.orderUsecase.UpdateOrder(req)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateOrderErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "cannot change order status") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(orderStatusErr),
+				err.Error(),
+			).Res()
 		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateOrderErr),
+			err.Error(),
+		).Res()
+	}
 
-		if req.TransferSlip.CreatedAt == "" {
-			loc, err := time.LoadLocation("Asia/Bangkok")
-			if err != nil {
-				return entities.NewResponse(c).Error(
-					fiber.ErrInternalServerError.Code,
-					string(updateOrderErr),
-					err.Error(),
-				).Res()
-			}
-			now := time.Now().In(loc)
+	return entities.NewResponse(c).Success(
+		fiber.StatusCreated,
+		order,
+	).Res()
+}
+
+// UpdateOrderItems replaces the lines of a waiting order, a line that is not sent is removed
+func (h *ordersHandler) UpdateOrderItems(c *fiber.Ctx) error {
+	req := &orders.OrderItemsUpdate{
+		Products: make([]*orders.ProductsOrder, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateItemsErr),
+			err.Error(),
+		).Res()
+	}
 
-			// YYYY-MM-DD HH:MM:SS
-			// 2006-01-02 15:04:05
-			req.TransferSlip.CreatedAt = now.Format("2006-01-02 15:04:05")
+	if len(req.Products) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateItemsErr),
+			"products are empty, cancel the order instead",
+		).Res()
+	}
+
+	req.Id = strings.Trim(c.Params("order_id"), " ")
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	req.UpdatedBy = c.Locals("userId").(string)
+	req.Note = strings.Trim(req.Note, " ")
+
+	order, err := h.orderUsecase.UpdateOrderItems(req)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateItemsErr),
+				err.Error(),
+			).Res()
 		}
+		if errors.Is(err, orders.ErrChanged) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(updateItemsErr),
+				err.Error(),
+			).Res()
+		}
+		if orders.IsInvalid(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateItemsErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateItemsErr),
+			err.Error(),
+		).Res()
 	}
 
-	order, err := h.orderUsecase.UpdateOrder(req)
+	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
+}
+
+func (h *ordersHandler) QuoteOrder(c *fiber.Ctx) error {
+	req := &orders.Order{
+		Products: make([]*orders.ProductsOrder, 0),
+	}
+
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(quoteOrderErr),
+			err.Error(),
+		).Res()
+	}
+
+	if len(req.Products) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(quoteOrderErr),
+			"products are empty",
+		).Res()
+	}
+
+	req.UserId = c.Locals("userId").(string)
+	if req.Currency == "" {
+		req.Currency = c.Get("X-Currency")
+	}
+
+	quote, err := h.orderUsecase.QuoteOrder(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(quoteOrderErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		quote,
+	).Res()
+}
+
+func (h *ordersHandler) UploadTransferSlip(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(uploadSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	ext, err := files.CheckImage(file, h.cfg.App().FileLimit())
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(uploadSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	filename := utils.RandFileName(ext)
+	destination := fmt.Sprintf("transfer_slips/%s/%s", orderId, filename)
+	res, err := h.fileUsecase.UploadToGCP([]*files.FileReq{
+		{
+			File:        file,
+			Destination: destination,
+			FileName:    filename,
+			Extension:   ext,
+		},
+	})
 	if err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
-			string(updateOrderErr),
+			string(uploadSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(uploadSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	slip := &orders.TransferSlip{
+		Id:       uuid.NewString(),
+		FileName: res[0].FileName,
+		Url:      res[0].Url,
+		// YYYY-MM-DD HH:MM:SS
+		CreatedAt: time.Now().In(loc).Format("2006-01-02 15:04:05"),
+	}
+
+	order, err := h.orderUsecase.UploadTransferSlip(userId, orderId, slip)
+	if err != nil {
+		// the slip is not attached to any order, remove it
+		h.fileUsecase.DeleteFileOnGCP([]*files.DeleteFileReq{
+			{
+				Destination: destination,
+			},
+		})
+
+		if err.Error() == "order is not waiting for payment" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(uploadSlipErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(uploadSlipErr),
 			err.Error(),
 		).Res()
 	}
@@ -243,3 +682,114 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 		order,
 	).Res()
 }
+
+func (h *ordersHandler) ApprovePayment(c *fiber.Ctx) error {
+	return h.reviewPayment(c, true)
+}
+
+func (h *ordersHandler) RejectPayment(c *fiber.Ctx) error {
+	return h.reviewPayment(c, false)
+}
+
+func (h *ordersHandler) reviewPayment(c *fiber.Ctx, isApproved bool) error {
+	req := new(orders.PaymentReviewReq)
+	if err := c.BodyParser(req); err != nil && len(c.Body()) != 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.OrderId = strings.Trim(c.Params("order_id"), " ")
+	req.IsApproved = isApproved
+	req.ReviewedBy = c.Locals("userId").(string)
+	req.Reason = strings.Trim(req.Reason, " ")
+
+	if !req.IsApproved && req.Reason == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewSlipErr),
+			"reason is required",
+		).Res()
+	}
+
+	order, err := h.orderUsecase.ReviewPayment(req)
+	if err != nil {
+		if err.Error() == "order is not waiting for payment" || err.Error() == "transfer slip is not waiting for review" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(reviewSlipErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(reviewSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		order,
+	).Res()
+}
+
+// GeneratePromptPay returns the QR as json, or as an image when format=png
+func (h *ordersHandler) GeneratePromptPay(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	promptPay, err := h.orderUsecase.GeneratePromptPay(userId, orderId)
+	if err != nil {
+		switch err.Error() {
+		case "order is not waiting for payment", "order has nothing to pay", "promptpay is not available":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(promptPayErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(promptPayErr),
+			err.Error(),
+		).Res()
+	}
+
+	if strings.ToLower(c.Query("format")) == "png" {
+		c.Set(fiber.HeaderContentType, "image/png")
+		return c.Status(fiber.StatusOK).Send(promptPay.Png)
+	}
+
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		promptPay,
+	).Res()
+}
+
+func (h *ordersHandler) GenerateInvoice(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	invoice, err := h.orderUsecase.GenerateInvoice(userId, orderId)
+	if err != nil {
+		if err.Error() == "order not found" || err.Error() == "canceled order has no invoice" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(invoiceErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(invoiceErr),
+			err.Error(),
+		).Res()
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s.pdf"`, invoice.InvoiceNo))
+	return c.Status(fiber.StatusOK).Send(invoice.Pdf)
+}
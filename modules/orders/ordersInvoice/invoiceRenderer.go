@@ -0,0 +1,225 @@
+package ordersInvoice
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+	"github.com/go-pdf/fpdf"
+)
+
+type IInvoiceRenderer interface {
+	Render(order *orders.Order, invoice *orders.Invoice) ([]byte, error)
+}
+
+type invoiceRenderer struct {
+	cfg config.IConfig
+}
+
+func InvoiceRenderer(cfg config.IConfig) IInvoiceRenderer {
+	return &invoiceRenderer{
+		cfg: cfg,
+	}
+}
+
+// Render draws an A4 invoice/receipt, every line comes from the products snapshot of the order
+func (r *invoiceRenderer) Render(order *orders.Order, invoice *orders.Invoice) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetTitle(fmt.Sprintf("Invoice %s", invoice.InvoiceNo), true)
+
+	// core fonts only support latin text, thai text needs a utf-8 font from config
+	family := "Helvetica"
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	if r.cfg.Shop().InvoiceFont() != "" {
+		family = "invoice"
+		pdf.AddUTF8Font(family, "", r.cfg.Shop().InvoiceFont())
+		pdf.AddUTF8Font(family, "B", r.cfg.Shop().InvoiceFont())
+		tr = func(s string) string { return s }
+	}
+
+	pdf.AddPage()
+
+	// store
+	pdf.SetFont(family, "B", 16)
+	title := "INVOICE / RECEIPT"
+	if invoice.TaxInvoiceNo != nil {
+		title = "TAX INVOICE / RECEIPT"
+	}
+	pdf.CellFormat(110, 8, tr(r.cfg.Shop().Name()), "", 0, "L", false, 0, "")
+	pdf.CellFormat(70, 8, tr(title), "", 1, "R", false, 0, "")
+
+	pdf.SetFont(family, "", 10)
+	storeLines := make([]string, 0)
+	if r.cfg.Shop().Address() != "" {
+		storeLines = append(storeLines, r.cfg.Shop().Address())
+	}
+	if r.cfg.Shop().Phone() != "" {
+		storeLines = append(storeLines, fmt.Sprintf("Tel. %s", r.cfg.Shop().Phone()))
+	}
+	if r.cfg.Shop().TaxId() != "" {
+		storeLines = append(storeLines, fmt.Sprintf("Tax ID %s", r.cfg.Shop().TaxId()))
+	}
+	invoiceLines := []string{
+		fmt.Sprintf("No. %s", invoice.InvoiceNo),
+	}
+	if invoice.TaxInvoiceNo != nil {
+		invoiceLines = append(invoiceLines, fmt.Sprintf("Tax invoice No. %s", *invoice.TaxInvoiceNo))
+	}
+	invoiceLines = append(invoiceLines,
+		fmt.Sprintf("Date %s", formatDate(invoice.CreatedAt)),
+		fmt.Sprintf("Order %s", order.Id),
+	)
+	for i := 0; i < len(storeLines) || i < len(invoiceLines); i++ {
+		left, right := "", ""
+		if i < len(storeLines) {
+			left = storeLines[i]
+		}
+		if i < len(invoiceLines) {
+			right = invoiceLines[i]
+		}
+		pdf.CellFormat(110, 5, tr(left), "", 0, "L", false, 0, "")
+		pdf.CellFormat(70, 5, tr(right), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// customer
+	pdf.SetFont(family, "B", 11)
+	pdf.CellFormat(180, 6, tr("Bill to"), "", 1, "L", false, 0, "")
+	pdf.SetFont(family, "", 10)
+	pdf.MultiCell(180, 5, tr(order.Address), "", "L", false)
+	pdf.MultiCell(180, 5, tr(order.Contact), "", "L", false)
+	if order.BuyerTaxId != "" {
+		branch := "Head office"
+		if order.BuyerBranch != "" && order.BuyerBranch != "00000" {
+			branch = fmt.Sprintf("Branch %s", order.BuyerBranch)
+		}
+		pdf.MultiCell(180, 5, tr(fmt.Sprintf("Tax ID %s (%s)", order.BuyerTaxId, branch)), "", "L", false)
+	}
+	pdf.Ln(4)
+
+	// lines
+	widths := []float64{10, 95, 20, 27.5, 27.5}
+	pdf.SetFont(family, "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, header := range []string{"#", "Item", "Qty", "Unit price", "Amount"} {
+		align := "R"
+		if i == 1 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, tr(header), "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(family, "", 10)
+	for i, line := range order.Products {
+		title, price := "-", riMoney.Zero
+		if line.Product != nil {
+			title = line.Product.Title
+			price = line.Product.Price
+		}
+		pdf.CellFormat(widths[0], 7, fmt.Sprint(i+1), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[1], 7, truncate(pdf, tr, title, widths[1]-2), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 7, fmt.Sprint(line.Qty), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, money(price), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 7, money(price.Mul(line.Qty)), "1", 1, "R", false, 0, "")
+	}
+	pdf.Ln(2)
+
+	// totals
+	totals := [][2]string{
+		{"Subtotal", money(order.Subtotal)},
+	}
+	if order.Discount > 0 {
+		label := "Discount"
+		if order.CouponCode != "" {
+			label = fmt.Sprintf("Discount (%s)", order.CouponCode)
+		}
+		totals = append(totals, [2]string{label, fmt.Sprintf("-%s", money(order.Discount))})
+	}
+	if order.Vat != nil && order.Vat.Inclusive {
+		totals = append(totals,
+			[2]string{"Amount before VAT", money(order.Vat.Net)},
+			[2]string{fmt.Sprintf("VAT %s%% (included)", vatRate(order.Vat.Rate)), money(order.Tax)},
+			[2]string{"Shipping fee", money(order.ShippingFee)},
+		)
+	} else {
+		label := "Tax"
+		if order.Vat != nil {
+			label = fmt.Sprintf("VAT %s%%", vatRate(order.Vat.Rate))
+		}
+		totals = append(totals,
+			[2]string{"Shipping fee", money(order.ShippingFee)},
+			[2]string{label, money(order.Tax)},
+		)
+	}
+	for _, total := range totals {
+		pdf.CellFormat(125, 6, "", "", 0, "", false, 0, "")
+		pdf.CellFormat(27.5, 6, tr(total[0]), "", 0, "R", false, 0, "")
+		pdf.CellFormat(27.5, 6, total[1], "", 1, "R", false, 0, "")
+	}
+	pdf.SetFont(family, "B", 11)
+	pdf.CellFormat(125, 8, "", "", 0, "", false, 0, "")
+	pdf.CellFormat(27.5, 8, tr("Total"), "T", 0, "R", false, 0, "")
+	pdf.CellFormat(27.5, 8, money(order.TotalPaid), "T", 1, "R", false, 0, "")
+
+	if order.Payment != nil && order.Payment.Status == "approved" {
+		pdf.Ln(6)
+		pdf.SetTextColor(0, 128, 0)
+		pdf.CellFormat(180, 8, tr("PAID"), "", 1, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := pdf.Output(buf); err != nil {
+		return nil, fmt.Errorf("render invoice failed: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// money formats 1234.5 as 1,234.50
+func money(amount riMoney.Money) string {
+	s := riMoney.Max(amount, -amount).String()
+	intPart, decPart := s[:len(s)-3], s[len(s)-3:]
+
+	var b strings.Builder
+	for i, c := range intPart {
+		if i != 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteRune(',')
+		}
+		b.WriteRune(c)
+	}
+	if amount < 0 {
+		return "-" + b.String() + decPart
+	}
+	return b.String() + decPart
+}
+
+// vatRate formats 7 as 7 and 7.5 as 7.5
+func vatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// formatDate keeps only YYYY-MM-DD of a timestamp from database
+func formatDate(timestamp string) string {
+	if len(timestamp) < 10 {
+		return timestamp
+	}
+	return timestamp[:10]
+}
+
+// truncate cuts text which is wider than the cell, the result is already translated
+func truncate(pdf *fpdf.Fpdf, tr func(string) string, text string, width float64) string {
+	if pdf.GetStringWidth(tr(text)) <= width {
+		return tr(text)
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(tr(string(runes)+"...")) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return tr(string(runes) + "...")
+}
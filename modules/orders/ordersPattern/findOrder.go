@@ -15,11 +15,14 @@ import (
 type IFindOrderBuilder interface{
 	initQuery()
 	initCountQuery()
+	initExportQuery()
+	buildWhereUserId()
 	buildWhereSearch()
 	buildWhereStatus()
 	buildWhereDate()
 	buildSort()
 	buildPaginate()
+	buildExportSort()
 	closeQuery()
 	getQuery() string
 	setQuery(query string)
@@ -67,6 +70,15 @@ func (b *findOrderBuilder) initQuery() {
 			"o"."user_id",
 			"o"."transfer_slip",
 			"o"."status",
+			"o"."coupon_code",
+			json_build_object(
+				'status', "o"."payment_status",
+				'note', "o"."payment_note",
+				'reviewed_by', "o"."payment_reviewed_by",
+				'reviewed_at', "o"."payment_reviewed_at",
+				'promptpay_payload', "o"."promptpay_payload",
+				'promptpay_amount', "o"."promptpay_amount"
+			) AS "payment",
 			(
 				SELECT
 					array_to_json(array_agg("pt"))
@@ -74,19 +86,76 @@ func (b *findOrderBuilder) initQuery() {
 					SELECT
 						"spo"."id",
 						"spo"."qty",
-						"spo"."product"
+						"spo"."product",
+						"spo"."variant_id",
+						json_build_object(
+							'discount', "spo"."discount",
+							'net', "spo"."net",
+							'vat', "spo"."vat",
+							'gross', "spo"."gross"
+						) AS "vat"
 					FROM "products_orders" "spo"
 					WHERE "spo"."order_id" = "o"."id"
 				) AS "pt"
 			) AS "products",
-			"o"."address",
-			"o"."contact",
 			(
 				SELECT
-					SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0))
-				FROM "products_orders" "po"
-				WHERE "po"."order_id" = "o"."id"
-			) AS "total_paid",
+					COALESCE(array_to_json(array_agg("sht")), '[]'::json)
+				FROM (
+					SELECT
+						"s"."id",
+						"s"."carrier",
+						"s"."tracking_number",
+						"s"."shipped_at",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("sit")), '[]'::json)
+							FROM (
+								SELECT
+									"si"."id",
+									"si"."products_order_id",
+									"po"."product"->>'id' AS "product_id",
+									"po"."product"->>'title' AS "title",
+									"si"."qty"
+								FROM "shipments_items" "si"
+									JOIN "products_orders" "po" ON "po"."id" = "si"."products_order_id"
+								WHERE "si"."shipment_id" = "s"."id"
+							) AS "sit"
+						) AS "items",
+						"s"."created_at"
+					FROM "shipments" "s"
+					WHERE "s"."order_id" = "o"."id"
+					ORDER BY "s"."shipped_at" ASC, "s"."created_at" ASC
+				) AS "sht"
+			) AS "shipments",
+			"o"."address",
+			"o"."address_id",
+			"o"."shipping_address",
+			"o"."contact",
+			"o"."subtotal",
+			"o"."discount",
+			"o"."shipping_fee",
+			"o"."tax",
+			"o"."total_paid",
+			"o"."refunded",
+			"o"."buyer_tax_id",
+			"o"."buyer_branch",
+			"o"."currency",
+			"o"."exchange_rate",
+			"o"."currency_total",
+			json_build_object(
+				'rate', "o"."vat_rate",
+				'inclusive', "o"."vat_inclusive",
+				'net', "o"."net_amount",
+				'vat', "o"."tax",
+				'gross', "o"."gross_amount",
+				'tax_invoice_no', (
+					SELECT
+						"i"."tax_invoice_no"
+					FROM "invoices" "i"
+					WHERE "i"."order_id" = "o"."id"
+				)
+			) AS "vat",
 			"o"."created_at",
 			"o"."updated_at"
 		FROM "orders" "o"
@@ -108,6 +177,54 @@ func (b *findOrderBuilder) initCountQuery() {
 
 
 
+// initExportQuery selects one row per order line, the rows are not wrapped as json so they can be streamed
+func (b *findOrderBuilder) initExportQuery() {
+	b.query += `
+		SELECT
+			"o"."id" AS "order_id",
+			"o"."user_id",
+			"o"."status",
+			"o"."payment_status",
+			"o"."contact",
+			"o"."address",
+			COALESCE("o"."coupon_code", '') AS "coupon_code",
+			"o"."subtotal",
+			"o"."discount",
+			"o"."shipping_fee",
+			"o"."tax",
+			"o"."total_paid",
+			"o"."refunded",
+			"o"."created_at",
+			COALESCE("po"."product"->>'id', '') AS "product_id",
+			COALESCE("po"."product"->>'title', '') AS "title",
+			COALESCE(("po"."product"->>'price')::NUMERIC, 0) AS "unit_price",
+			"po"."qty",
+			COALESCE(("po"."product"->>'price')::NUMERIC, 0) * "po"."qty" AS "line_total"
+		FROM "orders" "o"
+			JOIN "products_orders" "po" ON "po"."order_id" = "o"."id"
+		WHERE 1 = 1`
+}
+
+// buildWhereUserId limits the orders to one customer
+func (b *findOrderBuilder) buildWhereUserId() {
+	if b.req.UserId != "" {
+		b.values = append(
+			b.values,
+			b.req.UserId,
+		)
+
+		query := fmt.Sprintf(`
+		AND "o"."user_id" = $%d`,
+			b.lastIndex+1,
+		)
+		temp := b.getQuery()
+		temp += query
+		b.setQuery(temp)
+
+		b.lastIndex = len(b.values)
+	}
+}
+
 func (b *findOrderBuilder) buildWhereSearch() {
 	if b.req.Search != "" {
 		b.values = append(
@@ -194,6 +311,16 @@ func (b *findOrderBuilder) buildSort() {
 	
 }
 
+// buildExportSort puts the column in the query because the order by is already checked by handler,
+// lines of the same order are kept together
+func (b *findOrderBuilder) buildExportSort() {
+	b.query += fmt.Sprintf(`
+	ORDER BY %s %s, "o"."id", "po"."id"`,
+		b.req.OrderBy,
+		b.req.Sort,
+	)
+}
+
 func (b *findOrderBuilder) buildPaginate() {
 	b.values = append(
 		b.values,
@@ -255,6 +382,7 @@ func (en *findOrderEngineer) FindOrder() []*orders.Order {
 
 
 	en.builder.initQuery()
+	en.builder.buildWhereUserId()
 	en.builder.buildWhereSearch()
 	en.builder.buildWhereStatus()
 	en.builder.buildWhereDate()
@@ -286,6 +414,7 @@ func (en *findOrderEngineer) CountOrder() int {
 
 
 	en.builder.initCountQuery()
+	en.builder.buildWhereUserId()
 	en.builder.buildWhereSearch()
 	en.builder.buildWhereStatus()
 	en.builder.buildWhereDate()
@@ -299,3 +428,34 @@ func (en *findOrderEngineer) CountOrder() int {
 	return count
 }
 
+// ExportOrder streams every matching order line to fn without pagination
+func (en *findOrderEngineer) ExportOrder(fn func(row *orders.OrderExportRow) error) error {
+	defer en.builder.resetQuery()
+
+	en.builder.initExportQuery()
+	en.builder.buildWhereUserId()
+	en.builder.buildWhereSearch()
+	en.builder.buildWhereStatus()
+	en.builder.buildWhereDate()
+	en.builder.buildExportSort()
+
+	rows, err := en.builder.getDb().Queryx(en.builder.getQuery(), en.builder.getValues()...)
+	if err != nil {
+		return fmt.Errorf("export orders failed: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := new(orders.OrderExportRow)
+		if err := rows.StructScan(row); err != nil {
+			return fmt.Errorf("scan order row failed: %v", err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("export orders failed: %v", err)
+	}
+	return nil
+}
@@ -2,17 +2,26 @@ package ordersPattern
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/NatthawutSK/ri-shop/modules/coupons"
 	"github.com/NatthawutSK/ri-shop/modules/orders"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersPricing"
+	"github.com/NatthawutSK/ri-shop/modules/users"
 	"github.com/jmoiron/sqlx"
 )
 
 type IInsertOrderBuilder interface {
 	initTransaction() error
+	snapshotAddress() error
 	insertOrder() error
 	insertProductsOrder() error
+	reserveStock() error
+	applyCoupon() error
+	insertStatusHistory() error
+	clearCart() error
 	getOrderId() string
 	commit() error
 }
@@ -56,6 +65,51 @@ func (b *insertOrderBuilder) getOrderId() string {
 }
 
 
+// snapshotAddress copies the saved address into the order, the default address is used when no address is sent
+func (b *insertOrderBuilder) snapshotAddress() error {
+	b.req.ShippingAddress = nil
+	if b.req.AddressId == "" && b.req.Address != "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT
+		"id",
+		"user_id",
+		"recipient",
+		"phone",
+		"line1",
+		"line2",
+		"subdistrict",
+		"district",
+		"province",
+		"postal_code",
+		"is_default",
+		"created_at",
+		"updated_at"
+	FROM "user_addresses"
+	WHERE "user_id" = $1
+	AND ("id"::TEXT = $2 OR ($2 = '' AND "is_default"));`
+
+	address := new(users.UserAddress)
+	if err := b.tx.GetContext(ctx, address, query, b.req.UserId, b.req.AddressId); err != nil {
+		b.tx.Rollback()
+		if b.req.AddressId == "" {
+			return orders.Invalidf(orders.ErrAddress, "address is required")
+		}
+		return orders.Invalidf(orders.ErrAddress, "address not found")
+	}
+
+	b.req.AddressId = address.Id
+	b.req.ShippingAddress = address
+	b.req.Address = address.Text()
+	b.req.Contact = address.Contact()
+	return nil
+}
+
 func (b *insertOrderBuilder) insertOrder() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -66,12 +120,44 @@ func (b *insertOrderBuilder) insertOrder() error {
 		"contact",
 		"address",
 		"transfer_slip",
-		"status"
+		"status",
+		"subtotal",
+		"discount",
+		"shipping_fee",
+		"tax",
+		"total_paid",
+		"coupon_code",
+		"address_id",
+		"shipping_address",
+		"vat_rate",
+		"vat_inclusive",
+		"net_amount",
+		"gross_amount",
+		"buyer_tax_id",
+		"buyer_branch",
+		"currency",
+		"exchange_rate",
+		"currency_total"
 	)
 	VALUES
-	($1, $2, $3, $4, $5)
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, '')::uuid, $13::jsonb, $14, $15, $16, $17, NULLIF($18, ''), NULLIF($19, ''), $20, $21, $22)
 		RETURNING "id";`
 
+	vat := b.req.Vat
+	if vat == nil {
+		vat = new(orders.OrderVat)
+	}
+
+	var shippingAddress any
+	if b.req.ShippingAddress != nil {
+		bytes, err := json.Marshal(b.req.ShippingAddress)
+		if err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("marshal shipping address failed: %v", err)
+		}
+		shippingAddress = string(bytes)
+	}
+
 	if err := b.tx.QueryRowxContext(
 		ctx,
 		query,
@@ -80,9 +166,26 @@ func (b *insertOrderBuilder) insertOrder() error {
 		b.req.Address,
 		b.req.TransferSlip,
 		b.req.Status,
+		b.req.Subtotal,
+		b.req.Discount,
+		b.req.ShippingFee,
+		b.req.Tax,
+		b.req.TotalPaid,
+		b.req.CouponCode,
+		b.req.AddressId,
+		shippingAddress,
+		vat.Rate,
+		vat.Inclusive,
+		vat.Net,
+		vat.Gross,
+		b.req.BuyerTaxId,
+		b.req.BuyerBranch,
+		b.req.Currency,
+		b.req.ExchangeRate,
+		b.req.CurrencyTotal,
 	).Scan(&b.req.Id); err != nil {
 		b.tx.Rollback()
-		return fmt.Errorf("insert order: %w", err)
+		return fmt.Errorf("insert order failed: %v", err)
 	}
 	
 	return nil
@@ -97,32 +200,224 @@ func (b *insertOrderBuilder) insertProductsOrder() error {
 	INSERT INTO "products_orders" (
 		"order_id",
 		"qty",
-		"product"
+		"product",
+		"variant_id",
+		"discount",
+		"net",
+		"vat",
+		"gross"
 	)
 	VALUES`
 
 	lastIndex := 0
 	valueStack := make([]any, 0)
 	for i := range b.req.Products {
-		valueStack = append(valueStack, b.req.Id, b.req.Products[i].Qty, b.req.Products[i].Product)
+		vat := b.req.Products[i].Vat
+		if vat == nil {
+			vat = new(orders.LineVat)
+		}
+		valueStack = append(valueStack, b.req.Id, b.req.Products[i].Qty, b.req.Products[i].Product, b.req.Products[i].VariantId, vat.Discount, vat.Net, vat.Vat, vat.Gross)
 
 		if i != len(b.req.Products)-1 {
-			query += fmt.Sprintf(`($%d, $%d, $%d),`, lastIndex+1, lastIndex+2, lastIndex+3)
+			query += fmt.Sprintf(`($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d),`, lastIndex+1, lastIndex+2, lastIndex+3, lastIndex+4, lastIndex+5, lastIndex+6, lastIndex+7, lastIndex+8)
 		} else {
-			query += fmt.Sprintf(`($%d, $%d, $%d);`, lastIndex+1, lastIndex+2, lastIndex+3)
+			query += fmt.Sprintf(`($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d);`, lastIndex+1, lastIndex+2, lastIndex+3, lastIndex+4, lastIndex+5, lastIndex+6, lastIndex+7, lastIndex+8)
 
 		}
-		lastIndex += 3
+		lastIndex += 8
 	}
 
 	if _, err := b.tx.ExecContext(ctx, query, valueStack...); err != nil {
 		b.tx.Rollback()
-		return fmt.Errorf("insert products order: %w", err)
+		return fmt.Errorf("insert products order failed: %v", err)
+	}
+
+	return nil
+}
+
+
+func (b *insertOrderBuilder) reserveStock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// sum qty by product (or variant) because the same product can be sent in more than one line
+	keys := make([]stockKey, 0)
+	qtyMap := make(map[stockKey]int)
+	for i := range b.req.Products {
+		key := lineStockKey(b.req.Products[i])
+		if _, ok := qtyMap[key]; !ok {
+			keys = append(keys, key)
+		}
+		qtyMap[key] += b.req.Products[i].Qty
+	}
+
+	// lock rows in the same order on every checkout to avoid deadlock
+	sortStockKeys(keys)
+
+	for _, key := range keys {
+		stock, err := lockStock(ctx, b.tx, key)
+		if err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("get product stock failed: %v", err)
+		}
+
+		if stock < qtyMap[key] {
+			b.tx.Rollback()
+			return orders.Invalidf(orders.ErrOutOfStock, "%s is out of stock", key)
+		}
+
+		if err := takeStock(ctx, b.tx, key, b.req.Id, "reserve", qtyMap[key]); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("reserve stock failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+
+// applyCoupon checks the coupon again while its row is locked, so usage limits can't be exceeded by concurrent orders
+func (b *insertOrderBuilder) applyCoupon() error {
+	if b.req.CouponCode == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	coupon := new(coupons.Coupon)
+	if err := b.tx.GetContext(ctx, coupon, `
+	SELECT
+		"id",
+		"code",
+		"type",
+		"value",
+		"max_discount",
+		"min_order",
+		"usage_limit",
+		"usage_limit_per_user",
+		"used_count",
+		"is_active",
+		("starts_at" IS NULL OR "starts_at" <= now()) AS "is_started",
+		("expires_at" IS NOT NULL AND "expires_at" <= now()) AS "is_expired"
+	FROM "coupons"
+	WHERE "code" = $1
+	FOR UPDATE;`, b.req.CouponCode); err != nil {
+		b.tx.Rollback()
+		return orders.Invalidf(orders.ErrCoupon, "coupon %s not found", b.req.CouponCode)
+	}
+
+	coupon.CategoryIds = make([]int, 0)
+	if err := b.tx.SelectContext(ctx, &coupon.CategoryIds, `
+	SELECT
+		"category_id"
+	FROM "coupons_categories"
+	WHERE "coupon_id" = $1;`, coupon.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get coupon categories failed: %v", err)
+	}
+
+	var userUsed int
+	if err := b.tx.GetContext(ctx, &userUsed, `
+	SELECT
+		COUNT(*)
+	FROM "coupon_usages"
+	WHERE "coupon_id" = $1
+	AND "user_id" = $2;`, coupon.Id, b.req.UserId); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("count coupon usages failed: %v", err)
 	}
 
+	if err := coupon.Check(userUsed); err != nil {
+		b.tx.Rollback()
+		return orders.Invalid(orders.ErrCoupon, err)
+	}
+
+	discount, err := coupon.Discount(b.req.Subtotal, ordersPricing.CouponLines(b.req.Products))
+	if err != nil {
+		b.tx.Rollback()
+		return orders.Invalid(orders.ErrCoupon, err)
+	}
+	// the coupon was edited after the order was priced
+	if discount != b.req.Discount {
+		b.tx.Rollback()
+		return orders.Invalidf(orders.ErrChanged, "coupon %s has been changed, please try again", coupon.Code)
+	}
+
+	if _, err := b.tx.ExecContext(ctx, `
+	UPDATE "coupons" SET
+		"used_count" = "used_count" + 1
+	WHERE "id" = $1;`, coupon.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update coupon used count failed: %v", err)
+	}
+
+	if _, err := b.tx.ExecContext(ctx, `
+	INSERT INTO "coupon_usages" (
+		"coupon_id",
+		"order_id",
+		"user_id",
+		"discount"
+	)
+	VALUES ($1, $2, $3, $4);`, coupon.Id, b.req.Id, b.req.UserId, discount); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert coupon usage failed: %v", err)
+	}
 	return nil
 }
 
+func (b *insertOrderBuilder) insertStatusHistory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "order_status_history" (
+		"order_id",
+		"to_status",
+		"changed_by"
+	)
+	VALUES ($1, $2, $3);`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Id, b.req.Status, b.req.UserId); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert order status history failed: %v", err)
+	}
+	return nil
+}
+
+// clearCart removes the ordered lines from the cart, the order fails if the cart was changed during checkout
+func (b *insertOrderBuilder) clearCart() error {
+	if b.req.CartId == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	for i := range b.req.Products {
+		result, err := b.tx.ExecContext(ctx, `
+		DELETE FROM "carts_items"
+		WHERE "cart_id" = $1
+		AND "product_id" = $2
+		AND "variant_id" IS NOT DISTINCT FROM $3::uuid
+		AND "qty" = $4;`, b.req.CartId, b.req.Products[i].Product.Id, b.req.Products[i].VariantId, b.req.Products[i].Qty)
+		if err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("delete cart item failed: %v", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("get rows affected failed: %v", err)
+		}
+		if rowsAffected == 0 {
+			b.tx.Rollback()
+			return orders.Invalidf(orders.ErrChanged, "cart has been changed, please try again")
+		}
+	}
+	return nil
+}
 
 // engineer
 type insertOrderEngineer struct {
@@ -140,6 +435,10 @@ func (en *insertOrderEngineer) InsertOrder() (string, error) {
 		return "", err
 	}
 
+	if err := en.builder.snapshotAddress(); err != nil {
+		return "", err
+	}
+
 	if err := en.builder.insertOrder() ; err != nil {
 		return "", err
 	}
@@ -148,6 +447,22 @@ func (en *insertOrderEngineer) InsertOrder() (string, error) {
 		return "", err
 	}
 
+	if err := en.builder.reserveStock(); err != nil {
+		return "", err
+	}
+
+	if err := en.builder.applyCoupon(); err != nil {
+		return "", err
+	}
+
+	if err := en.builder.insertStatusHistory(); err != nil {
+		return "", err
+	}
+
+	if err := en.builder.clearCart(); err != nil {
+		return "", err
+	}
+
 	if err := en.builder.commit() ; err != nil {
 		return "", err
 	}
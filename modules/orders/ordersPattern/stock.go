@@ -0,0 +1,110 @@
+package ordersPattern
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	"github.com/jmoiron/sqlx"
+)
+
+// stockKey is the row that keeps the stock of an order line,
+// a variant has its own stock and the product stock is used when there is no variant
+type stockKey struct {
+	ProductId string `db:"product_id"`
+	VariantId string `db:"variant_id"` // empty when the line has no variant
+}
+
+func lineStockKey(line *orders.ProductsOrder) stockKey {
+	key := stockKey{ProductId: line.Product.Id}
+	if line.VariantId != nil {
+		key.VariantId = *line.VariantId
+	}
+	return key
+}
+
+// sortStockKeys locks rows in the same order on every checkout and edit to avoid deadlock
+func sortStockKeys(keys []stockKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProductId != keys[j].ProductId {
+			return keys[i].ProductId < keys[j].ProductId
+		}
+		return keys[i].VariantId < keys[j].VariantId
+	})
+}
+
+func (k stockKey) String() string {
+	if k.VariantId == "" {
+		return fmt.Sprintf("product %s", k.ProductId)
+	}
+	return fmt.Sprintf("product %s variant %s", k.ProductId, k.VariantId)
+}
+
+// lockStock returns the stock of the key, its row is locked until the transaction ends
+func lockStock(ctx context.Context, tx *sqlx.Tx, key stockKey) (int, error) {
+	query := `
+	SELECT
+		"stock"
+	FROM "products"
+	WHERE "id" = $1
+	FOR UPDATE;`
+	args := []any{key.ProductId}
+	if key.VariantId != "" {
+		query = `
+	SELECT
+		"stock"
+	FROM "product_variants"
+	WHERE "id" = $1
+	AND "product_id" = $2
+	FOR UPDATE;`
+		args = []any{key.VariantId, key.ProductId}
+	}
+
+	var stock int
+	if err := tx.GetContext(ctx, &stock, query, args...); err != nil {
+		return 0, err
+	}
+	return stock, nil
+}
+
+// takeStock removes qty from the stock of the key (a negative qty gives it back) and records the movement
+func takeStock(ctx context.Context, tx *sqlx.Tx, key stockKey, orderId, movementType string, qty int) error {
+	query := `
+	UPDATE "products" SET
+		"stock" = "stock" - $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+	args := []any{qty, key.ProductId}
+	if key.VariantId != "" {
+		query = `
+	UPDATE "product_variants" SET
+		"stock" = "stock" - $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+		args = []any{qty, key.VariantId}
+	}
+
+	var stock int
+	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&stock); err != nil {
+		return fmt.Errorf("update stock failed: %v", err)
+	}
+
+	var variantId *string
+	if key.VariantId != "" {
+		variantId = &key.VariantId
+	}
+	if _, err := tx.ExecContext(ctx, `
+	INSERT INTO "stock_movements" (
+		"product_id",
+		"variant_id",
+		"order_id",
+		"type",
+		"qty",
+		"balance"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6);`, key.ProductId, variantId, orderId, movementType, -qty, stock); err != nil {
+		return fmt.Errorf("insert stock movement failed: %v", err)
+	}
+	return nil
+}
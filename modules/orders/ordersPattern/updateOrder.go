@@ -0,0 +1,318 @@
+package ordersPattern
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+	"github.com/jmoiron/sqlx"
+)
+
+type IUpdateOrderBuilder interface {
+	initTransaction() error
+	findOldStatus() error
+	checkStatus() error
+	updateOrder() error
+	insertStatusHistory() error
+	releaseStock() error
+	releaseCoupon() error
+	commit() error
+}
+
+type updateOrderBuilder struct {
+	ctx       context.Context
+	req       *orders.OrderUpdate
+	db        *sqlx.DB
+	tx        *sqlx.Tx
+	oldStatus string
+	totalPaid riMoney.Money
+	hasSlip   bool
+}
+
+// UpdateOrderBuilder runs the update in a transaction of ctx, the transaction is rolled back when ctx is canceled
+func UpdateOrderBuilder(ctx context.Context, req *orders.OrderUpdate, db *sqlx.DB) IUpdateOrderBuilder {
+	return &updateOrderBuilder{
+		ctx: ctx,
+		req: req,
+		db:  db,
+	}
+}
+
+func (b *updateOrderBuilder) initTransaction() error {
+	tx, err := b.db.BeginTxx(b.ctx, nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+
+func (b *updateOrderBuilder) commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// findOldStatus locks the order row until commit, so two requests can't cancel the same order at once
+func (b *updateOrderBuilder) findOldStatus() error {
+	ctx, cancel := context.WithTimeout(b.ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT
+		"status",
+		"total_paid",
+		("transfer_slip" IS NOT NULL) AS "has_slip"
+	FROM "orders"
+	WHERE "id" = $1
+	AND "user_id" = $2
+	FOR UPDATE;`
+
+	if err := b.tx.QueryRowxContext(ctx, query, b.req.Id, b.req.UserId).Scan(&b.oldStatus, &b.totalPaid, &b.hasSlip); err != nil {
+		b.tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("get order status failed: %v", err)
+	}
+	return nil
+}
+
+func (b *updateOrderBuilder) checkStatus() error {
+	// the slip may be uploaded after the order was picked to cancel
+	if b.req.UnpaidOnly && (b.oldStatus != "waiting" || b.hasSlip) {
+		b.tx.Rollback()
+		return fmt.Errorf("order has been paid")
+	}
+
+	// same status is not a change, only the other fields will be updated
+	if b.req.Status == "" || b.req.Status == b.oldStatus {
+		b.req.Status = ""
+		return nil
+	}
+
+	if !orders.CanChangeStatus(b.oldStatus, b.req.Status, b.req.IsAdmin) {
+		b.tx.Rollback()
+		return fmt.Errorf("cannot change order status from %s to %s", b.oldStatus, b.req.Status)
+	}
+
+	// an order that has to be paid only leaves waiting when its transfer slip is approved
+	if b.oldStatus == "waiting" && b.req.Status != "canceled" && b.totalPaid > 0 {
+		b.tx.Rollback()
+		return fmt.Errorf("cannot change order status from %s to %s, the transfer slip must be approved", b.oldStatus, b.req.Status)
+	}
+	return nil
+}
+
+func (b *updateOrderBuilder) updateOrder() error {
+	ctx, cancel := context.WithTimeout(b.ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE "orders" SET`
+
+	queryWhereStack := make([]string, 0)
+	values := make([]any, 0)
+	lastIndex := 1
+
+	if b.req.Status != "" {
+		values = append(values, b.req.Status)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"status" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	// nothing to update
+	if len(queryWhereStack) == 0 {
+		return nil
+	}
+
+	values = append(values, b.req.Id)
+
+	queryClose := fmt.Sprintf(`
+	WHERE "id" = $%d;`, lastIndex)
+
+	for i := range queryWhereStack {
+		if i != len(queryWhereStack)-1 {
+			query += strings.Replace(queryWhereStack[i], "?", ",", 1)
+		} else {
+			query += strings.Replace(queryWhereStack[i], "?", "", 1)
+		}
+	}
+	query += queryClose
+
+	if _, err := b.tx.ExecContext(ctx, query, values...); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update order failed: %v", err)
+	}
+	return nil
+}
+
+func (b *updateOrderBuilder) insertStatusHistory() error {
+	if b.req.Status == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "order_status_history" (
+		"order_id",
+		"from_status",
+		"to_status",
+		"changed_by",
+		"note"
+	)
+	VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''));`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Id, b.oldStatus, b.req.Status, b.req.UpdatedBy, b.req.Note); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert order status history failed: %v", err)
+	}
+	return nil
+}
+
+// releaseStock gives back whatever is still reserved by the order when it becomes canceled
+func (b *updateOrderBuilder) releaseStock() error {
+	if b.req.Status != "canceled" || b.oldStatus == "canceled" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, 15*time.Second)
+	defer cancel()
+
+	// reserved qty is the net of every movement of the order, so orders created
+	// before stock tracking (no movement) release nothing. A variant gets back its own stock.
+	query := `
+	WITH "reserved" AS (
+		SELECT
+			"sm"."product_id",
+			"sm"."variant_id",
+			(-SUM("sm"."qty"))::INT AS "qty"
+		FROM "stock_movements" "sm"
+		WHERE "sm"."order_id" = $1
+		GROUP BY "sm"."product_id", "sm"."variant_id"
+		HAVING SUM("sm"."qty") < 0
+	), "released_products" AS (
+		UPDATE "products" "p" SET
+			"stock" = "p"."stock" + "r"."qty"
+		FROM "reserved" "r"
+		WHERE "p"."id" = "r"."product_id"
+		AND "r"."variant_id" IS NULL
+		RETURNING "p"."id" AS "product_id", NULL::uuid AS "variant_id", "r"."qty", "p"."stock"
+	), "released_variants" AS (
+		UPDATE "product_variants" "v" SET
+			"stock" = "v"."stock" + "r"."qty"
+		FROM "reserved" "r"
+		WHERE "v"."id" = "r"."variant_id"
+		RETURNING "v"."product_id", "v"."id" AS "variant_id", "r"."qty", "v"."stock"
+	)
+	INSERT INTO "stock_movements" (
+		"product_id",
+		"variant_id",
+		"order_id",
+		"type",
+		"qty",
+		"balance"
+	)
+	SELECT
+		"product_id",
+		"variant_id",
+		$1,
+		'release',
+		"qty",
+		"stock"
+	FROM (
+		SELECT * FROM "released_products"
+		UNION ALL
+		SELECT * FROM "released_variants"
+	) AS "released";`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("release stock failed: %v", err)
+	}
+	return nil
+}
+
+// releaseCoupon gives the coupon usage back when the order becomes canceled
+func (b *updateOrderBuilder) releaseCoupon() error {
+	if b.req.Status != "canceled" || b.oldStatus == "canceled" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+	WITH "released" AS (
+		DELETE FROM "coupon_usages"
+		WHERE "order_id" = $1
+		RETURNING "coupon_id"
+	)
+	UPDATE "coupons" "c" SET
+		"used_count" = GREATEST("c"."used_count" - 1, 0)
+	FROM "released" "r"
+	WHERE "c"."id" = "r"."coupon_id";`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("release coupon failed: %v", err)
+	}
+	return nil
+}
+
+// engineer
+type updateOrderEngineer struct {
+	builder IUpdateOrderBuilder
+}
+
+func UpdateOrderEngineer(builder IUpdateOrderBuilder) *updateOrderEngineer {
+	return &updateOrderEngineer{
+		builder: builder,
+	}
+}
+
+func (en *updateOrderEngineer) UpdateOrder() error {
+	if err := en.builder.initTransaction(); err != nil {
+		return err
+	}
+
+	if err := en.builder.findOldStatus(); err != nil {
+		return err
+	}
+
+	if err := en.builder.checkStatus(); err != nil {
+		return err
+	}
+
+	if err := en.builder.updateOrder(); err != nil {
+		return err
+	}
+
+	if err := en.builder.insertStatusHistory(); err != nil {
+		return err
+	}
+
+	if err := en.builder.releaseStock(); err != nil {
+		return err
+	}
+
+	if err := en.builder.releaseCoupon(); err != nil {
+		return err
+	}
+
+	if err := en.builder.commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
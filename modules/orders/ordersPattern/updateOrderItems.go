@@ -0,0 +1,430 @@
+package ordersPattern
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+	"github.com/jmoiron/sqlx"
+)
+
+type IUpdateOrderItemsBuilder interface {
+	initTransaction() error
+	lockOrder() error
+	findItemsBefore() error
+	replaceProductsOrder() error
+	adjustStock() error
+	updateOrder() error
+	updateCouponUsage() error
+	insertItemChange() error
+	commit() error
+}
+
+type updateOrderItemsBuilder struct {
+	req         *orders.OrderItemsUpdate
+	db          *sqlx.DB
+	tx          *sqlx.Tx
+	totalBefore riMoney.Money
+	itemsBefore []byte
+}
+
+func UpdateOrderItemsBuilder(req *orders.OrderItemsUpdate, db *sqlx.DB) IUpdateOrderItemsBuilder {
+	return &updateOrderItemsBuilder{
+		req: req,
+		db:  db,
+	}
+}
+
+func (b *updateOrderItemsBuilder) initTransaction() error {
+	tx, err := b.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+
+func (b *updateOrderItemsBuilder) commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// lockOrder locks the order row until commit and checks it can still be edited,
+// lines can't change once a slip is sent or an invoice is issued because the amount is already used
+func (b *updateOrderItemsBuilder) lockOrder() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT
+		"status",
+		"payment_status",
+		COALESCE("coupon_code", '') AS "coupon_code",
+		"total_paid",
+		EXISTS (
+			SELECT 1
+			FROM "invoices" "i"
+			WHERE "i"."order_id" = "o"."id"
+		) AS "has_invoice"
+	FROM "orders" "o"
+	WHERE "id" = $1
+	AND "user_id" = $2
+	FOR UPDATE;`
+
+	var status, paymentStatus, couponCode string
+	var hasInvoice bool
+	if err := b.tx.QueryRowxContext(ctx, query, b.req.Id, b.req.UserId).Scan(&status, &paymentStatus, &couponCode, &b.totalBefore, &hasInvoice); err != nil {
+		b.tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("get order failed: %v", err)
+	}
+
+	if status != "waiting" {
+		b.tx.Rollback()
+		return orders.Invalidf(orders.ErrStatus, "cannot edit items of %s order", status)
+	}
+	if paymentStatus != "unpaid" && paymentStatus != "rejected" {
+		b.tx.Rollback()
+		return orders.Invalidf(orders.ErrStatus, "cannot edit items, the transfer slip has been sent")
+	}
+	if hasInvoice {
+		b.tx.Rollback()
+		return orders.Invalidf(orders.ErrStatus, "cannot edit items, the invoice has been issued")
+	}
+	// the order was priced with another coupon
+	if couponCode != b.req.CouponCode {
+		b.tx.Rollback()
+		return orders.Invalidf(orders.ErrChanged, "order has been changed, please try again")
+	}
+	return nil
+}
+
+func (b *updateOrderItemsBuilder) findItemsBefore() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("it")), '[]'::json)
+	FROM (
+		SELECT
+			COALESCE("po"."product"->>'id', '') AS "product_id",
+			COALESCE("po"."product"->'variant'->>'sku', '') AS "sku",
+			COALESCE("po"."product"->>'title', '') AS "title",
+			COALESCE(("po"."product"->>'price')::NUMERIC, 0) AS "unit_price",
+			"po"."qty"
+		FROM "products_orders" "po"
+		WHERE "po"."order_id" = $1
+	) AS "it";`
+
+	if err := b.tx.GetContext(ctx, &b.itemsBefore, query, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get order items failed: %v", err)
+	}
+	return nil
+}
+
+// replaceProductsOrder inserts the lines again with the new product snapshot,
+// a waiting order has no shipment or return pointing to its lines
+func (b *updateOrderItemsBuilder) replaceProductsOrder() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if _, err := b.tx.ExecContext(ctx, `
+	DELETE FROM "products_orders"
+	WHERE "order_id" = $1;`, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("delete products order failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "products_orders" (
+		"order_id",
+		"qty",
+		"product",
+		"variant_id",
+		"discount",
+		"net",
+		"vat",
+		"gross"
+	)
+	VALUES`
+
+	lastIndex := 0
+	valueStack := make([]any, 0)
+	for i := range b.req.Products {
+		vat := b.req.Products[i].Vat
+		if vat == nil {
+			vat = new(orders.LineVat)
+		}
+		valueStack = append(valueStack, b.req.Id, b.req.Products[i].Qty, b.req.Products[i].Product, b.req.Products[i].VariantId, vat.Discount, vat.Net, vat.Vat, vat.Gross)
+
+		if i != len(b.req.Products)-1 {
+			query += fmt.Sprintf(`($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d),`, lastIndex+1, lastIndex+2, lastIndex+3, lastIndex+4, lastIndex+5, lastIndex+6, lastIndex+7, lastIndex+8)
+		} else {
+			query += fmt.Sprintf(`($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d);`, lastIndex+1, lastIndex+2, lastIndex+3, lastIndex+4, lastIndex+5, lastIndex+6, lastIndex+7, lastIndex+8)
+		}
+		lastIndex += 8
+	}
+
+	if _, err := b.tx.ExecContext(ctx, query, valueStack...); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert products order failed: %v", err)
+	}
+	return nil
+}
+
+// adjustStock reserves or releases only the difference between the new qty and the qty reserved by the order
+func (b *updateOrderItemsBuilder) adjustStock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	reserved := make([]*struct {
+		stockKey
+		Qty int `db:"qty"`
+	}, 0)
+	if err := b.tx.SelectContext(ctx, &reserved, `
+	SELECT
+		"product_id",
+		COALESCE("variant_id"::TEXT, '') AS "variant_id",
+		(-SUM("qty"))::INT AS "qty"
+	FROM "stock_movements"
+	WHERE "order_id" = $1
+	GROUP BY "product_id", "variant_id";`, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get reserved stock failed: %v", err)
+	}
+
+	keys := make([]stockKey, 0)
+	deltaMap := make(map[stockKey]int)
+	for _, r := range reserved {
+		if _, ok := deltaMap[r.stockKey]; !ok {
+			keys = append(keys, r.stockKey)
+		}
+		deltaMap[r.stockKey] -= r.Qty
+	}
+	for i := range b.req.Products {
+		key := lineStockKey(b.req.Products[i])
+		if _, ok := deltaMap[key]; !ok {
+			keys = append(keys, key)
+		}
+		deltaMap[key] += b.req.Products[i].Qty
+	}
+
+	// lock rows in the same order as checkout to avoid deadlock
+	sortStockKeys(keys)
+
+	for _, key := range keys {
+		delta := deltaMap[key]
+		if delta == 0 {
+			continue
+		}
+
+		stock, err := lockStock(ctx, b.tx, key)
+		if err != nil {
+			// the product (or variant) was deleted, nothing to give back
+			if err == sql.ErrNoRows && delta < 0 {
+				continue
+			}
+			b.tx.Rollback()
+			return fmt.Errorf("get product stock failed: %v", err)
+		}
+
+		movementType := "release"
+		if delta > 0 {
+			movementType = "reserve"
+			if stock < delta {
+				b.tx.Rollback()
+				return orders.Invalidf(orders.ErrOutOfStock, "%s is out of stock", key)
+			}
+		}
+
+		if err := takeStock(ctx, b.tx, key, b.req.Id, movementType, delta); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("update product stock failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// updateOrder saves the new totals, the last PromptPay QR has the old amount so it is cleared
+func (b *updateOrderItemsBuilder) updateOrder() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	quote := b.req.Quote
+	vat := quote.Vat
+	if vat == nil {
+		vat = new(orders.OrderVat)
+	}
+	// converted with the rate of the checkout
+	currencyTotal := quote.Total
+	if quote.Currency != nil {
+		currencyTotal = quote.Currency.Total
+	}
+
+	query := `
+	UPDATE "orders" SET
+		"subtotal" = $1,
+		"discount" = $2,
+		"shipping_fee" = $3,
+		"tax" = $4,
+		"total_paid" = $5,
+		"vat_rate" = $6,
+		"vat_inclusive" = $7,
+		"net_amount" = $8,
+		"gross_amount" = $9,
+		"currency_total" = $10,
+		"promptpay_payload" = NULL,
+		"promptpay_amount" = NULL
+	WHERE "id" = $11;`
+
+	if _, err := b.tx.ExecContext(
+		ctx,
+		query,
+		quote.Subtotal,
+		quote.Discount,
+		quote.ShippingFee,
+		quote.Tax,
+		quote.Total,
+		vat.Rate,
+		vat.Inclusive,
+		vat.Net,
+		vat.Gross,
+		currencyTotal,
+		b.req.Id,
+	); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update order failed: %v", err)
+	}
+	return nil
+}
+
+func (b *updateOrderItemsBuilder) updateCouponUsage() error {
+	if b.req.CouponCode == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if _, err := b.tx.ExecContext(ctx, `
+	UPDATE "coupon_usages" SET
+		"discount" = $1
+	WHERE "order_id" = $2;`, b.req.Quote.Discount, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update coupon usage failed: %v", err)
+	}
+	return nil
+}
+
+func (b *updateOrderItemsBuilder) insertItemChange() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	itemsAfter := make([]*orders.OrderItemLine, 0)
+	for i := range b.req.Products {
+		line := &orders.OrderItemLine{
+			ProductId: b.req.Products[i].Product.Id,
+			Title:     b.req.Products[i].Product.Title,
+			UnitPrice: b.req.Products[i].Product.Price,
+			Qty:       b.req.Products[i].Qty,
+		}
+		if b.req.Products[i].Product.Variant != nil {
+			line.Sku = b.req.Products[i].Product.Variant.Sku
+		}
+		itemsAfter = append(itemsAfter, line)
+	}
+	itemsAfterBytes, err := json.Marshal(itemsAfter)
+	if err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("marshal order items failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "order_item_changes" (
+		"order_id",
+		"changed_by",
+		"note",
+		"items_before",
+		"items_after",
+		"total_before",
+		"total_after"
+	)
+	VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7);`
+
+	if _, err := b.tx.ExecContext(
+		ctx,
+		query,
+		b.req.Id,
+		b.req.UpdatedBy,
+		b.req.Note,
+		string(b.itemsBefore),
+		string(itemsAfterBytes),
+		b.totalBefore,
+		b.req.Quote.Total,
+	); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert order item change failed: %v", err)
+	}
+	return nil
+}
+
+// engineer
+type updateOrderItemsEngineer struct {
+	builder IUpdateOrderItemsBuilder
+}
+
+func UpdateOrderItemsEngineer(builder IUpdateOrderItemsBuilder) *updateOrderItemsEngineer {
+	return &updateOrderItemsEngineer{
+		builder: builder,
+	}
+}
+
+func (en *updateOrderItemsEngineer) UpdateOrderItems() error {
+	if err := en.builder.initTransaction(); err != nil {
+		return err
+	}
+
+	if err := en.builder.lockOrder(); err != nil {
+		return err
+	}
+
+	if err := en.builder.findItemsBefore(); err != nil {
+		return err
+	}
+
+	if err := en.builder.replaceProductsOrder(); err != nil {
+		return err
+	}
+
+	if err := en.builder.adjustStock(); err != nil {
+		return err
+	}
+
+	if err := en.builder.updateOrder(); err != nil {
+		return err
+	}
+
+	if err := en.builder.updateCouponUsage(); err != nil {
+		return err
+	}
+
+	if err := en.builder.insertItemChange(); err != nil {
+		return err
+	}
+
+	if err := en.builder.commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package ordersPricing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/coupons"
+	"github.com/NatthawutSK/ri-shop/modules/coupons/couponsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/currencies"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	"github.com/NatthawutSK/ri-shop/modules/products/productsRepositories"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+type IPricingEngine interface {
+	Quote(req *orders.Order) (*orders.OrderQuote, error)
+	Requote(req *orders.Order) (*orders.OrderQuote, error)
+}
+
+type pricingEngine struct {
+	cfg                  config.IConfig
+	productsRepository   productsRepositories.IProductsRepository
+	couponsRepository    couponsRepositories.ICouponsRepository
+	currenciesRepository currenciesRepositories.ICurrenciesRepository
+}
+
+func PricingEngine(cfg config.IConfig, productsRepo productsRepositories.IProductsRepository, couponsRepo couponsRepositories.ICouponsRepository, currenciesRepo currenciesRepositories.ICurrenciesRepository) IPricingEngine {
+	return &pricingEngine{
+		cfg:                  cfg,
+		productsRepository:   productsRepo,
+		couponsRepository:    couponsRepo,
+		currenciesRepository: currenciesRepo,
+	}
+}
+
+// Quote prices every line from the product in the database, the price sent by client is ignored.
+// req.Products[i].Product is replaced by the product from the database so it can be used as the order snapshot.
+func (e *pricingEngine) Quote(req *orders.Order) (*orders.OrderQuote, error) {
+	// a new order always uses the current rate, the rate sent by client is ignored
+	req.ExchangeRate = 0
+	return e.quote(req, true)
+}
+
+// Requote prices the edited lines of a placed order, its coupon is already counted as used by the order
+// so only the discount is calculated again. The rate of the order (req.ExchangeRate) is kept.
+func (e *pricingEngine) Requote(req *orders.Order) (*orders.OrderQuote, error) {
+	return e.quote(req, false)
+}
+
+func (e *pricingEngine) quote(req *orders.Order, checkCoupon bool) (*orders.OrderQuote, error) {
+	quote := &orders.OrderQuote{
+		Lines: make([]*orders.QuoteLine, 0),
+	}
+
+	currency, err := e.findCurrency(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range req.Products {
+		if req.Products[i].Product == nil {
+			return nil, orders.Invalidf(orders.ErrItem, "product is required")
+		}
+		if req.Products[i].Qty < 1 {
+			return nil, orders.Invalidf(orders.ErrItem, "qty must be more than 0")
+		}
+
+		prod, err := e.productsRepository.FindOneProduct(req.Products[i].Product.Id)
+		if err != nil {
+			return nil, fmt.Errorf("find one product failed : %v", err)
+		}
+
+		var variantId string
+		if req.Products[i].VariantId != nil {
+			variantId = strings.Trim(*req.Products[i].VariantId, " ")
+		}
+		// the snapshot is the variant that was bought, its price is the price of the line
+		variant, err := prod.ApplyVariant(variantId)
+		if err != nil {
+			return nil, orders.Invalid(orders.ErrVariant, err)
+		}
+		req.Products[i].Product = prod
+		req.Products[i].VariantId = nil
+
+		line := &orders.QuoteLine{
+			ProductId: prod.Id,
+			Title:     prod.Title,
+			UnitPrice: prod.Price,
+			Qty:       req.Products[i].Qty,
+			Total:     prod.Price.Mul(req.Products[i].Qty),
+		}
+		if variant != nil {
+			req.Products[i].VariantId = &variant.Id
+			line.VariantId = &variant.Id
+			line.Sku = variant.Sku
+		}
+		quote.Lines = append(quote.Lines, line)
+		quote.Subtotal += line.Total
+	}
+
+	// lines the coupon applies to, nil without coupon
+	var eligible []bool
+	if req.CouponCode != "" {
+		discount, eligibleLines, err := e.couponDiscount(req, quote.Subtotal, checkCoupon)
+		if err != nil {
+			return nil, err
+		}
+		quote.CouponCode = strings.ToUpper(req.CouponCode)
+		quote.Discount = discount
+		eligible = eligibleLines
+	}
+
+	quote.ShippingFee = e.shippingFee(quote.Subtotal - quote.Discount)
+	// VAT is only on the products, the shipping fee is added to the total as it is
+	quote.Vat = e.vat(quote, eligible)
+	quote.Tax = quote.Vat.Vat
+	quote.Total = quote.Vat.Gross + quote.ShippingFee
+	quote.Currency = convert(quote, currency)
+
+	return quote, nil
+}
+
+// findCurrency returns the currency of the request, baht when no currency is sent
+func (e *pricingEngine) findCurrency(req *orders.Order) (*currencies.Currency, error) {
+	code := strings.ToUpper(strings.Trim(req.Currency, " "))
+	if code == "" {
+		code = currencies.BaseCurrency
+	}
+
+	currency, err := e.currenciesRepository.FindOneCurrency(code)
+	if err != nil {
+		return nil, orders.Invalidf(orders.ErrCurrency, "currency %s is not supported", code)
+	}
+
+	// placed order keeps its rate even when the currency is turned off later
+	if req.ExchangeRate > 0 {
+		currency.Rate = req.ExchangeRate
+		return currency, nil
+	}
+	if err := currency.Check(); err != nil {
+		return nil, orders.Invalid(orders.ErrCurrency, err)
+	}
+	return currency, nil
+}
+
+// convert shows the quote in the currency, the order is still priced and paid in baht
+func convert(quote *orders.OrderQuote, currency *currencies.Currency) *orders.QuoteCurrency {
+	converted := &orders.QuoteCurrency{
+		Code:        currency.Code,
+		Symbol:      currency.Symbol,
+		Rate:        currency.Rate,
+		Lines:       make([]*orders.QuoteLineCurrency, 0),
+		Subtotal:    currency.Convert(quote.Subtotal),
+		Discount:    currency.Convert(quote.Discount),
+		ShippingFee: currency.Convert(quote.ShippingFee),
+		Tax:         currency.Convert(quote.Tax),
+		Total:       currency.Convert(quote.Total),
+	}
+	for _, line := range quote.Lines {
+		converted.Lines = append(converted.Lines, &orders.QuoteLineCurrency{
+			ProductId: line.ProductId,
+			VariantId: line.VariantId,
+			UnitPrice: currency.Convert(line.UnitPrice),
+			Total:     currency.Convert(line.Total),
+		})
+	}
+	return converted
+}
+
+// vat calculates VAT of every line then sums them, so the lines always add up to the order.
+// The discount is shared to the lines the coupon applies to (eligible, nil is every line) by their running totals,
+// every line takes the share of its running total minus what the lines before it took,
+// so the shares add up to the discount and no line goes below 0.
+func (e *pricingEngine) vat(quote *orders.OrderQuote, eligible []bool) *orders.OrderVat {
+	vat := &orders.OrderVat{
+		Rate:      e.cfg.Shop().TaxRate(),
+		Inclusive: e.cfg.Shop().VatInclusive(),
+	}
+
+	rate := basisPoints(vat.Rate)
+
+	isEligible := func(i int) bool {
+		return eligible == nil || eligible[i]
+	}
+	var eligibleTotal riMoney.Money
+	for i, line := range quote.Lines {
+		if isEligible(i) {
+			eligibleTotal += line.Total
+		}
+	}
+	// nothing to share the discount to, it is shared to every line
+	if eligibleTotal <= 0 {
+		isEligible = func(int) bool { return true }
+		eligibleTotal = quote.Subtotal
+	}
+
+	var runningTotal, discounted riMoney.Money
+	for i, line := range quote.Lines {
+		lineVat := new(orders.LineVat)
+		if isEligible(i) {
+			runningTotal += line.Total
+			lineVat.Discount = quote.Discount.Share(runningTotal, eligibleTotal) - discounted
+			discounted += lineVat.Discount
+		}
+
+		amount := line.Total - lineVat.Discount
+		if vat.Inclusive {
+			lineVat.Gross = amount
+			lineVat.Vat = amount.MulDiv(rate, 10000+rate)
+			lineVat.Net = lineVat.Gross - lineVat.Vat
+		} else {
+			lineVat.Net = amount
+			lineVat.Vat = amount.MulDiv(rate, 10000)
+			lineVat.Gross = lineVat.Net + lineVat.Vat
+		}
+		line.Vat = lineVat
+
+		vat.Net += lineVat.Net
+		vat.Vat += lineVat.Vat
+		vat.Gross += lineVat.Gross
+	}
+
+	return vat
+}
+
+// couponDiscount returns the discount and which lines of the order the coupon applies to
+// basisPoints converts a percent to hundredths of a percent, 7% is 700
+func basisPoints(percent float64) int64 {
+	return int64(math.Round(percent * 100))
+}
+
+func (e *pricingEngine) couponDiscount(req *orders.Order, subtotal riMoney.Money, checkCoupon bool) (riMoney.Money, []bool, error) {
+	coupon, err := e.couponsRepository.FindOneCouponByCode(req.CouponCode)
+	if err != nil {
+		return riMoney.Zero, nil, orders.Invalid(orders.ErrCoupon, err)
+	}
+	if !checkCoupon {
+		return couponDiscount(coupon, subtotal, req.Products)
+	}
+
+	userUsed, err := e.couponsRepository.CountUserUsage(coupon.Id, req.UserId)
+	if err != nil {
+		return riMoney.Zero, nil, err
+	}
+
+	if err := coupon.Check(userUsed); err != nil {
+		return riMoney.Zero, nil, orders.Invalid(orders.ErrCoupon, err)
+	}
+	return couponDiscount(coupon, subtotal, req.Products)
+}
+
+func couponDiscount(coupon *coupons.Coupon, subtotal riMoney.Money, products []*orders.ProductsOrder) (riMoney.Money, []bool, error) {
+	lines := CouponLines(products)
+	discount, err := coupon.Discount(subtotal, lines)
+	if err != nil {
+		return riMoney.Zero, nil, orders.Invalid(orders.ErrCoupon, err)
+	}
+	return discount, coupon.Eligible(lines), nil
+}
+
+// CouponLines converts order lines to lines a coupon can discount
+func CouponLines(products []*orders.ProductsOrder) []*coupons.CouponLine {
+	lines := make([]*coupons.CouponLine, 0)
+	for _, p := range products {
+		line := &coupons.CouponLine{
+			CategoryIds: make([]int, 0),
+			Total:       p.Product.Price.Mul(p.Qty),
+		}
+		for _, category := range p.Product.Categories {
+			line.CategoryIds = append(line.CategoryIds, category.Id)
+		}
+		// snapshot from before a product could have more than one category
+		if len(line.CategoryIds) == 0 && p.Product.Category != nil {
+			line.CategoryIds = append(line.CategoryIds, p.Product.Category.Id)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (e *pricingEngine) shippingFee(amount riMoney.Money) riMoney.Money {
+	if e.cfg.Shop().FreeShippingMin() > 0 && amount >= e.cfg.Shop().FreeShippingMin() {
+		return 0
+	}
+	return e.cfg.Shop().ShippingFee()
+}
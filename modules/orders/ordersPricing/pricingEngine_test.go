@@ -0,0 +1,221 @@
+package ordersPricing
+
+import (
+	"testing"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/orders"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+// testConfig only answers the shop settings used by vat
+type testConfig struct {
+	config.IConfig
+	shop *testShopConfig
+}
+
+func (c *testConfig) Shop() config.IShopConfig { return c.shop }
+
+type testShopConfig struct {
+	config.IShopConfig
+	taxRate      float64
+	vatInclusive bool
+}
+
+func (s *testShopConfig) TaxRate() float64   { return s.taxRate }
+func (s *testShopConfig) VatInclusive() bool { return s.vatInclusive }
+
+type testVat struct {
+	name      string
+	taxRate   float64
+	inclusive bool
+	lines     []riMoney.Money
+	discount  riMoney.Money
+	eligible  []bool          // lines the coupon applies to, nil is every line
+	expected  []riMoney.Money // discount of every line
+	vat       riMoney.Money
+}
+
+func TestVat(t *testing.T) {
+	tests := []testVat{
+		{
+			name:      "inclusive without discount",
+			taxRate:   7,
+			inclusive: true,
+			lines:     []riMoney.Money{10700},
+			expected:  []riMoney.Money{0},
+			vat:       700,
+		},
+		{
+			name:     "exclusive without discount",
+			taxRate:  7,
+			lines:    []riMoney.Money{10000},
+			expected: []riMoney.Money{0},
+			vat:      700,
+		},
+		{
+			name:     "exclusive with rounded shares",
+			taxRate:  7,
+			lines:    []riMoney.Money{3333, 3333, 3334},
+			discount: 1000,
+			expected: []riMoney.Money{333, 334, 333},
+			vat:      630,
+		},
+		{
+			name:      "inclusive with shares by line total",
+			taxRate:   7,
+			inclusive: true,
+			lines:     []riMoney.Money{10000, 20000, 5000},
+			discount:  3500,
+			expected:  []riMoney.Money{1000, 2000, 500},
+			vat:       2061,
+		},
+		{
+			// every share rounds up, the running total keeps the last line from going below 0
+			name:      "shares rounded up on every line",
+			taxRate:   7,
+			inclusive: true,
+			lines:     []riMoney.Money{1, 1, 1, 1},
+			discount:  2,
+			expected:  []riMoney.Money{1, 0, 1, 0},
+			vat:       0,
+		},
+		{
+			name:     "discount of the whole order",
+			taxRate:  7,
+			lines:    []riMoney.Money{9999, 9999, 9999},
+			discount: 29997,
+			expected: []riMoney.Money{9999, 9999, 9999},
+			vat:      0,
+		},
+		{
+			name:      "odd totals",
+			taxRate:   7,
+			inclusive: true,
+			lines:     []riMoney.Money{9999, 1, 4550, 12345},
+			discount:  1234,
+			expected:  []riMoney.Money{459, 0, 209, 566},
+			vat:       1679,
+		},
+		{
+			// coupon of a category, the other line keeps its full price and VAT
+			name:     "coupon of some lines",
+			taxRate:  7,
+			lines:    []riMoney.Money{10000, 20000, 5000},
+			discount: 1500,
+			eligible: []bool{true, false, true},
+			expected: []riMoney.Money{1000, 0, 500},
+			vat:      2345,
+		},
+		{
+			name:      "coupon of the last line",
+			taxRate:   7,
+			inclusive: true,
+			lines:     []riMoney.Money{10700, 3333},
+			discount:  333,
+			eligible:  []bool{false, true},
+			expected:  []riMoney.Money{0, 333},
+			vat:       896,
+		},
+		{
+			name:     "no eligible line shares to every line",
+			taxRate:  7,
+			lines:    []riMoney.Money{10000, 10000},
+			discount: 100,
+			eligible: []bool{false, false},
+			expected: []riMoney.Money{50, 50},
+			vat:      1394,
+		},
+		{
+			name:     "no tax",
+			lines:    []riMoney.Money{10000, 5000},
+			discount: 100,
+			expected: []riMoney.Money{67, 33},
+			vat:      0,
+		},
+	}
+
+	for _, test := range tests {
+		engine := &pricingEngine{
+			cfg: &testConfig{
+				shop: &testShopConfig{
+					taxRate:      test.taxRate,
+					vatInclusive: test.inclusive,
+				},
+			},
+		}
+
+		quote := &orders.OrderQuote{
+			Lines:    make([]*orders.QuoteLine, 0),
+			Discount: test.discount,
+		}
+		for _, total := range test.lines {
+			quote.Lines = append(quote.Lines, &orders.QuoteLine{Total: total})
+			quote.Subtotal += total
+		}
+
+		vat := engine.vat(quote, test.eligible)
+
+		var discount, net, lineVat, gross riMoney.Money
+		for i, line := range quote.Lines {
+			if line.Vat.Discount != test.expected[i] {
+				t.Errorf("%s line %d discount expected: %v, got: %v", test.name, i, test.expected[i], line.Vat.Discount)
+			}
+			if line.Vat.Discount < 0 || line.Vat.Discount > line.Total {
+				t.Errorf("%s line %d discount expected: 0 to %v, got: %v", test.name, i, line.Total, line.Vat.Discount)
+			}
+			if line.Vat.Net+line.Vat.Vat != line.Vat.Gross {
+				t.Errorf("%s line %d expected: net %v + vat %v = gross, got: %v", test.name, i, line.Vat.Net, line.Vat.Vat, line.Vat.Gross)
+			}
+
+			amount := line.Total - line.Vat.Discount
+			if test.inclusive && line.Vat.Gross != amount {
+				t.Errorf("%s line %d gross expected: %v, got: %v", test.name, i, amount, line.Vat.Gross)
+			}
+			if !test.inclusive && line.Vat.Net != amount {
+				t.Errorf("%s line %d net expected: %v, got: %v", test.name, i, amount, line.Vat.Net)
+			}
+
+			discount += line.Vat.Discount
+			net += line.Vat.Net
+			lineVat += line.Vat.Vat
+			gross += line.Vat.Gross
+		}
+
+		if discount != test.discount {
+			t.Errorf("%s discount expected: %v, got: %v", test.name, test.discount, discount)
+		}
+		if vat.Net != net || vat.Vat != lineVat || vat.Gross != gross {
+			t.Errorf("%s expected: %v %v %v, got: %v %v %v", test.name, net, lineVat, gross, vat.Net, vat.Vat, vat.Gross)
+		}
+		if vat.Net+vat.Vat != vat.Gross {
+			t.Errorf("%s expected: net %v + vat %v = gross, got: %v", test.name, vat.Net, vat.Vat, vat.Gross)
+		}
+		if vat.Vat != test.vat {
+			t.Errorf("%s vat expected: %v, got: %v", test.name, test.vat, vat.Vat)
+		}
+	}
+}
+
+type testBasisPoints struct {
+	percent  float64
+	expected int64
+}
+
+func TestBasisPoints(t *testing.T) {
+	tests := []testBasisPoints{
+		{percent: 7, expected: 700},
+		{percent: 0, expected: 0},
+		{percent: 7.5, expected: 750},
+		{percent: 0.07, expected: 7},
+		// not exact in binary
+		{percent: 1.15, expected: 115},
+		{percent: 4.35, expected: 435},
+	}
+
+	for _, test := range tests {
+		if result := basisPoints(test.percent); result != test.expected {
+			t.Errorf("%v expected: %v, got: %v", test.percent, test.expected, result)
+		}
+	}
+}
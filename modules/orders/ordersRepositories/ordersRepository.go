@@ -2,9 +2,10 @@ package ordersRepositories
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/NatthawutSK/ri-shop/modules/orders"
 	"github.com/NatthawutSK/ri-shop/modules/orders/ordersPattern"
@@ -14,8 +15,15 @@ import (
 type IOrdersRepository interface {
 	FindOneOrder(orderId string) (*orders.Order, error)
 	FindOrder(req *orders.OrderFilter) ([]*orders.Order, int)
+	ExportOrder(req *orders.OrderFilter, fn func(row *orders.OrderExportRow) error) error
 	InsertOrder(req *orders.Order) (string, error)
-	UpdateOrder(req *orders.OrderUpdate) error
+	UpdateOrder(ctx context.Context, req *orders.OrderUpdate) error
+	UpdateOrderItems(req *orders.OrderItemsUpdate) error
+	UpdateTransferSlip(userId, orderId string, slip *orders.TransferSlip) error
+	ReviewPayment(req *orders.PaymentReviewReq) error
+	UpdatePromptPay(userId string, req *orders.PromptPay) error
+	FindOrInsertInvoice(orderId string) (*orders.Invoice, error)
+	FindExpiredOrder(ctx context.Context, ttl time.Duration, limit int) ([]*orders.ExpiredOrder, error)
 }
 
 type ordersRepository struct {
@@ -38,6 +46,15 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 			"o"."user_id",
 			"o"."transfer_slip",
 			"o"."status",
+			"o"."coupon_code",
+			json_build_object(
+				'status', "o"."payment_status",
+				'note', "o"."payment_note",
+				'reviewed_by', "o"."payment_reviewed_by",
+				'reviewed_at', "o"."payment_reviewed_at",
+				'promptpay_payload', "o"."promptpay_payload",
+				'promptpay_amount', "o"."promptpay_amount"
+			) AS "payment",
 			(
 				SELECT
 					array_to_json(array_agg("pt"))
@@ -45,30 +62,116 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 					SELECT
 						"spo"."id",
 						"spo"."qty",
-						"spo"."product"
+						"spo"."product",
+						"spo"."variant_id",
+						json_build_object(
+							'discount', "spo"."discount",
+							'net', "spo"."net",
+							'vat', "spo"."vat",
+							'gross', "spo"."gross"
+						) AS "vat"
 					FROM "products_orders" "spo"
 					WHERE "spo"."order_id" = "o"."id"
 				) AS "pt"
 			) AS "products",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("sht")), '[]'::json)
+				FROM (
+					SELECT
+						"s"."id",
+						"s"."carrier",
+						"s"."tracking_number",
+						"s"."shipped_at",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("sit")), '[]'::json)
+							FROM (
+								SELECT
+									"si"."id",
+									"si"."products_order_id",
+									"po"."product"->>'id' AS "product_id",
+									"po"."product"->>'title' AS "title",
+									"si"."qty"
+								FROM "shipments_items" "si"
+									JOIN "products_orders" "po" ON "po"."id" = "si"."products_order_id"
+								WHERE "si"."shipment_id" = "s"."id"
+							) AS "sit"
+						) AS "items",
+						"s"."created_at"
+					FROM "shipments" "s"
+					WHERE "s"."order_id" = "o"."id"
+					ORDER BY "s"."shipped_at" ASC, "s"."created_at" ASC
+				) AS "sht"
+			) AS "shipments",
 			"o"."address",
+			"o"."address_id",
+			"o"."shipping_address",
 			"o"."contact",
+			"o"."subtotal",
+			"o"."discount",
+			"o"."shipping_fee",
+			"o"."tax",
+			"o"."total_paid",
+			"o"."refunded",
+			"o"."buyer_tax_id",
+			"o"."buyer_branch",
+			"o"."currency",
+			"o"."exchange_rate",
+			"o"."currency_total",
+			json_build_object(
+				'rate', "o"."vat_rate",
+				'inclusive', "o"."vat_inclusive",
+				'net', "o"."net_amount",
+				'vat', "o"."tax",
+				'gross', "o"."gross_amount",
+				'tax_invoice_no', (
+					SELECT
+						"i"."tax_invoice_no"
+					FROM "invoices" "i"
+					WHERE "i"."order_id" = "o"."id"
+				)
+			) AS "vat",
+			"o"."created_at",
+			"o"."updated_at",
 			(
 				SELECT
-					SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0))
-				FROM "products_orders" "po"
-				WHERE "po"."order_id" = "o"."id"
-			) AS "total_paid",
-			"o"."created_at",
-			"o"."updated_at"
+					COALESCE(array_to_json(array_agg("ht")), '[]'::json)
+				FROM (
+					SELECT
+						"h"."id",
+						"h"."from_status",
+						"h"."to_status",
+						"h"."changed_by",
+						"h"."note",
+						"h"."created_at"
+					FROM "order_status_history" "h"
+					WHERE "h"."order_id" = "o"."id"
+					ORDER BY "h"."created_at" ASC
+				) AS "ht"
+			) AS "status_history",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("ct")), '[]'::json)
+				FROM (
+					SELECT
+						"c"."id",
+						"c"."changed_by",
+						"c"."note",
+						"c"."items_before",
+						"c"."items_after",
+						"c"."total_before",
+						"c"."total_after",
+						"c"."created_at"
+					FROM "order_item_changes" "c"
+					WHERE "c"."order_id" = "o"."id"
+					ORDER BY "c"."created_at" ASC
+				) AS "ct"
+			) AS "item_changes"
 		FROM "orders" "o"
 		WHERE "o"."id" = $1
 	) AS "t";`
 
-	//SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0))
-	//  "po"."product"->>'price' คือการเข้าถึง value ของ key "price" ใน jsonb ของ column "product" เพราะ product เก็บเป็น json
-	// ::FLOAT คือ casting ค่าให้เป็น float
-	// COALESCE คือ ถ้าเป็น null ให้ return เป็น 0 แทน
-
 	bytes := make([]byte, 0)
 	order := &orders.Order{
 		Products: make([]*orders.ProductsOrder, 0),
@@ -92,6 +195,11 @@ func (r *ordersRepository) FindOrder(req *orders.OrderFilter) ([]*orders.Order,
 	return engineer.FindOrder(), engineer.CountOrder()
 }
 
+func (r *ordersRepository) ExportOrder(req *orders.OrderFilter, fn func(row *orders.OrderExportRow) error) error {
+	builder := ordersPattern.FindOrderBuilder(r.db, req)
+	return ordersPattern.FindOrderEngineer(builder).ExportOrder(fn)
+}
+
 func (r *ordersRepository) InsertOrder(req *orders.Order) (string, error) {
 	builder := ordersPattern.InsertOrderBuilder(req, r.db)
 	orderId, err := ordersPattern.InsertOrderEngineer(builder).InsertOrder()
@@ -148,48 +256,234 @@ func (r *ordersRepository) InsertOrder(req *orders.Order) (string, error) {
 // 	return nil
 // }
 
-func (r *ordersRepository) UpdateOrder(req *orders.OrderUpdate) error {
-	query := `
-	UPDATE "orders" SET`
+func (r *ordersRepository) UpdateOrder(ctx context.Context, req *orders.OrderUpdate) error {
+	builder := ordersPattern.UpdateOrderBuilder(ctx, req, r.db)
+	if err := ordersPattern.UpdateOrderEngineer(builder).UpdateOrder(); err != nil {
+		return err
+	}
+	return nil
+}
 
-	queryWhereStack := make([]string, 0)
-	values := make([]any, 0)
-	lastIndex := 1
+// UpdateOrderItems replaces the lines of a waiting order, req.Products must be priced by the pricing engine
+func (r *ordersRepository) UpdateOrderItems(req *orders.OrderItemsUpdate) error {
+	builder := ordersPattern.UpdateOrderItemsBuilder(req, r.db)
+	if err := ordersPattern.UpdateOrderItemsEngineer(builder).UpdateOrderItems(); err != nil {
+		return err
+	}
+	return nil
+}
 
-	if req.Status != "" {
-		values = append(values, req.Status)
+// UpdateTransferSlip replaces the slip of a waiting order and sends it to review again
+func (r *ordersRepository) UpdateTransferSlip(userId, orderId string, slip *orders.TransferSlip) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
 
-		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
-		"status" = $%d?`, lastIndex))
+	slipBytes, err := json.Marshal(slip)
+	if err != nil {
+		return fmt.Errorf("marshal transfer slip failed: %v", err)
+	}
 
-		lastIndex++
+	query := `
+	UPDATE "orders" SET
+		"transfer_slip" = $1::jsonb,
+		"payment_status" = 'pending_review',
+		"payment_note" = NULL,
+		"payment_reviewed_by" = NULL,
+		"payment_reviewed_at" = NULL
+	WHERE "id" = $2
+	AND "user_id" = $3
+	AND "status" = 'waiting'
+	AND "payment_status" <> 'approved';`
+
+	result, err := r.db.ExecContext(ctx, query, string(slipBytes), orderId, userId)
+	if err != nil {
+		return fmt.Errorf("update transfer slip failed: %v", err)
 	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected failed: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("order is not waiting for payment")
+	}
+	return nil
+}
 
-	if req.TransferSlip != nil {
-		values = append(values, req.TransferSlip)
+// ReviewPayment approves or rejects the transfer slip, an approved order moves from waiting to shipping
+func (r *ordersRepository) ReviewPayment(req *orders.PaymentReviewReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
 
-		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
-		"transfer_slip" = $%d?`, lastIndex))
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
 
-		lastIndex++
+	var status, paymentStatus string
+	if err := tx.QueryRowxContext(ctx, `
+	SELECT
+		"status",
+		"payment_status"
+	FROM "orders"
+	WHERE "id" = $1
+	FOR UPDATE;`, req.OrderId).Scan(&status, &paymentStatus); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("get order failed: %v", err)
 	}
 
-	values = append(values, req.Id)
+	if status != "waiting" {
+		tx.Rollback()
+		return fmt.Errorf("order is not waiting for payment")
+	}
+	if paymentStatus != "pending_review" {
+		tx.Rollback()
+		return fmt.Errorf("transfer slip is not waiting for review")
+	}
 
-	queryClose := fmt.Sprintf(`
-	WHERE "id" = $%d;`, lastIndex)
+	if !req.IsApproved {
+		if _, err := tx.ExecContext(ctx, `
+		UPDATE "orders" SET
+			"payment_status" = 'rejected',
+			"payment_note" = $1,
+			"payment_reviewed_by" = $2,
+			"payment_reviewed_at" = now()
+		WHERE "id" = $3;`, req.Reason, req.ReviewedBy, req.OrderId); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("reject payment failed: %v", err)
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, `
+		UPDATE "orders" SET
+			"status" = 'shipping',
+			"payment_status" = 'approved',
+			"payment_note" = NULLIF($1, ''),
+			"payment_reviewed_by" = $2,
+			"payment_reviewed_at" = now()
+		WHERE "id" = $3;`, req.Reason, req.ReviewedBy, req.OrderId); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("approve payment failed: %v", err)
+		}
 
-	for i := range queryWhereStack {
-		if i != len(queryWhereStack)-1 {
-			query += strings.Replace(queryWhereStack[i], "?", ",", 1)
-		} else {
-			query += strings.Replace(queryWhereStack[i], "?", "", 1)
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO "order_status_history" (
+			"order_id",
+			"from_status",
+			"to_status",
+			"changed_by"
+		)
+		VALUES ($1, $2, 'shipping', $3);`, req.OrderId, status, req.ReviewedBy); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("insert order status history failed: %v", err)
 		}
 	}
-	query += queryClose
 
-	if _, err := r.db.ExecContext(context.Background(), query, values...); err != nil {
-		return fmt.Errorf("update order failed: %v", err)
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return nil
+}
+
+// UpdatePromptPay keeps the last QR payload of a waiting order so admins can match the incoming transfer
+func (r *ordersRepository) UpdatePromptPay(userId string, req *orders.PromptPay) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE "orders" SET
+		"promptpay_payload" = $1,
+		"promptpay_amount" = $2
+	WHERE "id" = $3
+	AND "user_id" = $4
+	AND "status" = 'waiting'
+	AND "payment_status" <> 'approved';`
+
+	result, err := r.db.ExecContext(ctx, query, req.Payload, req.Amount, req.OrderId, userId)
+	if err != nil {
+		return fmt.Errorf("update promptpay failed: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected failed: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("order is not waiting for payment")
 	}
 	return nil
 }
+
+// FindOrInsertInvoice returns the invoice of the order, the next invoice number is taken on the first call
+func (r *ordersRepository) FindOrInsertInvoice(orderId string) (*orders.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	// concurrent requests of the same order wait here, so no invoice number is wasted
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('invoice:' || $1));`, orderId); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("lock invoice failed: %v", err)
+	}
+
+	invoice := new(orders.Invoice)
+	err = tx.GetContext(ctx, invoice, `
+	SELECT
+		"id",
+		"invoice_no",
+		"tax_invoice_no",
+		"order_id",
+		"created_at"
+	FROM "invoices"
+	WHERE "order_id" = $1;`, orderId)
+	if err == sql.ErrNoRows {
+		// only an order with VAT takes a tax invoice number
+		err = tx.GetContext(ctx, invoice, `
+		INSERT INTO "invoices" (
+			"order_id",
+			"tax_invoice_no"
+		)
+		SELECT
+			"o"."id",
+			CASE
+				WHEN "o"."tax" > 0 THEN CONCAT('TX', LPAD(NEXTVAL('tax_invoices_no_seq')::TEXT, 8, '0'))
+				ELSE NULL
+			END
+		FROM "orders" "o"
+		WHERE "o"."id" = $1
+		RETURNING "id", "invoice_no", "tax_invoice_no", "order_id", "created_at";`, orderId)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("get invoice failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return invoice, nil
+}
+
+// FindExpiredOrder finds waiting orders that have to be paid but have no transfer slip after ttl, oldest first
+func (r *ordersRepository) FindExpiredOrder(ctx context.Context, ttl time.Duration, limit int) ([]*orders.ExpiredOrder, error) {
+	query := `
+	SELECT
+		"id",
+		"user_id"
+	FROM "orders"
+	WHERE "status" = 'waiting'
+	AND "transfer_slip" IS NULL
+	AND "total_paid" > 0
+	AND "created_at" < now() - make_interval(secs => $1)
+	ORDER BY "created_at" ASC
+	LIMIT $2;`
+
+	expiredOrders := make([]*orders.ExpiredOrder, 0)
+	if err := r.db.SelectContext(ctx, &expiredOrders, query, ttl.Seconds(), limit); err != nil {
+		return nil, fmt.Errorf("get expired orders failed: %v", err)
+	}
+	return expiredOrders, nil
+}
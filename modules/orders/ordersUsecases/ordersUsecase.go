@@ -1,31 +1,55 @@
 package ordersUsecases
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"log"
 	"math"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/config"
 
 	"github.com/NatthawutSK/ri-shop/modules/entities"
 	"github.com/NatthawutSK/ri-shop/modules/orders"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersInvoice"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersPricing"
 	"github.com/NatthawutSK/ri-shop/modules/orders/ordersRepositories"
 	"github.com/NatthawutSK/ri-shop/modules/products/productsRepositories"
+	riPromptPay "github.com/NatthawutSK/ri-shop/pkg/ripromptpay"
+	"github.com/skip2/go-qrcode"
 )
 
 type IOrdersUsecase interface {
 	FindOneOrder(orderId string) (*orders.Order, error)
 	FindOrder(req *orders.OrderFilter) *entities.PaginateRes
+	ExportOrder(req *orders.OrderFilter, fn func(row *orders.OrderExportRow) error) error
 	InsertOrder(req *orders.Order) (*orders.Order, error)
 	UpdateOrder(req *orders.OrderUpdate) (*orders.Order, error)
+	UpdateOrderItems(req *orders.OrderItemsUpdate) (*orders.Order, error)
+	QuoteOrder(req *orders.Order) (*orders.OrderQuote, error)
+	UploadTransferSlip(userId, orderId string, slip *orders.TransferSlip) (*orders.Order, error)
+	ReviewPayment(req *orders.PaymentReviewReq) (*orders.Order, error)
+	GeneratePromptPay(userId, orderId string) (*orders.PromptPay, error)
+	GenerateInvoice(userId, orderId string) (*orders.Invoice, error)
+	CancelExpiredOrder(ctx context.Context, ttl time.Duration) (int, error)
 }
 
 type ordersUsecase struct {
+	cfg                config.IConfig
 	ordersRepository   ordersRepositories.IOrdersRepository
 	productsRepository productsRepositories.IProductsRepository
+	pricingEngine      ordersPricing.IPricingEngine
+	invoiceRenderer    ordersInvoice.IInvoiceRenderer
 }
 
-func OrdersUsecase(ordersRepo ordersRepositories.IOrdersRepository, productsRepo productsRepositories.IProductsRepository) IOrdersUsecase {
+func OrdersUsecase(ordersRepo ordersRepositories.IOrdersRepository, productsRepo productsRepositories.IProductsRepository, pricingEngine ordersPricing.IPricingEngine, cfg config.IConfig) IOrdersUsecase {
 	return &ordersUsecase{
+		cfg:                cfg,
 		ordersRepository:   ordersRepo,
 		productsRepository: productsRepo,
+		pricingEngine:      pricingEngine,
+		invoiceRenderer:    ordersInvoice.InvoiceRenderer(cfg),
 	}
 }
 
@@ -50,22 +74,32 @@ func (u *ordersUsecase) FindOrder(req *orders.OrderFilter) *entities.PaginateRes
 	}
 }
 
-func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
-	// Check product is exist and correct price
-	for i := range req.Products {
-		if req.Products[i].Product == nil {
-			return nil, fmt.Errorf("product is required")
-
-		}
+func (u *ordersUsecase) ExportOrder(req *orders.OrderFilter, fn func(row *orders.OrderExportRow) error) error {
+	return u.ordersRepository.ExportOrder(req, fn)
+}
 
-		prod, err := u.productsRepository.FindOneProduct(req.Products[i].Product.Id)
-		if err != nil {
-			return nil, fmt.Errorf("find one product failed : %v", err)
-		}
+func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
+	if err := req.CheckBuyer(); err != nil {
+		return nil, err
+	}
 
-		// set price from product
-		req.TotalPaid += req.Products[i].Product.Price * float64(req.Products[i].Qty)
-		req.Products[i].Product = prod
+	// price is always calculated from products in database
+	quote, err := u.pricingEngine.Quote(req)
+	if err != nil {
+		return nil, err
+	}
+	req.CouponCode = quote.CouponCode
+	req.Subtotal = quote.Subtotal
+	req.Discount = quote.Discount
+	req.ShippingFee = quote.ShippingFee
+	req.Tax = quote.Tax
+	req.TotalPaid = quote.Total
+	req.Vat = quote.Vat
+	req.Currency = quote.Currency.Code
+	req.ExchangeRate = quote.Currency.Rate
+	req.CurrencyTotal = quote.Currency.Total
+	for i := range req.Products {
+		req.Products[i].Vat = quote.Lines[i].Vat
 	}
 
 	orderId, err := u.ordersRepository.InsertOrder(req)
@@ -82,7 +116,7 @@ func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
 }
 
 func (u *ordersUsecase) UpdateOrder(req *orders.OrderUpdate) (*orders.Order, error) {
-	if err := u.ordersRepository.UpdateOrder(req); err != nil {
+	if err := u.ordersRepository.UpdateOrder(context.Background(), req); err != nil {
 		return nil, err
 	}
 
@@ -93,3 +127,175 @@ func (u *ordersUsecase) UpdateOrder(req *orders.OrderUpdate) (*orders.Order, err
 
 	return order, nil
 }
+
+// UpdateOrderItems prices the new lines from the products in database with the coupon of the order,
+// then replaces the lines in one transaction
+func (u *ordersUsecase) UpdateOrderItems(req *orders.OrderItemsUpdate) (*orders.Order, error) {
+	order, err := u.ordersRepository.FindOneOrder(req.Id)
+	if err != nil || order.UserId != req.UserId {
+		return nil, fmt.Errorf("order not found")
+	}
+	if order.Status != "waiting" {
+		return nil, orders.Invalidf(orders.ErrStatus, "cannot edit items of %s order", order.Status)
+	}
+
+	priceReq := &orders.Order{
+		Id:         order.Id,
+		UserId:     order.UserId,
+		CouponCode: order.CouponCode,
+		Products:   req.Products,
+		// the lines are converted with the currency and rate of the checkout
+		Currency:     order.Currency,
+		ExchangeRate: order.ExchangeRate,
+	}
+	quote, err := u.pricingEngine.Requote(priceReq)
+	if err != nil {
+		return nil, err
+	}
+	for i := range req.Products {
+		req.Products[i].Vat = quote.Lines[i].Vat
+	}
+	req.CouponCode = order.CouponCode
+	req.Quote = quote
+
+	if err := u.ordersRepository.UpdateOrderItems(req); err != nil {
+		return nil, err
+	}
+
+	order, err = u.ordersRepository.FindOneOrder(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (u *ordersUsecase) QuoteOrder(req *orders.Order) (*orders.OrderQuote, error) {
+	quote, err := u.pricingEngine.Quote(req)
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+func (u *ordersUsecase) UploadTransferSlip(userId, orderId string, slip *orders.TransferSlip) (*orders.Order, error) {
+	if err := u.ordersRepository.UpdateTransferSlip(userId, orderId, slip); err != nil {
+		return nil, err
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (u *ordersUsecase) ReviewPayment(req *orders.PaymentReviewReq) (*orders.Order, error) {
+	if err := u.ordersRepository.ReviewPayment(req); err != nil {
+		return nil, err
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (u *ordersUsecase) GeneratePromptPay(userId, orderId string) (*orders.PromptPay, error) {
+	if u.cfg.Shop().PromptPayId() == "" {
+		return nil, fmt.Errorf("promptpay is not available")
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserId != userId || order.Status != "waiting" || (order.Payment != nil && order.Payment.Status == "approved") {
+		return nil, fmt.Errorf("order is not waiting for payment")
+	}
+	if order.TotalPaid <= 0 {
+		return nil, fmt.Errorf("order has nothing to pay")
+	}
+
+	payload, err := riPromptPay.Payload(u.cfg.Shop().PromptPayId(), order.TotalPaid)
+	if err != nil {
+		return nil, err
+	}
+
+	png, err := qrcode.Encode(payload, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("encode qr code failed: %v", err)
+	}
+
+	promptPay := &orders.PromptPay{
+		OrderId: order.Id,
+		Payload: payload,
+		Amount:  order.TotalPaid,
+		QrCode:  base64.StdEncoding.EncodeToString(png),
+		Png:     png,
+	}
+	if err := u.ordersRepository.UpdatePromptPay(userId, promptPay); err != nil {
+		return nil, err
+	}
+	return promptPay, nil
+}
+
+func (u *ordersUsecase) GenerateInvoice(userId, orderId string) (*orders.Invoice, error) {
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+	if order.Status == "canceled" {
+		return nil, fmt.Errorf("canceled order has no invoice")
+	}
+
+	invoice, err := u.ordersRepository.FindOrInsertInvoice(order.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice.Pdf, err = u.invoiceRenderer.Render(order, invoice)
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// CancelExpiredOrder cancels unpaid orders older than ttl like an admin does, it returns how many orders are canceled.
+// It stops at the next order when ctx is canceled, the order being canceled is rolled back.
+func (u *ordersUsecase) CancelExpiredOrder(ctx context.Context, ttl time.Duration) (int, error) {
+	expiredOrders, err := u.ordersRepository.FindExpiredOrder(ctx, ttl, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	canceled := 0
+	for _, expired := range expiredOrders {
+		if err := ctx.Err(); err != nil {
+			return canceled, err
+		}
+		if err := u.ordersRepository.UpdateOrder(ctx, &orders.OrderUpdate{
+			Id:         expired.Id,
+			UserId:     expired.UserId,
+			Status:     "canceled",
+			IsAdmin:    true,
+			UnpaidOnly: true,
+			Note:       fmt.Sprintf("not paid within %s", ttl),
+		}); err != nil {
+			// the customer paid while the order was waiting to be canceled
+			if err.Error() == "order has been paid" {
+				continue
+			}
+			log.Printf("cancel expired order %s failed: %v\n", expired.Id, err)
+			continue
+		}
+		canceled++
+	}
+	return canceled, nil
+}
@@ -1,24 +1,323 @@
 package products
 
 import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
 	"github.com/NatthawutSK/ri-shop/modules/appinfo"
+	"github.com/NatthawutSK/ri-shop/modules/currencies"
 	"github.com/NatthawutSK/ri-shop/modules/entities"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
 )
 
 type Products struct {
-	Id          string            `json:"id"`
-	Title       string            `json:"title"`
-	Description string            `json:"description"`
-	Category    *appinfo.Category `json:"category"`
-	CreatedAt   string            `json:"created_at"`
-	UpdatedAt   string            `json:"updated_at"`
-	Price       float64           `json:"price"`
-	Images      []*entities.Image `json:"images"`
+	Id          string              `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Category    *appinfo.Category   `json:"category"` // primary category, the first of categories, kept for clients of one category
+	Categories  []*appinfo.Category `json:"categories"`
+	CreatedAt   string              `json:"created_at"`
+	UpdatedAt   string              `json:"updated_at"`
+	Price       riMoney.Money       `json:"price"`
+	Images      []*entities.Image   `json:"images"`
+	Options     []*ProductOption    `json:"options"`
+
+	Variants     []*ProductVariant `json:"variants,omitempty"`      // active variants, a product with variants is bought by one of them
+	Variant      *ProductVariant   `json:"variant,omitempty"`       // only in the order snapshot, the variant that was bought
+	DisplayPrice *currencies.Price `json:"display_price,omitempty"` // price in the currency of the request, never saved
+	Highlight    *ProductHighlight `json:"highlight,omitempty"`     // matched words in <mark>, only when searching
+}
+
+// ProductOption is an option type such as size or colour, a variant picks one of the values
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ProductVariant is one combination of the options with its own SKU, price is the product price when it is null
+type ProductVariant struct {
+	Id        string            `json:"id"`
+	ProductId string            `json:"product_id"`
+	Sku       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	Price     *riMoney.Money    `json:"price"`
+	IsActive  bool              `json:"is_active"`
+	Stock     *int              `json:"stock,omitempty"` // only shown to admins
+	Images    []*entities.Image `json:"images"`
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+
+	DisplayPrice *currencies.Price `json:"display_price,omitempty"` // price of the variant in the currency of the request
+}
+
+type ProductVariantUpdate struct {
+	Id         string             `json:"-"`
+	ProductId  string             `json:"-"`
+	Sku        *string            `json:"sku"`
+	Options    *map[string]string `json:"options"`
+	Price      *riMoney.Money     `json:"price"`
+	ClearPrice bool               `json:"clear_price"` // use the product price again
+	IsActive   *bool              `json:"is_active"`
+	Images     []*entities.Image  `json:"images"` // replaces the images when it is sent
+}
+
+// ProductHighlight is html escaped except the <mark> tags, description is cut to the matched parts
+type ProductHighlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
 }
 
 type ProductFilter struct {
-	Id     string `json:"id" query:"id"`
-	Search string `json:"search" query:"search"` // search by title and description
+	Id       string `json:"id" query:"id"`
+	Search   string `json:"search" query:"search"`     // search by title and description, see order_by=relevance
+	Currency string `json:"currency" query:"currency"` // price is also shown in this currency
+
+	CategoryIds   []int  `json:"category_ids" query:"category_ids"`     // repeat the parameter for more than one category
+	MinPrice      string `json:"min_price" query:"min_price"`           // baht
+	MaxPrice      string `json:"max_price" query:"max_price"`           // baht
+	CreatedAfter  string `json:"created_after" query:"created_after"`   // YYYY-MM-DD, the day is included
+	CreatedBefore string `json:"created_before" query:"created_before"` // YYYY-MM-DD, the day is included
+	HasImages     *bool  `json:"has_images" query:"has_images"`
 	*entities.PaginationReq
 	*entities.SortReq
 }
+
+// Check validates the filters and normalizes prices and dates for the query
+func (f *ProductFilter) Check() error {
+	var minPrice, maxPrice riMoney.Money
+	if f.MinPrice != "" {
+		price, err := riMoney.Parse(f.MinPrice)
+		if err != nil || price < 0 {
+			return fmt.Errorf("min_price is invalid")
+		}
+		minPrice = price
+		f.MinPrice = price.String()
+	}
+	if f.MaxPrice != "" {
+		price, err := riMoney.Parse(f.MaxPrice)
+		if err != nil || price < 0 {
+			return fmt.Errorf("max_price is invalid")
+		}
+		maxPrice = price
+		f.MaxPrice = price.String()
+	}
+	if f.MinPrice != "" && f.MaxPrice != "" && minPrice > maxPrice {
+		return fmt.Errorf("min_price must not be more than max_price")
+	}
+
+	var after, before time.Time
+	if f.CreatedAfter != "" {
+		date, err := time.Parse("2006-01-02", f.CreatedAfter)
+		if err != nil {
+			return fmt.Errorf("created_after is invalid")
+		}
+		after = date
+		f.CreatedAfter = date.Format("2006-01-02")
+	}
+	if f.CreatedBefore != "" {
+		date, err := time.Parse("2006-01-02", f.CreatedBefore)
+		if err != nil {
+			return fmt.Errorf("created_before is invalid")
+		}
+		before = date
+		f.CreatedBefore = date.Format("2006-01-02")
+	}
+	if f.CreatedAfter != "" && f.CreatedBefore != "" && after.After(before) {
+		return fmt.Errorf("created_after must not be after created_before")
+	}
+
+	for _, categoryId := range f.CategoryIds {
+		if categoryId < 1 {
+			return fmt.Errorf("category_ids is invalid")
+		}
+	}
+	return nil
+}
+
+// CheckCategories removes repeated categories and keeps their order, the first category becomes the primary category
+func (p *Products) CheckCategories() error {
+	seen := make(map[int]bool)
+	categories := make([]*appinfo.Category, 0)
+	for _, category := range p.Categories {
+		if category == nil || category.Id <= 0 {
+			return fmt.Errorf("category id is invalid")
+		}
+		if seen[category.Id] {
+			continue
+		}
+		seen[category.Id] = true
+		categories = append(categories, category)
+	}
+	p.Categories = categories
+	if len(categories) > 0 {
+		p.Category = categories[0]
+	}
+	return nil
+}
+
+// CheckOptions trims the options, every option needs a name and at least one value, names and values can't be repeated
+func (p *Products) CheckOptions() error {
+	names := make(map[string]bool)
+	for _, option := range p.Options {
+		if option == nil {
+			return fmt.Errorf("option is invalid")
+		}
+		option.Name = strings.Trim(option.Name, " ")
+		if option.Name == "" {
+			return fmt.Errorf("option name is required")
+		}
+		if names[strings.ToLower(option.Name)] {
+			return fmt.Errorf("option %s is repeated", option.Name)
+		}
+		names[strings.ToLower(option.Name)] = true
+
+		if len(option.Values) == 0 {
+			return fmt.Errorf("option %s has no value", option.Name)
+		}
+		values := make(map[string]bool)
+		for i := range option.Values {
+			option.Values[i] = strings.Trim(option.Values[i], " ")
+			if option.Values[i] == "" || values[option.Values[i]] {
+				return fmt.Errorf("option %s has an empty or repeated value", option.Name)
+			}
+			values[option.Values[i]] = true
+		}
+	}
+	return nil
+}
+
+// CheckVariantOptions checks that the variant picks one value of every option of the product and nothing else
+func (p *Products) CheckVariantOptions(options map[string]string) error {
+	if len(p.Options) == 0 {
+		return fmt.Errorf("variant needs the product to have options")
+	}
+	if len(options) != len(p.Options) {
+		return fmt.Errorf("variant must have a value of every option")
+	}
+	for _, option := range p.Options {
+		value, ok := options[option.Name]
+		if !ok {
+			return fmt.Errorf("variant must have a value of option %s", option.Name)
+		}
+		found := false
+		for _, v := range option.Values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("variant value %s is not in option %s", value, option.Name)
+		}
+	}
+	return nil
+}
+
+// SelectVariant returns the active variant to buy, nil when the product has no variant
+func (p *Products) SelectVariant(variantId string) (*ProductVariant, error) {
+	if variantId == "" {
+		if len(p.Variants) > 0 {
+			return nil, fmt.Errorf("variant is required for product %s", p.Id)
+		}
+		return nil, nil
+	}
+	for _, variant := range p.Variants {
+		if variant.Id == variantId {
+			return variant, nil
+		}
+	}
+	return nil, fmt.Errorf("variant %s is not found in product %s", variantId, p.Id)
+}
+
+// ApplyVariant turns the product into the line that is bought: the variant is selected, the price becomes
+// the price of the variant and the other variants are removed. Carts and order quotes are priced the same way.
+func (p *Products) ApplyVariant(variantId string) (*ProductVariant, error) {
+	variant, err := p.SelectVariant(variantId)
+	if err != nil {
+		return nil, err
+	}
+	p.Price = p.VariantPrice(variant)
+	p.Variant = variant
+	p.Variants = nil
+	return variant, nil
+}
+
+// VariantPrice is the price of the variant in baht, the product price when the variant has no price of its own
+func (p *Products) VariantPrice(variant *ProductVariant) riMoney.Money {
+	if variant == nil || variant.Price == nil {
+		return p.Price
+	}
+	return *variant.Price
+}
+
+// ProductStock is the stock of the product, a product with variants keeps stock by variant
+type ProductStock struct {
+	ProductId string           `json:"product_id" db:"product_id"`
+	Stock     int              `json:"stock" db:"stock"`
+	Variants  []*VariantStock  `json:"variants"`
+	Movements []*StockMovement `json:"movements"`
+}
+
+type VariantStock struct {
+	Id       string `json:"id" db:"id"`
+	Sku      string `json:"sku" db:"sku"`
+	IsActive bool   `json:"is_active" db:"is_active"`
+	Stock    int    `json:"stock" db:"stock"`
+}
+
+type StockMovement struct {
+	Id        string  `json:"id" db:"id"`
+	ProductId string  `json:"product_id" db:"product_id"`
+	VariantId *string `json:"variant_id" db:"variant_id"`
+	OrderId   *string `json:"order_id" db:"order_id"`
+	Type      string  `json:"type" db:"type"` // adjust, reserve, release
+	Qty       int     `json:"qty" db:"qty"`
+	Balance   int     `json:"balance" db:"balance"`
+	Note      string  `json:"note" db:"note"`
+	CreatedBy *string `json:"created_by" db:"created_by"`
+	CreatedAt string  `json:"created_at" db:"created_at"`
+}
+
+type StockAdjustReq struct {
+	ProductId string `json:"-"`
+	VariantId string `json:"variant_id" form:"variant_id"` // stock of the variant is adjusted when it is sent
+	Qty       int    `json:"qty" form:"qty"`               // + for restock, - for write-off
+	Note      string `json:"note" form:"note"`
+	CreatedBy string `json:"-"`
+}
+
+// Mark escapes html of the highlight, search text is marked when full-text search matched no word (part of a word, Thai)
+func (h *ProductHighlight) Mark(search string) {
+	h.Title = markText(h.Title, search)
+	h.Description = markText(h.Description, search)
+}
+
+func markText(text, search string) string {
+	if strings.Contains(text, "<mark>") {
+		return markReplacer.Replace(html.EscapeString(text))
+	}
+
+	search = strings.Trim(search, " ")
+	lowerText, lowerSearch := strings.ToLower(text), strings.ToLower(search)
+	// indexes of the lower case text can only be used when lower case has the same bytes
+	if search == "" || len(lowerText) != len(text) || len(lowerSearch) != len(search) {
+		return html.EscapeString(text)
+	}
+
+	var marked strings.Builder
+	for {
+		i := strings.Index(lowerText, lowerSearch)
+		if i < 0 {
+			break
+		}
+		marked.WriteString(html.EscapeString(text[:i]))
+		marked.WriteString("<mark>" + html.EscapeString(text[i:i+len(search)]) + "</mark>")
+		text, lowerText = text[i+len(search):], lowerText[i+len(search):]
+	}
+	marked.WriteString(html.EscapeString(text))
+	return marked.String()
+}
+
+var markReplacer = strings.NewReplacer("&lt;mark&gt;", "<mark>", "&lt;/mark&gt;", "</mark>")
@@ -24,6 +24,12 @@ const (
 	insertProductErr productsHandlerErrCode = "products-003"
 	updateProductErr productsHandlerErrCode = "products-004"
 	deleteProductErr productsHandlerErrCode = "products-005"
+	findStockErr productsHandlerErrCode = "products-006"
+	adjustStockErr productsHandlerErrCode = "products-007"
+	findVariantsErr productsHandlerErrCode = "products-008"
+	insertVariantErr productsHandlerErrCode = "products-009"
+	updateVariantErr productsHandlerErrCode = "products-010"
+	deleteVariantErr productsHandlerErrCode = "products-011"
 )
 
 type IProductsHandler interface{
@@ -32,6 +38,12 @@ type IProductsHandler interface{
 	AddProduct(c *fiber.Ctx) error
 	UpdateProduct(c *fiber.Ctx) error
 	DeleteProduct(c *fiber.Ctx) error
+	FindStock(c *fiber.Ctx) error
+	AdjustStock(c *fiber.Ctx) error
+	FindVariants(c *fiber.Ctx) error
+	AddVariant(c *fiber.Ctx) error
+	UpdateVariant(c *fiber.Ctx) error
+	DeleteVariant(c *fiber.Ctx) error
 }
 
 type productsHandler struct {
@@ -50,8 +62,18 @@ func ProductsHandler(productsUsecase productsUsecases.IProductsUsecase, cfg conf
 
 func (h *productsHandler) FindOneProduct(c *fiber.Ctx) error {
 	productId := strings.Trim(c.Params("productId"), " ")
-	product, err := h.productsUsecase.FindOneProduct(productId)
+	// currency can be sent by query or X-Currency header
+	currency := c.Query("currency", c.Get("X-Currency"))
+
+	product, err := h.productsUsecase.FindOneProduct(productId, currency)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "currency ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(findOneProductErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(findOneProductErr),
@@ -93,7 +115,26 @@ func (h *productsHandler) FindProduct(c *fiber.Ctx) error {
 		req.Sort = "ASC"
 	}
 
-	products := h.productsUsecase.FindProduct(req)
+	if req.Currency == "" {
+		req.Currency = c.Get("X-Currency")
+	}
+
+	if err := req.Check(); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findProductErr),
+			err.Error(),
+		).Res()
+	}
+
+	products, err := h.productsUsecase.FindProduct(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findProductErr),
+			err.Error(),
+		).Res()
+	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, products).Res()
 }
 
@@ -111,7 +152,11 @@ func (h *productsHandler) AddProduct(c *fiber.Ctx) error {
 		).Res()
 	}
 
-	if req.Category.Id <= 0 {
+	// category is still accepted for clients of one category
+	if len(req.Categories) == 0 && req.Category != nil && req.Category.Id > 0 {
+		req.Categories = append(req.Categories, req.Category)
+	}
+	if len(req.Categories) == 0 {
 		return entities.NewResponse(c).Error(
 			fiber.ErrBadRequest.Code,
 			string(insertProductErr),
@@ -121,6 +166,14 @@ func (h *productsHandler) AddProduct(c *fiber.Ctx) error {
 
 	product, err := h.productsUsecase.AddProduct(req)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "option") ||
+			strings.HasPrefix(err.Error(), "category ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertProductErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(insertProductErr),
@@ -153,6 +206,14 @@ func (h *productsHandler) UpdateProduct(c *fiber.Ctx) error {
 
 	product, err := h.productsUsecase.UpdateProduct(req)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "option") ||
+			strings.HasPrefix(err.Error(), "category ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateProductErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(updateProductErr),
@@ -169,7 +230,17 @@ func (h *productsHandler) UpdateProduct(c *fiber.Ctx) error {
 func (h *productsHandler) DeleteProduct(c *fiber.Ctx) error {
 	productId := strings.Trim(c.Params("productId"), " ")
 	
-	product, err := h.productsUsecase.FindOneProduct(productId)
+	product, err := h.productsUsecase.FindOneProduct(productId, "")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteProductErr),
+			err.Error(),
+		).Res()
+	}
+
+	// images of the variants are deleted with the product, inactive variants too
+	variants, err := h.productsUsecase.FindVariants(productId)
 	if err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
@@ -177,9 +248,13 @@ func (h *productsHandler) DeleteProduct(c *fiber.Ctx) error {
 			err.Error(),
 		).Res()
 	}
+	images := product.Images
+	for _, variant := range variants {
+		images = append(images, variant.Images...)
+	}
 
 	deleteFileReq := make([]*files.DeleteFileReq, 0)
-	for _, image := range product.Images {
+	for _, image := range images {
 		parsedURL, err := url.Parse(image.Url)
 		if err != nil {
 			fmt.Println("Error parsing URL:", err)
@@ -213,4 +288,193 @@ func (h *productsHandler) DeleteProduct(c *fiber.Ctx) error {
 
 	return entities.NewResponse(c).Success(fiber.StatusNoContent, nil).Res()
 
-}
\ No newline at end of file
+}
+
+func (h *productsHandler) FindStock(c *fiber.Ctx) error {
+	productId := strings.Trim(c.Params("productId"), " ")
+
+	stock, err := h.productsUsecase.FindStock(productId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findStockErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, stock).Res()
+}
+
+func (h *productsHandler) AdjustStock(c *fiber.Ctx) error {
+	req := new(products.StockAdjustReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(adjustStockErr),
+			err.Error(),
+		).Res()
+	}
+
+	if req.Qty == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(adjustStockErr),
+			"qty must not be 0",
+		).Res()
+	}
+
+	req.ProductId = strings.Trim(c.Params("productId"), " ")
+	req.VariantId = strings.Trim(req.VariantId, " ")
+	req.CreatedBy = c.Locals("userId").(string)
+
+	stock, err := h.productsUsecase.AdjustStock(req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "stock is not enough") ||
+			strings.HasPrefix(err.Error(), "variant ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(adjustStockErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(adjustStockErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, stock).Res()
+}
+
+func (h *productsHandler) FindVariants(c *fiber.Ctx) error {
+	productId := strings.Trim(c.Params("productId"), " ")
+
+	variants, err := h.productsUsecase.FindVariants(productId)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findVariantsErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findVariantsErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, variants).Res()
+}
+
+func (h *productsHandler) AddVariant(c *fiber.Ctx) error {
+	req := &products.ProductVariant{
+		Options:  make(map[string]string),
+		IsActive: true,
+		Images:   make([]*entities.Image, 0),
+	}
+
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertVariantErr),
+			err.Error(),
+		).Res()
+	}
+	req.ProductId = strings.Trim(c.Params("productId"), " ")
+
+	variant, err := h.productsUsecase.AddVariant(req)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(insertVariantErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "variant ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertVariantErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertVariantErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, variant).Res()
+}
+
+func (h *productsHandler) UpdateVariant(c *fiber.Ctx) error {
+	req := new(products.ProductVariantUpdate)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateVariantErr),
+			err.Error(),
+		).Res()
+	}
+	req.ProductId = strings.Trim(c.Params("productId"), " ")
+	req.Id = strings.Trim(c.Params("variantId"), " ")
+
+	variant, err := h.productsUsecase.UpdateVariant(req)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateVariantErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "variant ") ||
+			strings.HasPrefix(err.Error(), "nothing to update") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateVariantErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateVariantErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, variant).Res()
+}
+
+func (h *productsHandler) DeleteVariant(c *fiber.Ctx) error {
+	productId := strings.Trim(c.Params("productId"), " ")
+	variantId := strings.Trim(c.Params("variantId"), " ")
+
+	if err := h.productsUsecase.DeleteVariant(productId, variantId); err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteVariantErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "variant has stock movements") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(deleteVariantErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteVariantErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusNoContent, nil).Res()
+}
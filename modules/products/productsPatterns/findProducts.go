@@ -34,6 +34,12 @@ type findProductBuilder struct {
 	query          string
 	lastStackIndex int
 	values         []any
+	searchIndex    int // placeholder of the search text, 0 when there is no search
+}
+
+// searchTsQuery matches english words by their stem and other words as they are
+func searchTsQuery(index int) string {
+	return fmt.Sprintf(`(websearch_to_tsquery('english', $%d) || websearch_to_tsquery('simple', $%d))`, index, index)
 }
 
 func FindProductBuilder(db *sqlx.DB, req *products.ProductFilter) IFindProductBuilder {
@@ -48,10 +54,30 @@ func (b *findProductBuilder) openJsonQuery() {
 		array_to_json(array_agg("t"))
 	FROM (`
 }
+func (b *findProductBuilder) initSearch() {
+	// a search of only spaces is no search, LIKE '%%' would match every product
+	search := strings.Trim(b.req.Search, " ")
+	if search == "" {
+		return
+	}
+	b.values = append(b.values, search)
+	b.searchIndex = len(b.values)
+}
 func (b *findProductBuilder) initQuery() {
-	
+	b.initSearch()
+
+	// highlight is only selected when searching, title is highlighted whole and description is cut to snippets
+	var highlight string
+	if b.searchIndex > 0 {
+		highlight = fmt.Sprintf(`
+			json_build_object(
+				'title', ts_headline('english', "p"."title", %s, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+				'description', ts_headline('english', "p"."description", %s, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+			) AS "highlight",`, searchTsQuery(b.searchIndex), searchTsQuery(b.searchIndex))
+	}
+
 	b.query += `
-		SELECT
+		SELECT` + highlight + `
 			"p"."id",
 			"p"."title",
 			"p"."description",
@@ -66,8 +92,23 @@ func (b *findProductBuilder) initQuery() {
 					FROM "categories" "c"
 						LEFT JOIN "products_categories" "pc" ON "pc"."category_id" = "c"."id"
 					WHERE "pc"."product_id" = "p"."id"
+					ORDER BY "pc"."position" ASC, "c"."id" ASC
+					LIMIT 1
 				) AS "ct"
 			) AS "category",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("cst")), '[]'::json)
+				FROM (
+					SELECT
+						"c"."id",
+						"c"."title"
+					FROM "categories" "c"
+						LEFT JOIN "products_categories" "pc" ON "pc"."category_id" = "c"."id"
+					WHERE "pc"."product_id" = "p"."id"
+					ORDER BY "pc"."position" ASC, "c"."id" ASC
+				) AS "cst"
+			) AS "categories",
 			"p"."created_at",
 			"p"."updated_at",
 			(
@@ -81,11 +122,45 @@ func (b *findProductBuilder) initQuery() {
 					FROM "images" "i"
 					WHERE "i"."product_id" = "p"."id"
 				) AS "it"
-			) AS "images"
+			) AS "images",
+			"p"."options",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("vt")), '[]'::json)
+				FROM (
+					SELECT
+						"v"."id",
+						"v"."product_id",
+						"v"."sku",
+						"v"."options",
+						"v"."price",
+						"v"."is_active",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("vit")), '[]'::json)
+							FROM (
+								SELECT
+									"vi"."id",
+									"vi"."filename",
+									"vi"."url"
+								FROM "product_variant_images" "vi"
+								WHERE "vi"."variant_id" = "v"."id"
+							) AS "vit"
+						) AS "images",
+						"v"."created_at",
+						"v"."updated_at"
+					FROM "product_variants" "v"
+					WHERE "v"."product_id" = "p"."id"
+					AND "v"."is_active" = TRUE
+					ORDER BY "v"."created_at" ASC
+				) AS "vt"
+			) AS "variants"
 		FROM "products" "p"
 		WHERE 1 = 1`
 }
 func (b *findProductBuilder) countQuery() {
+	b.initSearch()
+
 	b.query += `
 		SELECT
 			COUNT(*) AS "count"
@@ -95,6 +170,8 @@ func (b *findProductBuilder) countQuery() {
 func (b *findProductBuilder) whereQuery() {
 	var queryWhere string
 	queryWhereStack := make([]string, 0)
+	// values added before where, such as the search text
+	start := len(b.values)
 
 	// Id check
 	if b.req.Id != "" {
@@ -104,25 +181,90 @@ func (b *findProductBuilder) whereQuery() {
 		AND "p"."id" = ?`)
 	}
 
-	// Search check
-	if b.req.Search != "" {
-		b.values = append(
-			b.values,
-			"%"+strings.ToLower(b.req.Search)+"%",
-			"%"+strings.ToLower(b.req.Search)+"%",
-		)
+	// Search check, full-text for words, LIKE for part of a word and Thai, similarity for typos
+	if b.searchIndex > 0 {
+		like := "%" + likeEscaper.Replace(strings.ToLower(strings.Trim(b.req.Search, " "))) + "%"
+		b.values = append(b.values, like, like)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		AND (
+			"p"."search_vector" @@ %s
+			OR LOWER("p"."title") LIKE ?
+			OR LOWER("p"."description") LIKE ?
+			OR LOWER($%d) <%% LOWER("p"."title")
+		)`, searchTsQuery(b.searchIndex), b.searchIndex))
+	}
+
+	// Category check, product is in any of the categories
+	if len(b.req.CategoryIds) > 0 {
+		placeholders := make([]string, 0)
+		for _, categoryId := range b.req.CategoryIds {
+			b.values = append(b.values, categoryId)
+			placeholders = append(placeholders, "?")
+		}
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		AND EXISTS (
+			SELECT
+				1
+			FROM "products_categories" "pc"
+			WHERE "pc"."product_id" = "p"."id"
+			AND "pc"."category_id" IN (%s)
+		)`, strings.Join(placeholders, ", ")))
+	}
+
+	// Price check, prices are normalized by ProductFilter.Check
+	if b.req.MinPrice != "" {
+		b.values = append(b.values, b.req.MinPrice)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."price" >= ?::NUMERIC`)
+	}
+	if b.req.MaxPrice != "" {
+		b.values = append(b.values, b.req.MaxPrice)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."price" <= ?::NUMERIC`)
+	}
+
+	// Date check, both days are included
+	if b.req.CreatedAfter != "" {
+		b.values = append(b.values, b.req.CreatedAfter)
 
 		queryWhereStack = append(queryWhereStack, `
-		AND (LOWER("p"."title") LIKE ? OR LOWER("p"."description") LIKE ?)`)
+		AND "p"."created_at" >= ?::DATE`)
 	}
+	if b.req.CreatedBefore != "" {
+		b.values = append(b.values, b.req.CreatedBefore)
 
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."created_at" < ?::DATE + 1`)
+	}
+
+	// Image check
+	if b.req.HasImages != nil {
+		exists := "EXISTS"
+		if !*b.req.HasImages {
+			exists = "NOT EXISTS"
+		}
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		AND %s (
+			SELECT
+				1
+			FROM "images" "i"
+			WHERE "i"."product_id" = "p"."id"
+		)`, exists))
+	}
+
+	// every ? is numbered in the order of b.values, so the same where works for find and count
+	index := start
 	for i := range queryWhereStack {
-		if i != len(queryWhereStack)-1 {
-			queryWhere += strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(i+1), 1)
-		} else {
-			queryWhere += strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(i+1), 1)
-			queryWhere = strings.Replace(queryWhere, "?", "$"+strconv.Itoa(i+2), 1)
+		for strings.Contains(queryWhereStack[i], "?") {
+			index++
+			queryWhereStack[i] = strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(index), 1)
 		}
+		queryWhere += queryWhereStack[i]
 	}
 	// Last stack record
 	b.lastStackIndex = len(b.values)
@@ -137,6 +279,14 @@ func (b *findProductBuilder) sort() {
         "price": "\"p\".\"price\"",
     }
  
+    // relevance is only known when searching, the best match comes first
+    if strings.ToLower(b.req.OrderBy) == "relevance" && b.searchIndex > 0 {
+        b.req.OrderBy = "relevance"
+        b.query += fmt.Sprintf(`
+        ORDER BY (ts_rank("p"."search_vector", %s) + word_similarity(LOWER($%d), LOWER("p"."title"))) DESC, "p"."id" ASC`, searchTsQuery(b.searchIndex), b.searchIndex)
+        return
+    }
+ 
     if orderByMap[strings.ToLower(b.req.OrderBy)] == "" {
         b.req.OrderBy = orderByMap["title"]
     } else {
@@ -177,6 +327,7 @@ func (b *findProductBuilder) resetQuery() {
 	b.query = ""
 	b.values = make([]any, 0)
 	b.lastStackIndex = 0
+	b.searchIndex = 0
 }
 func (b *findProductBuilder) Result() []*products.Products {
 	_, cancel := context.WithTimeout(context.Background(), time.Second*15)
@@ -185,6 +336,9 @@ func (b *findProductBuilder) Result() []*products.Products {
 	bytes := make([]byte, 0)
 	productsData := make([]*products.Products, 0)
 
+	// reset on every path, count is built on the same builder after find
+	defer b.resetQuery()
+
 	if err := b.db.Get(&bytes, b.query, b.values...); err != nil {
 		log.Printf("find products failed: %v\n", err)
 		return make([]*products.Products, 0)
@@ -194,19 +348,19 @@ func (b *findProductBuilder) Result() []*products.Products {
 		log.Printf("unmarshal products failed: %v\n", err)
 		return make([]*products.Products, 0)
 	}
-	b.resetQuery()
 	return productsData
 }
 func (b *findProductBuilder) Count() int {
 	_, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
 
+	defer b.resetQuery()
+
 	var count int
 	if err := b.db.Get(&count, b.query, b.values...); err != nil {
 		log.Printf("count products failed: %v\n", err)
 		return 0
 	}
-	b.resetQuery()
 	return count
 }
 func (b *findProductBuilder) PrintQuery() {
@@ -214,6 +368,9 @@ func (b *findProductBuilder) PrintQuery() {
 	fmt.Println(b.query)
 }
 
+// likeEscaper keeps % and _ of the search text as they are in LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type findProductEngineer struct {
 	builder IFindProductBuilder
 }
@@ -0,0 +1,244 @@
+package productsPatterns
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/products"
+)
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// checkPlaceholders makes sure the query uses every value and nothing more
+func checkPlaceholders(t *testing.T, name string, b *findProductBuilder) {
+	t.Helper()
+
+	if strings.Contains(b.query, "?") {
+		t.Errorf("%s expected: no ?, got: %v", name, b.query)
+	}
+	used := make(map[int]bool)
+	for _, match := range placeholder.FindAllStringSubmatch(b.query, -1) {
+		index, _ := strconv.Atoi(match[1])
+		used[index] = true
+	}
+	for i := 1; i <= len(b.values); i++ {
+		if !used[i] {
+			t.Errorf("%s expected: $%d in query, got: %v", name, i, b.query)
+		}
+	}
+	if used[len(b.values)+1] {
+		t.Errorf("%s expected: %d values, got: $%d in query", name, len(b.values), len(b.values)+1)
+	}
+}
+
+func testFilter(filter *products.ProductFilter) *products.ProductFilter {
+	if filter.PaginationReq == nil {
+		filter.PaginationReq = &entities.PaginationReq{Page: 1, Limit: 10}
+	}
+	if filter.SortReq == nil {
+		filter.SortReq = &entities.SortReq{}
+	}
+	return filter
+}
+
+type testFindProductQuery struct {
+	name      string
+	filter    *products.ProductFilter
+	values    []any
+	fragments []string
+	count     []any // values of the count query
+}
+
+func TestFindProductQuery(t *testing.T) {
+	noImages := false
+	tests := []testFindProductQuery{
+		{
+			name:      "no filter",
+			filter:    &products.ProductFilter{},
+			values:    []any{0, 10},
+			fragments: []string{`OFFSET $1 LIMIT $2`},
+			count:     []any{},
+		},
+		{
+			name: "search of only spaces is no search",
+			filter: &products.ProductFilter{
+				Search:      "   ",
+				CategoryIds: []int{1},
+				SortReq:     &entities.SortReq{OrderBy: "relevance"},
+			},
+			values: []any{1, 0, 10},
+			fragments: []string{
+				`"pc"."category_id" IN ($1)`,
+				`ORDER BY "p"."title" ASC`,
+				`OFFSET $2 LIMIT $3`,
+			},
+			count: []any{1},
+		},
+		{
+			name: "every filter without search",
+			filter: &products.ProductFilter{
+				Id:            "P000001",
+				CategoryIds:   []int{1, 2},
+				MinPrice:      "10.00",
+				MaxPrice:      "20.00",
+				CreatedAfter:  "2024-01-01",
+				CreatedBefore: "2024-01-31",
+				HasImages:     &noImages,
+				PaginationReq: &entities.PaginationReq{Page: 3, Limit: 20},
+			},
+			values: []any{"P000001", 1, 2, "10.00", "20.00", "2024-01-01", "2024-01-31", 40, 20},
+			fragments: []string{
+				`"p"."id" = $1`,
+				`"pc"."category_id" IN ($2, $3)`,
+				`"p"."price" >= $4::NUMERIC`,
+				`"p"."price" <= $5::NUMERIC`,
+				`"p"."created_at" >= $6::DATE`,
+				`"p"."created_at" < $7::DATE + 1`,
+				`AND NOT EXISTS (`,
+				`OFFSET $8 LIMIT $9`,
+			},
+			count: []any{"P000001", 1, 2, "10.00", "20.00", "2024-01-01", "2024-01-31"},
+		},
+		{
+			name: "search is numbered before the other filters",
+			filter: &products.ProductFilter{
+				Id:          "P000001",
+				Search:      " Coffee ",
+				CategoryIds: []int{3},
+				MaxPrice:    "99.50",
+			},
+			values: []any{"Coffee", "P000001", "%coffee%", "%coffee%", 3, "99.50", 0, 10},
+			fragments: []string{
+				`ts_headline('english', "p"."title", (websearch_to_tsquery('english', $1) || websearch_to_tsquery('simple', $1))`,
+				`"p"."id" = $2`,
+				`"p"."search_vector" @@ (websearch_to_tsquery('english', $1) || websearch_to_tsquery('simple', $1))`,
+				`LOWER("p"."title") LIKE $3`,
+				`LOWER("p"."description") LIKE $4`,
+				`LOWER($1) <% LOWER("p"."title")`,
+				`"pc"."category_id" IN ($5)`,
+				`"p"."price" <= $6::NUMERIC`,
+				`OFFSET $7 LIMIT $8`,
+			},
+			count: []any{"Coffee", "P000001", "%coffee%", "%coffee%", 3, "99.50"},
+		},
+	}
+
+	for _, test := range tests {
+		b := FindProductBuilder(nil, testFilter(test.filter)).(*findProductBuilder)
+		FindProductEngineer(b).FindProduct()
+
+		if !reflect.DeepEqual(b.values, test.values) {
+			t.Errorf("%s expected: %v, got: %v", test.name, test.values, b.values)
+		}
+		for _, fragment := range test.fragments {
+			if !strings.Contains(b.query, fragment) {
+				t.Errorf("%s expected: %v in query, got: %v", test.name, fragment, b.query)
+			}
+		}
+		checkPlaceholders(t, test.name, b)
+		if len(test.filter.Search) > 0 && strings.Trim(test.filter.Search, " ") == "" &&
+			(strings.Contains(b.query, "LIKE") || strings.Contains(b.query, "websearch_to_tsquery")) {
+			t.Errorf("%s expected: no search, got: %v", test.name, b.query)
+		}
+
+		// count is built on the same builder after find
+		b.resetQuery()
+		FindProductEngineer(b).CountProduct()
+
+		if !reflect.DeepEqual(b.values, test.count) {
+			t.Errorf("%s count expected: %v, got: %v", test.name, test.count, b.values)
+		}
+		if strings.Contains(b.query, "OFFSET") || strings.Contains(b.query, "ORDER BY") {
+			t.Errorf("%s count expected: no paging, got: %v", test.name, b.query)
+		}
+		checkPlaceholders(t, test.name+" count", b)
+	}
+}
+
+type testLikeEscape struct {
+	search   string
+	expected string
+}
+
+func TestLikeEscape(t *testing.T) {
+	tests := []testLikeEscape{
+		{search: "Coffee", expected: "%coffee%"},
+		{search: "50%", expected: `%50\%%`},
+		{search: "a_b", expected: `%a\_b%`},
+		{search: `c:\tmp`, expected: `%c:\\tmp%`},
+		{search: `100%_\`, expected: `%100\%\_\\%`},
+		{search: "กาแฟ", expected: "%กาแฟ%"},
+	}
+
+	for _, test := range tests {
+		b := FindProductBuilder(nil, testFilter(&products.ProductFilter{Search: test.search})).(*findProductBuilder)
+		FindProductEngineer(b).CountProduct()
+
+		// the search text is sent as it is for full-text, only LIKE is escaped
+		if len(b.values) != 3 || b.values[0] != test.search {
+			t.Errorf("%q expected: search then 2 LIKE values, got: %v", test.search, b.values)
+			continue
+		}
+		if b.values[1] != test.expected || b.values[2] != test.expected {
+			t.Errorf("%q expected: %v, got: %v", test.search, test.expected, b.values[1:])
+		}
+	}
+}
+
+type testFindProductSort struct {
+	search   string
+	orderBy  string
+	sort     string
+	expected string
+}
+
+func TestFindProductSort(t *testing.T) {
+	tests := []testFindProductSort{
+		{
+			search:   "coffee",
+			orderBy:  "relevance",
+			expected: `ORDER BY (ts_rank("p"."search_vector", (websearch_to_tsquery('english', $1) || websearch_to_tsquery('simple', $1))) + word_similarity(LOWER($1), LOWER("p"."title"))) DESC, "p"."id" ASC`,
+		},
+		// the sort of the request is not used by relevance
+		{
+			search:   "coffee",
+			orderBy:  "RELEVANCE",
+			sort:     "ASC",
+			expected: `+ word_similarity(LOWER($1), LOWER("p"."title"))) DESC, "p"."id" ASC`,
+		},
+		// relevance is not known without search
+		{
+			orderBy:  "relevance",
+			expected: `ORDER BY "p"."title" ASC`,
+		},
+		{
+			search:   "coffee",
+			orderBy:  "price",
+			sort:     "desc",
+			expected: `ORDER BY "p"."price" DESC`,
+		},
+		{
+			orderBy:  "unknown",
+			sort:     "unknown",
+			expected: `ORDER BY "p"."title" ASC`,
+		},
+	}
+
+	for _, test := range tests {
+		filter := testFilter(&products.ProductFilter{
+			Search:  test.search,
+			SortReq: &entities.SortReq{OrderBy: test.orderBy, Sort: test.sort},
+		})
+		b := FindProductBuilder(nil, filter).(*findProductBuilder)
+		FindProductEngineer(b).FindProduct()
+
+		if !strings.Contains(b.query, test.expected) {
+			t.Errorf("%s %s expected: %v in query, got: %v", test.orderBy, test.sort, test.expected, b.query)
+		}
+		checkPlaceholders(t, test.orderBy, b)
+	}
+}
@@ -58,17 +58,24 @@ func (b *insertProductBuilder) insertProduct() error {
 	INSERT INTO "products" (
 		"title",
 		"description",
-		"price"
+		"price",
+		"options"
 	)
-	VALUES ($1, $2, $3)
+	VALUES ($1, $2, $3, $4)
 		RETURNING "id";`
 
+	// options can't be null in the table
+	if b.req.Options == nil {
+		b.req.Options = make([]*products.ProductOption, 0)
+	}
+
 	if err := b.tx.QueryRowxContext(
 		ctx,
 		query,
 		b.req.Title,
 		b.req.Description,
 		b.req.Price,
+		b.req.Options,
 	).Scan(&b.req.Id); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("insert product failed: %v", err)
@@ -83,15 +90,35 @@ func (b *insertProductBuilder) insertCategory() error {
 	query := `
 	INSERT INTO "products_categories" (
 		"product_id",
-		"category_id"
+		"category_id",
+		"position"
 	)
-	VALUES ($1, $2);`
+	VALUES`
+
+	// categories are checked by Products.CheckCategories, the first one is the primary category
+	valueStack := make([]any, 0)
+	var index int
+	for i := range b.req.Categories {
+		valueStack = append(valueStack,
+			b.req.Id,
+			b.req.Categories[i].Id,
+			i,
+		)
+
+		if i != len(b.req.Categories)-1 {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d),`, index+1, index+2, index+3)
+		} else {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d);`, index+1, index+2, index+3)
+		}
+		index += 3
+	}
 
 	if _, err := b.tx.ExecContext(
 		ctx,
 		query,
-		b.req.Id,
-		b.req.Category.Id,
+		valueStack...,
 	); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("insert products_categories failed: %v", err)
@@ -20,6 +20,7 @@ type IUpdateProductBuilder interface {
 	updateTitleQuery()
 	updateDescriptionQuery()
 	updatePriceQuery()
+	updateOptionsQuery()
 	updateCategory() error
 	insertImages() error
 	getOldImages() []*entities.Image
@@ -114,8 +115,25 @@ func (b *updateProductBuilder) updatePriceQuery() {
 	}
 }
 
+// options are replaced only when they are sent, an empty array removes them
+func (b *updateProductBuilder) updateOptionsQuery() {
+	if b.req.Options != nil {
+		b.values = append(b.values, b.req.Options)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"options" = $%d`, b.lastStackIndex))
+	}
+}
+
+// updateCategory replaces every category when categories is sent,
+// when only category is sent (clients of one category) the primary category is replaced and the others are kept
 func (b *updateProductBuilder) updateCategory() error {
 
+	if len(b.req.Categories) > 0 {
+		return b.replaceCategories()
+	}
+
 	if b.req.Category == nil {
 		return nil
 	}
@@ -123,16 +141,35 @@ func (b *updateProductBuilder) updateCategory() error {
 		return nil
 	}
 
+	// the category is removed from its old position so it isn't repeated
+	queryDelete := `
+	DELETE FROM "products_categories"
+	WHERE "product_id" = $1
+	AND ("position" = 0 OR "category_id" = $2);`
+
+	if _, err := b.tx.ExecContext(
+		context.Background(),
+		queryDelete,
+		b.req.Id,
+		b.req.Category.Id,
+	); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("delete products_categories failed: %v", err)
+	}
+
 	query := `
-	UPDATE "products_categories" SET
-		"category_id" = $1
-	WHERE "product_id" = $2;`
+	INSERT INTO "products_categories" (
+		"product_id",
+		"category_id",
+		"position"
+	)
+	VALUES ($1, $2, 0);`
 
 	if _, err := b.tx.ExecContext(
 		context.Background(),
 		query,
-		b.req.Category.Id,
 		b.req.Id,
+		b.req.Category.Id,
 	); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("update products_categories failed: %v", err)
@@ -140,6 +177,58 @@ func (b *updateProductBuilder) updateCategory() error {
 	return nil
 }
 
+func (b *updateProductBuilder) replaceCategories() error {
+	queryDelete := `
+	DELETE FROM "products_categories"
+	WHERE "product_id" = $1;`
+
+	if _, err := b.tx.ExecContext(
+		context.Background(),
+		queryDelete,
+		b.req.Id,
+	); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("delete products_categories failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "products_categories" (
+		"product_id",
+		"category_id",
+		"position"
+	)
+	VALUES`
+
+	valueStack := make([]any, 0)
+	var index int
+	for i := range b.req.Categories {
+		valueStack = append(valueStack,
+			b.req.Id,
+			b.req.Categories[i].Id,
+			i,
+		)
+
+		if i != len(b.req.Categories)-1 {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d),`, index+1, index+2, index+3)
+		} else {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d);`, index+1, index+2, index+3)
+		}
+		index += 3
+	}
+
+	if _, err := b.tx.ExecContext(
+		context.Background(),
+		query,
+		valueStack...,
+	); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert products_categories failed: %v", err)
+	}
+	return nil
+}
+
 func (b *updateProductBuilder) insertImages() error {
 	query := `
 	INSERT INTO "images" (
@@ -325,6 +414,7 @@ func (en *updateProductEngineer) sumQueryFields() {
 	en.builder.updateTitleQuery()
 	en.builder.updateDescriptionQuery()
 	en.builder.updatePriceQuery()
+	en.builder.updateOptionsQuery()
 
 	fields := en.builder.getQueryFields()
 
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/NatthawutSK/ri-shop/config"
 	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/files"
 	"github.com/NatthawutSK/ri-shop/modules/files/filesUsecases"
 	"github.com/NatthawutSK/ri-shop/modules/products"
 	"github.com/NatthawutSK/ri-shop/modules/products/productsPatterns"
@@ -20,6 +23,13 @@ type IProductsRepository interface{
 	InsertProduct(req *products.Products) (*products.Products, error)
 	UpdateProduct(req *products.Products) (*products.Products, error)
 	DeleteProduct(productId string) error
+	FindStock(productId string) (*products.ProductStock, error)
+	AdjustStock(req *products.StockAdjustReq) error
+	FindVariants(productId string) ([]*products.ProductVariant, error)
+	FindOneVariant(productId, variantId string) (*products.ProductVariant, error)
+	InsertVariant(req *products.ProductVariant) (*products.ProductVariant, error)
+	UpdateVariant(req *products.ProductVariantUpdate) (*products.ProductVariant, error)
+	DeleteVariant(productId, variantId string) error
 }
 
 type productsRepository struct {
@@ -56,8 +66,23 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 					FROM "categories" "c"
 						LEFT JOIN "products_categories" "pc" ON "pc"."category_id" = "c"."id"
 					WHERE "pc"."product_id" = "p"."id"
+					ORDER BY "pc"."position" ASC, "c"."id" ASC
+					LIMIT 1
 				) AS "ct"
 			) AS "category",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("cst")), '[]'::json)
+				FROM (
+					SELECT
+						"c"."id",
+						"c"."title"
+					FROM "categories" "c"
+						LEFT JOIN "products_categories" "pc" ON "pc"."category_id" = "c"."id"
+					WHERE "pc"."product_id" = "p"."id"
+					ORDER BY "pc"."position" ASC, "c"."id" ASC
+				) AS "cst"
+			) AS "categories",
 			"p"."created_at",
 			"p"."updated_at",
 			(
@@ -71,7 +96,39 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 					FROM "images" "i"
 					WHERE "i"."product_id" = "p"."id"
 				) AS "it"
-			) AS "images"
+			) AS "images",
+			"p"."options",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("vt")), '[]'::json)
+				FROM (
+					SELECT
+						"v"."id",
+						"v"."product_id",
+						"v"."sku",
+						"v"."options",
+						"v"."price",
+						"v"."is_active",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("vit")), '[]'::json)
+							FROM (
+								SELECT
+									"vi"."id",
+									"vi"."filename",
+									"vi"."url"
+								FROM "product_variant_images" "vi"
+								WHERE "vi"."variant_id" = "v"."id"
+							) AS "vit"
+						) AS "images",
+						"v"."created_at",
+						"v"."updated_at"
+					FROM "product_variants" "v"
+					WHERE "v"."product_id" = "p"."id"
+					AND "v"."is_active" = TRUE
+					ORDER BY "v"."created_at" ASC
+				) AS "vt"
+			) AS "variants"
 		FROM "products" "p"
 		WHERE "p"."id" = $1
 		LIMIT 1
@@ -160,3 +217,481 @@ func (r *productsRepository) DeleteProduct(productId string) error {
 	return nil
 }
 
+func (r *productsRepository) FindStock(productId string) (*products.ProductStock, error) {
+	query := `
+	SELECT
+		"id" AS "product_id",
+		"stock"
+	FROM "products"
+	WHERE "id" = $1;`
+
+	stock := &products.ProductStock{
+		Variants:  make([]*products.VariantStock, 0),
+		Movements: make([]*products.StockMovement, 0),
+	}
+	if err := r.db.Get(stock, query, productId); err != nil {
+		return nil, fmt.Errorf("get product stock failed: %v", err)
+	}
+
+	queryMovements := `
+	SELECT
+		"id",
+		"product_id",
+		"variant_id",
+		"order_id",
+		"type",
+		"qty",
+		"balance",
+		"note",
+		"created_by",
+		"created_at"
+	FROM "stock_movements"
+	WHERE "product_id" = $1
+	ORDER BY "created_at" DESC;`
+
+	if err := r.db.Select(&stock.Movements, queryMovements, productId); err != nil {
+		return nil, fmt.Errorf("get stock movements failed: %v", err)
+	}
+
+	queryVariants := `
+	SELECT
+		"id",
+		"sku",
+		"is_active",
+		"stock"
+	FROM "product_variants"
+	WHERE "product_id" = $1
+	ORDER BY "created_at" ASC;`
+
+	if err := r.db.Select(&stock.Variants, queryVariants, productId); err != nil {
+		return nil, fmt.Errorf("get variants stock failed: %v", err)
+	}
+
+	return stock, nil
+}
+
+func (r *productsRepository) AdjustStock(req *products.StockAdjustReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	// lock the product (or variant) row so a checkout can't read the old stock in between
+	queryStock := `
+	SELECT
+		"stock"
+	FROM "products"
+	WHERE "id" = $1
+	FOR UPDATE;`
+	queryUpdate := `
+	UPDATE "products" SET
+		"stock" = "stock" + $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+	stockArgs := []any{req.ProductId}
+	var variantId *string
+	if req.VariantId != "" {
+		queryStock = `
+	SELECT
+		"stock"
+	FROM "product_variants"
+	WHERE "id"::TEXT = $1
+	AND "product_id" = $2
+	FOR UPDATE;`
+		queryUpdate = `
+	UPDATE "product_variants" SET
+		"stock" = "stock" + $1
+	WHERE "id"::TEXT = $2
+	RETURNING "stock";`
+		stockArgs = []any{req.VariantId, req.ProductId}
+		variantId = &req.VariantId
+	}
+
+	var stock int
+	if err := tx.GetContext(ctx, &stock, queryStock, stockArgs...); err != nil {
+		tx.Rollback()
+		if req.VariantId != "" {
+			return fmt.Errorf("variant %s not found", req.VariantId)
+		}
+		return fmt.Errorf("get product stock failed: %v", err)
+	}
+
+	if stock+req.Qty < 0 {
+		tx.Rollback()
+		return fmt.Errorf("stock is not enough, current stock is %d", stock)
+	}
+
+	if err := tx.QueryRowxContext(ctx, queryUpdate, req.Qty, stockArgs[0]).Scan(&stock); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update product stock failed: %v", err)
+	}
+
+	queryMovement := `
+	INSERT INTO "stock_movements" (
+		"product_id",
+		"variant_id",
+		"type",
+		"qty",
+		"balance",
+		"note",
+		"created_by"
+	)
+	VALUES ($1, $2, 'adjust', $3, $4, $5, $6);`
+
+	if _, err := tx.ExecContext(ctx, queryMovement, req.ProductId, variantId, req.Qty, stock, req.Note, req.CreatedBy); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert stock movement failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return nil
+}
+
+// selectVariantQuery returns the variants as json rows, inactive variants are included for admins
+const selectVariantQuery = `
+	SELECT
+		to_jsonb("vt")
+	FROM (
+		SELECT
+			"v"."id",
+			"v"."product_id",
+			"v"."sku",
+			"v"."options",
+			"v"."price",
+			"v"."is_active",
+			"v"."stock",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("vit")), '[]'::json)
+				FROM (
+					SELECT
+						"vi"."id",
+						"vi"."filename",
+						"vi"."url"
+					FROM "product_variant_images" "vi"
+					WHERE "vi"."variant_id" = "v"."id"
+				) AS "vit"
+			) AS "images",
+			"v"."created_at",
+			"v"."updated_at"
+		FROM "product_variants" "v"
+		WHERE "v"."product_id" = $1`
+
+func (r *productsRepository) FindVariants(productId string) ([]*products.ProductVariant, error) {
+	query := selectVariantQuery + `
+		ORDER BY "v"."created_at" ASC
+	) AS "vt";`
+
+	rows := make([][]byte, 0)
+	if err := r.db.Select(&rows, query, productId); err != nil {
+		return nil, fmt.Errorf("select variants failed: %v", err)
+	}
+
+	variants := make([]*products.ProductVariant, 0)
+	for _, row := range rows {
+		variant := new(products.ProductVariant)
+		if err := json.Unmarshal(row, variant); err != nil {
+			return nil, fmt.Errorf("unmarshal variant failed: %v", err)
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+func (r *productsRepository) FindOneVariant(productId, variantId string) (*products.ProductVariant, error) {
+	query := selectVariantQuery + `
+		AND "v"."id"::TEXT = $2
+	) AS "vt";`
+
+	row := make([]byte, 0)
+	if err := r.db.Get(&row, query, productId, variantId); err != nil {
+		return nil, fmt.Errorf("variant %s not found", variantId)
+	}
+
+	variant := new(products.ProductVariant)
+	if err := json.Unmarshal(row, variant); err != nil {
+		return nil, fmt.Errorf("unmarshal variant failed: %v", err)
+	}
+	return variant, nil
+}
+
+func (r *productsRepository) InsertVariant(req *products.ProductVariant) (*products.ProductVariant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "product_variants" (
+		"product_id",
+		"sku",
+		"options",
+		"price",
+		"is_active"
+	)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING "id";`
+
+	if err := tx.QueryRowxContext(
+		ctx,
+		query,
+		req.ProductId,
+		req.Sku,
+		req.Options,
+		req.Price,
+		req.IsActive,
+	).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		return nil, variantErr("insert variant", req.Sku, err)
+	}
+
+	if err := insertVariantImages(ctx, tx, req.Id, req.Images); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return r.FindOneVariant(req.ProductId, req.Id)
+}
+
+func (r *productsRepository) UpdateVariant(req *products.ProductVariantUpdate) (*products.ProductVariant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	oldVariant, err := r.FindOneVariant(req.ProductId, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	UPDATE "product_variants" SET`
+
+	queryWhereStack := make([]string, 0)
+	values := make([]any, 0)
+	lastIndex := 1
+
+	if req.Sku != nil {
+		values = append(values, *req.Sku)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"sku" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.Options != nil {
+		values = append(values, *req.Options)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"options" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.ClearPrice {
+		queryWhereStack = append(queryWhereStack, `
+		"price" = NULL?`)
+	} else if req.Price != nil {
+		values = append(values, *req.Price)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"price" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.IsActive != nil {
+		values = append(values, *req.IsActive)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"is_active" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if len(queryWhereStack) == 0 && req.Images == nil {
+		return nil, fmt.Errorf("nothing to update")
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	if len(queryWhereStack) > 0 {
+		values = append(values, req.Id)
+
+		queryClose := fmt.Sprintf(`
+	WHERE "id" = $%d;`, lastIndex)
+
+		for i := range queryWhereStack {
+			if i != len(queryWhereStack)-1 {
+				query += strings.Replace(queryWhereStack[i], "?", ",", 1)
+			} else {
+				query += strings.Replace(queryWhereStack[i], "?", "", 1)
+			}
+		}
+		query += queryClose
+
+		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+			tx.Rollback()
+			sku := oldVariant.Sku
+			if req.Sku != nil {
+				sku = *req.Sku
+			}
+			return nil, variantErr("update variant", sku, err)
+		}
+	}
+
+	// images are replaced only when they are sent, an empty array removes them
+	if req.Images != nil {
+		if _, err := tx.ExecContext(ctx, `
+	DELETE FROM "product_variant_images"
+	WHERE "variant_id" = $1;`, req.Id); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("delete variant images failed: %v", err)
+		}
+
+		if err := insertVariantImages(ctx, tx, req.Id, req.Images); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	// files are removed after the rows, a failed update keeps the old images
+	if req.Images != nil {
+		if err := r.deleteImageFiles(oldVariant.Images); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.FindOneVariant(req.ProductId, req.Id)
+}
+
+func (r *productsRepository) DeleteVariant(productId, variantId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	variant, err := r.FindOneVariant(productId, variantId)
+	if err != nil {
+		return err
+	}
+
+	// order lines keep the variant in their snapshot, variant_id of the lines is set to null.
+	// Stock movements are kept for the history, a variant that has any is deactivated instead.
+	query := `
+	DELETE FROM "product_variants" "v"
+	WHERE "v"."id" = $1
+	AND NOT EXISTS (
+		SELECT
+			1
+		FROM "stock_movements" "sm"
+		WHERE "sm"."variant_id" = "v"."id"
+	);`
+
+	result, err := r.db.ExecContext(ctx, query, variant.Id)
+	if err != nil {
+		return fmt.Errorf("delete variant failed: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected failed: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("variant has stock movements, deactivate it instead")
+	}
+
+	return r.deleteImageFiles(variant.Images)
+}
+
+func insertVariantImages(ctx context.Context, tx *sqlx.Tx, variantId string, images []*entities.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO "product_variant_images" (
+		"filename",
+		"url",
+		"variant_id"
+	)
+	VALUES`
+
+	valueStack := make([]any, 0)
+	var index int
+	for i := range images {
+		valueStack = append(valueStack,
+			images[i].FileName,
+			images[i].Url,
+			variantId,
+		)
+
+		if i != len(images)-1 {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d),`, index+1, index+2, index+3)
+		} else {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d);`, index+1, index+2, index+3)
+		}
+		index += 3
+	}
+
+	if _, err := tx.ExecContext(ctx, query, valueStack...); err != nil {
+		return fmt.Errorf("insert variant images failed: %v", err)
+	}
+	return nil
+}
+
+// variantErr tells which unique rule the variant broke
+func variantErr(action, sku string, err error) error {
+	if strings.Contains(err.Error(), "product_variants_sku_key") {
+		return fmt.Errorf("variant sku %s already exists", sku)
+	}
+	if strings.Contains(err.Error(), "product_variants_product_id_options_key") {
+		return fmt.Errorf("variant with the same options already exists")
+	}
+	return fmt.Errorf("%s failed: %v", action, err)
+}
+
+func (r *productsRepository) deleteImageFiles(images []*entities.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	deleteFileReq := make([]*files.DeleteFileReq, 0)
+	for _, img := range images {
+		parsedURL, err := url.Parse(img.Url)
+		if err != nil {
+			continue
+		}
+
+		// path in the bucket, without the bucket name
+		path := strings.TrimPrefix(parsedURL.Path, fmt.Sprintf("/%s/", r.cfg.App().GCPBucket()))
+		deleteFileReq = append(deleteFileReq, &files.DeleteFileReq{
+			Destination: path,
+		})
+	}
+
+	if err := r.fileUsecase.DeleteFileOnGCP(deleteFileReq); err != nil {
+		return fmt.Errorf("delete variant images failed: %v", err)
+	}
+	return nil
+}
@@ -1,42 +1,76 @@
 package productsUsecases
 
 import (
+	"fmt"
 	"math"
+	"strings"
 
+	"github.com/NatthawutSK/ri-shop/modules/currencies"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesRepositories"
 	"github.com/NatthawutSK/ri-shop/modules/entities"
 	"github.com/NatthawutSK/ri-shop/modules/products"
 	"github.com/NatthawutSK/ri-shop/modules/products/productsRepositories"
 )
 
 type IProductsUsecase interface{
-	FindOneProduct(productId string) (*products.Products, error)
-	FindProduct(req *products.ProductFilter) *entities.PaginateRes
+	FindOneProduct(productId, currency string) (*products.Products, error)
+	FindProduct(req *products.ProductFilter) (*entities.PaginateRes, error)
 	AddProduct(req *products.Products) (*products.Products, error)
 	UpdateProduct(req *products.Products) (*products.Products, error)
 	DeleteProduct(productId string) error
+	FindStock(productId string) (*products.ProductStock, error)
+	AdjustStock(req *products.StockAdjustReq) (*products.ProductStock, error)
+	FindVariants(productId string) ([]*products.ProductVariant, error)
+	AddVariant(req *products.ProductVariant) (*products.ProductVariant, error)
+	UpdateVariant(req *products.ProductVariantUpdate) (*products.ProductVariant, error)
+	DeleteVariant(productId, variantId string) error
 }
 
 type productsUsecase struct {
 	productsRepository productsRepositories.IProductsRepository
+	currenciesRepository currenciesRepositories.ICurrenciesRepository
 }
 
-func ProductsUsecase(productsRepository productsRepositories.IProductsRepository) IProductsUsecase {
+func ProductsUsecase(productsRepository productsRepositories.IProductsRepository, currenciesRepository currenciesRepositories.ICurrenciesRepository) IProductsUsecase {
 	return &productsUsecase{
 		productsRepository: productsRepository,
+		currenciesRepository: currenciesRepository,
 	}
 }
 
-func (u *productsUsecase) FindOneProduct(productId string) (*products.Products, error) {
+// FindOneProduct returns the price in baht, display_price is added when currency is sent
+func (u *productsUsecase) FindOneProduct(productId, currency string) (*products.Products, error) {
+	displayCurrency, err := u.findCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
 	product, err := u.productsRepository.FindOneProduct(productId)
 	if err != nil {
 		return nil, err
 	}
+	if displayCurrency != nil {
+		displayPrice(product, displayCurrency)
+	}
 	return product, nil
 }
 
 
-func (u *productsUsecase) FindProduct(req *products.ProductFilter) *entities.PaginateRes {
+func (u *productsUsecase) FindProduct(req *products.ProductFilter) (*entities.PaginateRes, error) {
+	displayCurrency, err := u.findCurrency(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	products, count := u.productsRepository.FindProduct(req)
+	for i := range products {
+		if displayCurrency != nil {
+			displayPrice(products[i], displayCurrency)
+		}
+		if products[i].Highlight != nil {
+			products[i].Highlight.Mark(req.Search)
+		}
+	}
 	return &entities.PaginateRes{
 		Data: products,
 		TotalItem: count,
@@ -44,11 +78,43 @@ func (u *productsUsecase) FindProduct(req *products.ProductFilter) *entities.Pag
 		Limit: req.Limit,
 		TotalPage: int(math.Ceil(float64(count) / float64(req.Limit))),
 
-	}
+	}, nil
 	
 }
 
+// displayPrice converts the price of the product and of every variant
+func displayPrice(product *products.Products, currency *currencies.Currency) {
+	product.DisplayPrice = currency.Price(product.Price)
+	for _, variant := range product.Variants {
+		variant.DisplayPrice = currency.Price(product.VariantPrice(variant))
+	}
+}
+
+// findCurrency returns nil when prices are shown in baht only
+func (u *productsUsecase) findCurrency(code string) (*currencies.Currency, error) {
+	code = strings.ToUpper(strings.Trim(code, " "))
+	if code == "" {
+		return nil, nil
+	}
+
+	currency, err := u.currenciesRepository.FindOneCurrency(code)
+	if err != nil {
+		return nil, fmt.Errorf("currency %s is not supported", code)
+	}
+	if err := currency.Check(); err != nil {
+		return nil, err
+	}
+	return currency, nil
+}
+
 func (u *productsUsecase) AddProduct(req *products.Products) (*products.Products, error) {
+	if err := req.CheckCategories(); err != nil {
+		return nil, err
+	}
+	if err := req.CheckOptions(); err != nil {
+		return nil, err
+	}
+
 	product, err := u.productsRepository.InsertProduct(req)
 	if err != nil {
 		return nil, err
@@ -57,6 +123,27 @@ func (u *productsUsecase) AddProduct(req *products.Products) (*products.Products
 }
 
 func (u *productsUsecase) UpdateProduct(req *products.Products) (*products.Products, error) {
+	if err := req.CheckCategories(); err != nil {
+		return nil, err
+	}
+
+	if req.Options != nil {
+		if err := req.CheckOptions(); err != nil {
+			return nil, err
+		}
+
+		// every variant, also the inactive ones, must still fit the new options
+		variants, err := u.productsRepository.FindVariants(req.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, variant := range variants {
+			if err := req.CheckVariantOptions(variant.Options); err != nil {
+				return nil, fmt.Errorf("options are used by variant %s, %v", variant.Sku, err)
+			}
+		}
+	}
+
 	product, err := u.productsRepository.UpdateProduct(req)
 	if err != nil {
 		return nil, err
@@ -69,4 +156,84 @@ func (u *productsUsecase) DeleteProduct(productId string) error {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func (u *productsUsecase) FindStock(productId string) (*products.ProductStock, error) {
+	stock, err := u.productsRepository.FindStock(productId)
+	if err != nil {
+		return nil, err
+	}
+	return stock, nil
+}
+
+func (u *productsUsecase) AdjustStock(req *products.StockAdjustReq) (*products.ProductStock, error) {
+	if err := u.productsRepository.AdjustStock(req); err != nil {
+		return nil, err
+	}
+
+	stock, err := u.productsRepository.FindStock(req.ProductId)
+	if err != nil {
+		return nil, err
+	}
+	return stock, nil
+}
+
+func (u *productsUsecase) FindVariants(productId string) ([]*products.ProductVariant, error) {
+	if _, err := u.productsRepository.FindOneProduct(productId); err != nil {
+		return nil, fmt.Errorf("product %s not found", productId)
+	}
+	return u.productsRepository.FindVariants(productId)
+}
+
+// AddVariant checks the options of the variant with the options of the product
+func (u *productsUsecase) AddVariant(req *products.ProductVariant) (*products.ProductVariant, error) {
+	product, err := u.productsRepository.FindOneProduct(req.ProductId)
+	if err != nil {
+		return nil, fmt.Errorf("product %s not found", req.ProductId)
+	}
+
+	req.Sku = strings.Trim(req.Sku, " ")
+	if req.Sku == "" {
+		return nil, fmt.Errorf("variant sku is required")
+	}
+	if req.Price != nil && *req.Price < 0 {
+		return nil, fmt.Errorf("variant price must not be negative")
+	}
+	if err := product.CheckVariantOptions(req.Options); err != nil {
+		return nil, err
+	}
+	if req.Images == nil {
+		req.Images = make([]*entities.Image, 0)
+	}
+
+	return u.productsRepository.InsertVariant(req)
+}
+
+func (u *productsUsecase) UpdateVariant(req *products.ProductVariantUpdate) (*products.ProductVariant, error) {
+	product, err := u.productsRepository.FindOneProduct(req.ProductId)
+	if err != nil {
+		return nil, fmt.Errorf("product %s not found", req.ProductId)
+	}
+
+	if req.Sku != nil {
+		sku := strings.Trim(*req.Sku, " ")
+		if sku == "" {
+			return nil, fmt.Errorf("variant sku is required")
+		}
+		req.Sku = &sku
+	}
+	if req.Price != nil && *req.Price < 0 {
+		return nil, fmt.Errorf("variant price must not be negative")
+	}
+	if req.Options != nil {
+		if err := product.CheckVariantOptions(*req.Options); err != nil {
+			return nil, err
+		}
+	}
+
+	return u.productsRepository.UpdateVariant(req)
+}
+
+func (u *productsUsecase) DeleteVariant(productId, variantId string) error {
+	return u.productsRepository.DeleteVariant(productId, variantId)
+}
@@ -0,0 +1,118 @@
+package reports
+
+import (
+	"fmt"
+	"time"
+
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+// StoreTimezone is the timezone created_at of orders is saved in
+const StoreTimezone = "Asia/Bangkok"
+
+// RevenueStatuses are statuses counted as sold, waiting orders are not paid yet and canceled orders are never paid
+var RevenueStatuses = []string{"shipping", "completed", "return_requested", "refunded"}
+
+type ReportFilter struct {
+	StartDate string `query:"start_date"` // YYYY-MM-DD in timezone, default 30 days before end_date
+	EndDate   string `query:"end_date"`   // YYYY-MM-DD in timezone, default today
+	Timezone  string `query:"tz"`         // IANA name, default Asia/Bangkok
+	Interval  string `query:"interval"`   // day, week, month
+	SortBy    string `query:"sort_by"`    // revenue, qty
+	Limit     int    `query:"limit"`
+}
+
+// Check validates the filter and fills the default values
+func (f *ReportFilter) Check() error {
+	if f.Timezone == "" {
+		f.Timezone = StoreTimezone
+	}
+	loc, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		return fmt.Errorf("tz is invalid")
+	}
+
+	if f.EndDate == "" {
+		f.EndDate = time.Now().In(loc).Format("2006-01-02")
+	}
+	end, err := time.Parse("2006-01-02", f.EndDate)
+	if err != nil {
+		return fmt.Errorf("end_date must be YYYY-MM-DD")
+	}
+	if f.StartDate == "" {
+		f.StartDate = end.AddDate(0, 0, -29).Format("2006-01-02")
+	}
+	start, err := time.Parse("2006-01-02", f.StartDate)
+	if err != nil {
+		return fmt.Errorf("start_date must be YYYY-MM-DD")
+	}
+	if start.After(end) {
+		return fmt.Errorf("start_date must be before end_date")
+	}
+
+	switch f.Interval {
+	case "":
+		f.Interval = "day"
+	case "day", "week", "month":
+	default:
+		return fmt.Errorf("interval must be day, week or month")
+	}
+
+	switch f.SortBy {
+	case "":
+		f.SortBy = "revenue"
+	case "revenue", "qty":
+	default:
+		return fmt.Errorf("sort_by must be revenue or qty")
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = 10
+	}
+	if f.Limit > 100 {
+		f.Limit = 100
+	}
+	return nil
+}
+
+// Summary is the totals of sold orders in the range
+type Summary struct {
+	StartDate         string        `json:"start_date" db:"-"`
+	EndDate           string        `json:"end_date" db:"-"`
+	Timezone          string        `json:"tz" db:"-"`
+	Orders            int           `json:"orders" db:"orders"`
+	Revenue           riMoney.Money `json:"revenue" db:"revenue"`
+	Refunded          riMoney.Money `json:"refunded" db:"refunded"`
+	NetRevenue        riMoney.Money `json:"net_revenue" db:"net_revenue"`
+	AverageOrderValue riMoney.Money `json:"average_order_value" db:"average_order_value"`
+}
+
+// RevenuePoint is one bucket of the revenue chart, buckets without orders are returned with zero
+type RevenuePoint struct {
+	Period     string        `json:"period" db:"period"` // first day of the bucket, YYYY-MM-DD
+	Orders     int           `json:"orders" db:"orders"`
+	Revenue    riMoney.Money `json:"revenue" db:"revenue"`
+	Refunded   riMoney.Money `json:"refunded" db:"refunded"`
+	NetRevenue riMoney.Money `json:"net_revenue" db:"net_revenue"`
+}
+
+type StatusCount struct {
+	Status string        `json:"status" db:"status"`
+	Orders int           `json:"orders" db:"orders"`
+	Total  riMoney.Money `json:"total" db:"total"`
+}
+
+// TopProduct is aggregated from the product snapshot of the orders, title is the latest title sold
+type TopProduct struct {
+	ProductId string        `json:"product_id" db:"product_id"`
+	Title     string        `json:"title" db:"title"`
+	Qty       int           `json:"qty" db:"qty"`
+	Revenue   riMoney.Money `json:"revenue" db:"revenue"`
+}
+
+type TopCategory struct {
+	CategoryId int           `json:"category_id" db:"category_id"`
+	Title      string        `json:"title" db:"title"`
+	Qty        int           `json:"qty" db:"qty"`
+	Revenue    riMoney.Money `json:"revenue" db:"revenue"`
+}
@@ -0,0 +1,167 @@
+package reportsHandlers
+
+import (
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/reports"
+	"github.com/NatthawutSK/ri-shop/modules/reports/reportsUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type reportsHandlerErrCode string
+
+const (
+	findSummaryErr     reportsHandlerErrCode = "reports-001"
+	findRevenueErr     reportsHandlerErrCode = "reports-002"
+	findStatusCountErr reportsHandlerErrCode = "reports-003"
+	findTopProductErr  reportsHandlerErrCode = "reports-004"
+	findTopCategoryErr reportsHandlerErrCode = "reports-005"
+)
+
+type IReportsHandler interface {
+	FindSummary(c *fiber.Ctx) error
+	FindRevenue(c *fiber.Ctx) error
+	FindStatusCount(c *fiber.Ctx) error
+	FindTopProduct(c *fiber.Ctx) error
+	FindTopCategory(c *fiber.Ctx) error
+}
+
+type reportsHandler struct {
+	cfg            config.IConfig
+	reportsUsecase reportsUsecases.IReportsUsecase
+}
+
+func ReportsHandler(reportsUsecase reportsUsecases.IReportsUsecase, cfg config.IConfig) IReportsHandler {
+	return &reportsHandler{
+		reportsUsecase: reportsUsecase,
+		cfg:            cfg,
+	}
+}
+
+// parseFilter reads the query string of every report, the error is returned as bad request
+func parseFilter(c *fiber.Ctx) (*reports.ReportFilter, error) {
+	req := new(reports.ReportFilter)
+	if err := c.QueryParser(req); err != nil {
+		return nil, err
+	}
+	req.Timezone = strings.Trim(req.Timezone, " ")
+	req.Interval = strings.ToLower(strings.Trim(req.Interval, " "))
+	req.SortBy = strings.ToLower(strings.Trim(req.SortBy, " "))
+
+	if err := req.Check(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (h *reportsHandler) FindSummary(c *fiber.Ctx) error {
+	req, err := parseFilter(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findSummaryErr),
+			err.Error(),
+		).Res()
+	}
+
+	summary, err := h.reportsUsecase.FindSummary(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findSummaryErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, summary).Res()
+}
+
+func (h *reportsHandler) FindRevenue(c *fiber.Ctx) error {
+	req, err := parseFilter(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findRevenueErr),
+			err.Error(),
+		).Res()
+	}
+
+	points, err := h.reportsUsecase.FindRevenue(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findRevenueErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, points).Res()
+}
+
+func (h *reportsHandler) FindStatusCount(c *fiber.Ctx) error {
+	req, err := parseFilter(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findStatusCountErr),
+			err.Error(),
+		).Res()
+	}
+
+	counts, err := h.reportsUsecase.FindStatusCount(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findStatusCountErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, counts).Res()
+}
+
+func (h *reportsHandler) FindTopProduct(c *fiber.Ctx) error {
+	req, err := parseFilter(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findTopProductErr),
+			err.Error(),
+		).Res()
+	}
+
+	products, err := h.reportsUsecase.FindTopProduct(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findTopProductErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, products).Res()
+}
+
+func (h *reportsHandler) FindTopCategory(c *fiber.Ctx) error {
+	req, err := parseFilter(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findTopCategoryErr),
+			err.Error(),
+		).Res()
+	}
+
+	categories, err := h.reportsUsecase.FindTopCategory(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findTopCategoryErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, categories).Res()
+}
@@ -0,0 +1,181 @@
+package reportsRepositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/reports"
+	"github.com/jmoiron/sqlx"
+)
+
+type IReportsRepository interface {
+	FindSummary(req *reports.ReportFilter) (*reports.Summary, error)
+	FindRevenue(req *reports.ReportFilter) ([]*reports.RevenuePoint, error)
+	FindStatusCount(req *reports.ReportFilter) ([]*reports.StatusCount, error)
+	FindTopProduct(req *reports.ReportFilter) ([]*reports.TopProduct, error)
+	FindTopCategory(req *reports.ReportFilter) ([]*reports.TopCategory, error)
+}
+
+type reportsRepository struct {
+	db *sqlx.DB
+}
+
+func ReportsRepository(db *sqlx.DB) IReportsRepository {
+	return &reportsRepository{
+		db: db,
+	}
+}
+
+// localCreatedAt is created_at of the order in the timezone of the filter ($3)
+var localCreatedAt = fmt.Sprintf(`(("o"."created_at" AT TIME ZONE '%s') AT TIME ZONE $3::TEXT)`, reports.StoreTimezone)
+
+// whereRange keeps orders created between start_date ($1) and end_date ($2) in the timezone of the filter ($3),
+// the dates are converted to store time so the index of created_at can be used
+var whereRange = fmt.Sprintf(`
+		"o"."created_at" >= (($1::DATE)::TIMESTAMP AT TIME ZONE $3::TEXT) AT TIME ZONE '%[1]s'
+		AND "o"."created_at" < (($2::DATE + 1)::TIMESTAMP AT TIME ZONE $3::TEXT) AT TIME ZONE '%[1]s'`,
+	reports.StoreTimezone,
+)
+
+var whereSold = fmt.Sprintf(`
+		AND "o"."status" IN ('%s')`, strings.Join(reports.RevenueStatuses, "', '"))
+
+func (r *reportsRepository) FindSummary(req *reports.ReportFilter) (*reports.Summary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	query := `
+	SELECT
+		COUNT(*) AS "orders",
+		COALESCE(SUM("o"."total_paid"), 0) AS "revenue",
+		COALESCE(SUM("o"."refunded"), 0) AS "refunded",
+		COALESCE(SUM("o"."total_paid" - "o"."refunded"), 0) AS "net_revenue",
+		COALESCE(AVG("o"."total_paid"), 0) AS "average_order_value"
+	FROM "orders" "o"
+	WHERE` + whereRange + whereSold + `;`
+
+	summary := &reports.Summary{
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Timezone:  req.Timezone,
+	}
+	if err := r.db.GetContext(ctx, summary, query, req.StartDate, req.EndDate, req.Timezone); err != nil {
+		return nil, fmt.Errorf("get summary failed: %v", err)
+	}
+	return summary, nil
+}
+
+func (r *reportsRepository) FindRevenue(req *reports.ReportFilter) ([]*reports.RevenuePoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	// generate_series returns every bucket in the range so the chart has no gap
+	query := `
+	SELECT
+		to_char("b"."period", 'YYYY-MM-DD') AS "period",
+		COUNT("o"."id") AS "orders",
+		COALESCE(SUM("o"."total_paid"), 0) AS "revenue",
+		COALESCE(SUM("o"."refunded"), 0) AS "refunded",
+		COALESCE(SUM("o"."total_paid" - "o"."refunded"), 0) AS "net_revenue"
+	FROM generate_series(
+		date_trunc($4::TEXT, ($1::DATE)::TIMESTAMP),
+		date_trunc($4::TEXT, ($2::DATE)::TIMESTAMP),
+		('1 ' || $4::TEXT)::INTERVAL
+	) AS "b"("period")
+		LEFT JOIN "orders" "o" ON date_trunc($4::TEXT, ` + localCreatedAt + `) = "b"."period"
+		AND` + whereRange + whereSold + `
+	GROUP BY "b"."period"
+	ORDER BY "b"."period" ASC;`
+
+	points := make([]*reports.RevenuePoint, 0)
+	if err := r.db.SelectContext(ctx, &points, query, req.StartDate, req.EndDate, req.Timezone, req.Interval); err != nil {
+		return nil, fmt.Errorf("select revenue failed: %v", err)
+	}
+	return points, nil
+}
+
+// FindStatusCount counts orders of every status, not only sold orders
+func (r *reportsRepository) FindStatusCount(req *reports.ReportFilter) ([]*reports.StatusCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	query := `
+	SELECT
+		"o"."status",
+		COUNT(*) AS "orders",
+		COALESCE(SUM("o"."total_paid"), 0) AS "total"
+	FROM "orders" "o"
+	WHERE` + whereRange + `
+	GROUP BY "o"."status"
+	ORDER BY "orders" DESC;`
+
+	counts := make([]*reports.StatusCount, 0)
+	if err := r.db.SelectContext(ctx, &counts, query, req.StartDate, req.EndDate, req.Timezone); err != nil {
+		return nil, fmt.Errorf("select status count failed: %v", err)
+	}
+	return counts, nil
+}
+
+// FindTopProduct sums the lines of sold orders, revenue of a line is its gross after discount
+func (r *reportsRepository) FindTopProduct(req *reports.ReportFilter) ([]*reports.TopProduct, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+	SELECT
+		"po"."product"->>'id' AS "product_id",
+		(array_agg("po"."product"->>'title' ORDER BY "o"."created_at" DESC))[1] AS "title",
+		SUM("po"."qty") AS "qty",
+		SUM("po"."gross") AS "revenue"
+	FROM "products_orders" "po"
+		JOIN "orders" "o" ON "o"."id" = "po"."order_id"
+	WHERE`+whereRange+whereSold+`
+	AND "po"."product"->>'id' IS NOT NULL
+	GROUP BY "po"."product"->>'id'
+	ORDER BY "%s" DESC
+	LIMIT $4;`, req.SortBy)
+
+	products := make([]*reports.TopProduct, 0)
+	if err := r.db.SelectContext(ctx, &products, query, req.StartDate, req.EndDate, req.Timezone, req.Limit); err != nil {
+		return nil, fmt.Errorf("select top products failed: %v", err)
+	}
+	return products, nil
+}
+
+// FindTopCategory groups the lines by every category in the product snapshot, snapshots from before a product
+// could have more than one category use their category. A line is counted in each of its categories,
+// so the categories may add up to more than the revenue of the orders.
+func (r *reportsRepository) FindTopCategory(req *reports.ReportFilter) ([]*reports.TopCategory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+	SELECT
+		("c"."category"->>'id')::INT AS "category_id",
+		(array_agg("c"."category"->>'title' ORDER BY "o"."created_at" DESC))[1] AS "title",
+		SUM("po"."qty") AS "qty",
+		SUM("po"."gross") AS "revenue"
+	FROM "products_orders" "po"
+		JOIN "orders" "o" ON "o"."id" = "po"."order_id"
+		CROSS JOIN LATERAL jsonb_array_elements(
+			CASE
+				WHEN jsonb_typeof("po"."product"->'categories') = 'array'
+				AND jsonb_array_length("po"."product"->'categories') > 0
+				THEN "po"."product"->'categories'
+				ELSE jsonb_build_array("po"."product"->'category')
+			END
+		) AS "c"("category")
+	WHERE`+whereRange+whereSold+`
+	AND "c"."category"->>'id' IS NOT NULL
+	GROUP BY ("c"."category"->>'id')::INT
+	ORDER BY "%s" DESC
+	LIMIT $4;`, req.SortBy)
+
+	categories := make([]*reports.TopCategory, 0)
+	if err := r.db.SelectContext(ctx, &categories, query, req.StartDate, req.EndDate, req.Timezone, req.Limit); err != nil {
+		return nil, fmt.Errorf("select top categories failed: %v", err)
+	}
+	return categories, nil
+}
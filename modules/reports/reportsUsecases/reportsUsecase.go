@@ -0,0 +1,44 @@
+package reportsUsecases
+
+import (
+	"github.com/NatthawutSK/ri-shop/modules/reports"
+	"github.com/NatthawutSK/ri-shop/modules/reports/reportsRepositories"
+)
+
+type IReportsUsecase interface {
+	FindSummary(req *reports.ReportFilter) (*reports.Summary, error)
+	FindRevenue(req *reports.ReportFilter) ([]*reports.RevenuePoint, error)
+	FindStatusCount(req *reports.ReportFilter) ([]*reports.StatusCount, error)
+	FindTopProduct(req *reports.ReportFilter) ([]*reports.TopProduct, error)
+	FindTopCategory(req *reports.ReportFilter) ([]*reports.TopCategory, error)
+}
+
+type reportsUsecase struct {
+	reportsRepository reportsRepositories.IReportsRepository
+}
+
+func ReportsUsecase(reportsRepository reportsRepositories.IReportsRepository) IReportsUsecase {
+	return &reportsUsecase{
+		reportsRepository: reportsRepository,
+	}
+}
+
+func (u *reportsUsecase) FindSummary(req *reports.ReportFilter) (*reports.Summary, error) {
+	return u.reportsRepository.FindSummary(req)
+}
+
+func (u *reportsUsecase) FindRevenue(req *reports.ReportFilter) ([]*reports.RevenuePoint, error) {
+	return u.reportsRepository.FindRevenue(req)
+}
+
+func (u *reportsUsecase) FindStatusCount(req *reports.ReportFilter) ([]*reports.StatusCount, error) {
+	return u.reportsRepository.FindStatusCount(req)
+}
+
+func (u *reportsUsecase) FindTopProduct(req *reports.ReportFilter) ([]*reports.TopProduct, error) {
+	return u.reportsRepository.FindTopProduct(req)
+}
+
+func (u *reportsUsecase) FindTopCategory(req *reports.ReportFilter) ([]*reports.TopCategory, error) {
+	return u.reportsRepository.FindTopCategory(req)
+}
@@ -0,0 +1,72 @@
+package returns
+
+import (
+	"github.com/NatthawutSK/ri-shop/modules/files"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+type Return struct {
+	Id           string           `json:"id"`
+	OrderId      string           `json:"order_id"`
+	UserId       string           `json:"user_id"`
+	Status       string           `json:"status"` // requested, approved, rejected, received, refunded
+	Reason       string           `json:"reason"`
+	Photos       []*files.FileRes `json:"photos"`
+	Items        []*ReturnItem    `json:"items"`
+	AdminNote    *string          `json:"admin_note"`
+	RefundAmount riMoney.Money    `json:"refund_amount"`
+	ReviewedBy   *string          `json:"reviewed_by"`
+	ReviewedAt   *string          `json:"reviewed_at"`
+	ReceivedAt   *string          `json:"received_at"`
+	RefundedAt   *string          `json:"refunded_at"`
+	CreatedAt    string           `json:"created_at"`
+	UpdatedAt    string           `json:"updated_at"`
+}
+
+type ReturnItem struct {
+	Id              string        `json:"id"`
+	ProductsOrderId string        `json:"products_order_id"`
+	ProductId       string        `json:"product_id"`
+	Title           string        `json:"title"`
+	UnitPrice       riMoney.Money `json:"unit_price"` // price in the order snapshot
+	Qty             int           `json:"qty"`
+	ReceivedQty     int           `json:"received_qty"`
+}
+
+type ReturnItemReq struct {
+	ProductsOrderId string `json:"products_order_id"`
+	Qty             int    `json:"qty"`
+}
+
+type ReturnReq struct {
+	OrderId string           `json:"-"`
+	UserId  string           `json:"-"`
+	Reason  string           `json:"reason" form:"reason"`
+	Items   []*ReturnItemReq `json:"items"`
+	Photos  []*files.FileRes `json:"-"`
+}
+
+type ReturnReviewReq struct {
+	ReturnId   string `json:"-"`
+	IsApproved bool   `json:"-"`
+	Note       string `json:"note" form:"note"`
+	ReviewedBy string `json:"-"`
+}
+
+// ReturnReceiveReq records qty that arrived back at the store, qty of each item is the received qty
+type ReturnReceiveReq struct {
+	ReturnId   string           `json:"-"`
+	Items      []*ReturnItemReq `json:"items"`
+	Restock    bool             `json:"restock"`
+	ReceivedBy string           `json:"-"`
+}
+
+type ReturnRefundReq struct {
+	ReturnId   string         `json:"-"`
+	Amount     *riMoney.Money `json:"amount"` // empty is what was paid for the received items
+	RefundedBy string         `json:"-"`
+}
+
+type ReturnFilter struct {
+	Status string `query:"status"`
+}
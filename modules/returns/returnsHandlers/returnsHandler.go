@@ -0,0 +1,392 @@
+package returnsHandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/files"
+	"github.com/NatthawutSK/ri-shop/modules/files/filesUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/returns"
+	"github.com/NatthawutSK/ri-shop/modules/returns/returnsUsecases"
+	"github.com/NatthawutSK/ri-shop/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type returnsHandlerErrCode string
+
+const (
+	findReturnErr      returnsHandlerErrCode = "returns-001"
+	findOneReturnErr   returnsHandlerErrCode = "returns-002"
+	insertReturnErr    returnsHandlerErrCode = "returns-003"
+	reviewReturnErr    returnsHandlerErrCode = "returns-004"
+	receiveReturnErr   returnsHandlerErrCode = "returns-005"
+	refundReturnErr    returnsHandlerErrCode = "returns-006"
+	findOrderReturnErr returnsHandlerErrCode = "returns-007"
+)
+
+type IReturnsHandler interface {
+	FindReturn(c *fiber.Ctx) error
+	FindOneReturn(c *fiber.Ctx) error
+	FindOrderReturn(c *fiber.Ctx) error
+	InsertReturn(c *fiber.Ctx) error
+	ApproveReturn(c *fiber.Ctx) error
+	RejectReturn(c *fiber.Ctx) error
+	ReceiveReturn(c *fiber.Ctx) error
+	RefundReturn(c *fiber.Ctx) error
+}
+
+type returnsHandler struct {
+	cfg            config.IConfig
+	returnsUsecase returnsUsecases.IReturnsUsecase
+	fileUsecase    filesUsecases.IFilesUsecase
+}
+
+func ReturnsHandler(returnsUsecase returnsUsecases.IReturnsUsecase, cfg config.IConfig, fileUsecase filesUsecases.IFilesUsecase) IReturnsHandler {
+	return &returnsHandler{
+		returnsUsecase: returnsUsecase,
+		cfg:            cfg,
+		fileUsecase:    fileUsecase,
+	}
+}
+
+func (h *returnsHandler) FindReturn(c *fiber.Ctx) error {
+	req := new(returns.ReturnFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findReturnErr),
+			err.Error(),
+		).Res()
+	}
+	req.Status = strings.ToLower(strings.Trim(req.Status, " "))
+
+	returnsData, err := h.returnsUsecase.FindReturn(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnsData).Res()
+}
+
+func (h *returnsHandler) FindOneReturn(c *fiber.Ctx) error {
+	returnId := strings.Trim(c.Params("returnId"), " ")
+
+	returnData, err := h.returnsUsecase.FindOneReturn(returnId)
+	if err != nil {
+		if err.Error() == "return not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findOneReturnErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findOneReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnData).Res()
+}
+
+// FindOrderReturn lists returns of an order for its owner
+func (h *returnsHandler) FindOrderReturn(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	returnsData, err := h.returnsUsecase.FindOrderReturn(userId, orderId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findOrderReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnsData).Res()
+}
+
+// InsertReturn accepts multipart form with "reason", "items" as json string and "photos" files,
+// a json body without photos is accepted too
+func (h *returnsHandler) InsertReturn(c *fiber.Ctx) error {
+	req := &returns.ReturnReq{
+		OrderId: strings.Trim(c.Params("order_id"), " "),
+		UserId:  strings.Trim(c.Params("user_id"), " "),
+		Items:   make([]*returns.ReturnItemReq, 0),
+		Photos:  make([]*files.FileRes, 0),
+	}
+
+	form, err := c.MultipartForm()
+	if err == nil {
+		if reason := form.Value["reason"]; len(reason) > 0 {
+			req.Reason = reason[0]
+		}
+		if items := form.Value["items"]; len(items) > 0 {
+			if err := json.Unmarshal([]byte(items[0]), &req.Items); err != nil {
+				return entities.NewResponse(c).Error(
+					fiber.ErrBadRequest.Code,
+					string(insertReturnErr),
+					"items must be a json array",
+				).Res()
+			}
+		}
+	} else if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.Reason = strings.Trim(req.Reason, " ")
+	if req.Reason == "" || len(req.Items) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertReturnErr),
+			"reason and items are required",
+		).Res()
+	}
+	for _, item := range req.Items {
+		if item == nil || item.ProductsOrderId == "" || item.Qty < 1 {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertReturnErr),
+				"products_order_id is required and qty must be more than 0",
+			).Res()
+		}
+	}
+
+	// photos
+	filesReq := make([]*files.FileReq, 0)
+	if form != nil {
+		for _, file := range form.File["photos"] {
+			ext, err := files.CheckImage(file, h.cfg.App().FileLimit())
+			if err != nil {
+				return entities.NewResponse(c).Error(
+					fiber.ErrBadRequest.Code,
+					string(insertReturnErr),
+					err.Error(),
+				).Res()
+			}
+
+			filename := utils.RandFileName(ext)
+			filesReq = append(filesReq, &files.FileReq{
+				File:        file,
+				Destination: fmt.Sprintf("returns/%s/%s", req.OrderId, filename),
+				FileName:    filename,
+				Extension:   ext,
+			})
+		}
+	}
+	if len(filesReq) > 0 {
+		res, err := h.fileUsecase.UploadToGCP(filesReq)
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(insertReturnErr),
+				err.Error(),
+			).Res()
+		}
+		req.Photos = res
+	}
+
+	returnData, err := h.returnsUsecase.InsertReturn(req)
+	if err != nil {
+		// the photos are not attached to any return, remove them
+		if len(filesReq) > 0 {
+			deleteReq := make([]*files.DeleteFileReq, 0)
+			for _, f := range filesReq {
+				deleteReq = append(deleteReq, &files.DeleteFileReq{
+					Destination: f.Destination,
+				})
+			}
+			h.fileUsecase.DeleteFileOnGCP(deleteReq)
+		}
+
+		if err.Error() == "order not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(insertReturnErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "cannot return ") ||
+			strings.HasPrefix(err.Error(), "item ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertReturnErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, returnData).Res()
+}
+
+func (h *returnsHandler) ApproveReturn(c *fiber.Ctx) error {
+	req := new(returns.ReturnReviewReq)
+	if err := c.BodyParser(req); err != nil && len(c.Body()) > 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewReturnErr),
+			err.Error(),
+		).Res()
+	}
+	req.IsApproved = true
+	return h.reviewReturn(c, req)
+}
+
+func (h *returnsHandler) RejectReturn(c *fiber.Ctx) error {
+	req := new(returns.ReturnReviewReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.Note = strings.Trim(req.Note, " ")
+	if req.Note == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewReturnErr),
+			"note is required",
+		).Res()
+	}
+	req.IsApproved = false
+	return h.reviewReturn(c, req)
+}
+
+func (h *returnsHandler) reviewReturn(c *fiber.Ctx, req *returns.ReturnReviewReq) error {
+	req.ReturnId = strings.Trim(c.Params("returnId"), " ")
+	req.ReviewedBy = c.Locals("userId").(string)
+
+	returnData, err := h.returnsUsecase.ReviewReturn(req)
+	if err != nil {
+		if err.Error() == "return not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(reviewReturnErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "cannot review ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(reviewReturnErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(reviewReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnData).Res()
+}
+
+func (h *returnsHandler) ReceiveReturn(c *fiber.Ctx) error {
+	req := &returns.ReturnReceiveReq{
+		Items: make([]*returns.ReturnItemReq, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(receiveReturnErr),
+			err.Error(),
+		).Res()
+	}
+	req.ReturnId = strings.Trim(c.Params("returnId"), " ")
+	req.ReceivedBy = c.Locals("userId").(string)
+
+	for _, item := range req.Items {
+		if item == nil || item.ProductsOrderId == "" || item.Qty < 0 {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(receiveReturnErr),
+				"products_order_id is required and qty must not be negative",
+			).Res()
+		}
+	}
+
+	returnData, err := h.returnsUsecase.ReceiveReturn(req)
+	if err != nil {
+		if err.Error() == "return not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(receiveReturnErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "cannot receive ") ||
+			strings.HasPrefix(err.Error(), "item ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(receiveReturnErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(receiveReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnData).Res()
+}
+
+func (h *returnsHandler) RefundReturn(c *fiber.Ctx) error {
+	req := new(returns.ReturnRefundReq)
+	if err := c.BodyParser(req); err != nil && len(c.Body()) > 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(refundReturnErr),
+			err.Error(),
+		).Res()
+	}
+	req.ReturnId = strings.Trim(c.Params("returnId"), " ")
+	req.RefundedBy = c.Locals("userId").(string)
+
+	returnData, err := h.returnsUsecase.RefundReturn(req)
+	if err != nil {
+		if err.Error() == "return not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(refundReturnErr),
+				err.Error(),
+			).Res()
+		}
+		if strings.HasPrefix(err.Error(), "cannot refund ") ||
+			strings.HasPrefix(err.Error(), "refund amount ") {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(refundReturnErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(refundReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnData).Res()
+}
@@ -0,0 +1,515 @@
+package returnsRepositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/returns"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+	"github.com/jmoiron/sqlx"
+)
+
+type IReturnsRepository interface {
+	FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error)
+	FindOrderReturn(userId, orderId string) ([]*returns.Return, error)
+	FindOneReturn(returnId string) (*returns.Return, error)
+	InsertReturn(req *returns.ReturnReq) (string, error)
+	ReviewReturn(req *returns.ReturnReviewReq) error
+	ReceiveReturn(req *returns.ReturnReceiveReq) error
+	RefundReturn(req *returns.ReturnRefundReq) error
+}
+
+type returnsRepository struct {
+	db *sqlx.DB
+}
+
+func ReturnsRepository(db *sqlx.DB) IReturnsRepository {
+	return &returnsRepository{
+		db: db,
+	}
+}
+
+const selectReturnQuery = `
+	SELECT
+		"r"."id",
+		"r"."order_id",
+		"r"."user_id",
+		"r"."status",
+		"r"."reason",
+		"r"."photos",
+		(
+			SELECT
+				COALESCE(array_to_json(array_agg("it")), '[]'::json)
+			FROM (
+				SELECT
+					"ri"."id",
+					"ri"."products_order_id",
+					"po"."product"->>'id' AS "product_id",
+					"po"."product"->>'title' AS "title",
+					COALESCE(("po"."product"->>'price')::NUMERIC, 0) AS "unit_price",
+					"ri"."qty",
+					"ri"."received_qty"
+				FROM "returns_items" "ri"
+					JOIN "products_orders" "po" ON "po"."id" = "ri"."products_order_id"
+				WHERE "ri"."return_id" = "r"."id"
+			) AS "it"
+		) AS "items",
+		"r"."admin_note",
+		"r"."refund_amount",
+		"r"."reviewed_by",
+		"r"."reviewed_at",
+		"r"."received_at",
+		"r"."refunded_at",
+		"r"."created_at",
+		"r"."updated_at"
+	FROM "returns" "r"`
+
+func (r *returnsRepository) findReturns(query string, args ...any) ([]*returns.Return, error) {
+	bytes := make([]byte, 0)
+	returnsData := make([]*returns.Return, 0)
+
+	if err := r.db.Get(&bytes, query, args...); err != nil {
+		return nil, fmt.Errorf("get returns failed: %v", err)
+	}
+	if err := json.Unmarshal(bytes, &returnsData); err != nil {
+		return nil, fmt.Errorf("unmarshal returns failed: %v", err)
+	}
+	return returnsData, nil
+}
+
+func (r *returnsRepository) FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (` + selectReturnQuery + `
+	WHERE ($1 = '' OR "r"."status"::TEXT = $1)
+	ORDER BY "r"."created_at" DESC
+	) AS "t";`
+
+	return r.findReturns(query, req.Status)
+}
+
+func (r *returnsRepository) FindOrderReturn(userId, orderId string) ([]*returns.Return, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (` + selectReturnQuery + `
+	WHERE "r"."order_id" = $1
+	AND "r"."user_id" = $2
+	ORDER BY "r"."created_at" DESC
+	) AS "t";`
+
+	return r.findReturns(query, orderId, userId)
+}
+
+func (r *returnsRepository) FindOneReturn(returnId string) (*returns.Return, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (` + selectReturnQuery + `
+	WHERE "r"."id" = $1
+	) AS "t";`
+
+	bytes := make([]byte, 0)
+	returnData := new(returns.Return)
+
+	if err := r.db.Get(&bytes, query, returnId); err != nil {
+		return nil, fmt.Errorf("return not found")
+	}
+	if err := json.Unmarshal(bytes, &returnData); err != nil {
+		return nil, fmt.Errorf("unmarshal return failed: %v", err)
+	}
+	return returnData, nil
+}
+
+func insertStatusHistory(ctx context.Context, tx *sqlx.Tx, orderId, fromStatus, toStatus, changedBy string) error {
+	if _, err := tx.ExecContext(ctx, `
+	INSERT INTO "order_status_history" (
+		"order_id",
+		"from_status",
+		"to_status",
+		"changed_by"
+	)
+	VALUES ($1, $2, $3, $4);`, orderId, fromStatus, toStatus, changedBy); err != nil {
+		return fmt.Errorf("insert order status history failed: %v", err)
+	}
+	return nil
+}
+
+// InsertReturn requests a return of some lines of a completed order, the order becomes return_requested
+func (r *returnsRepository) InsertReturn(req *returns.ReturnReq) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	var status string
+	if err := tx.QueryRowxContext(ctx, `
+	SELECT
+		"status"
+	FROM "orders"
+	WHERE "id" = $1
+	AND "user_id" = $2
+	FOR UPDATE;`, req.OrderId, req.UserId).Scan(&status); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("order not found")
+	}
+
+	// a partly refunded order can be returned again
+	if status != "completed" && status != "refunded" {
+		tx.Rollback()
+		return "", fmt.Errorf("cannot return %s order", status)
+	}
+
+	// qty that is not in any return yet, a rejected return only keeps what arrived back
+	remaining := make([]struct {
+		Id  string `db:"id"`
+		Qty int    `db:"qty"`
+	}, 0)
+	if err := tx.SelectContext(ctx, &remaining, `
+	SELECT
+		"po"."id",
+		("po"."qty" - COALESCE(SUM(CASE WHEN "r"."status" = 'rejected' THEN "ri"."received_qty" ELSE "ri"."qty" END), 0))::INT AS "qty"
+	FROM "products_orders" "po"
+		LEFT JOIN "returns_items" "ri" ON "ri"."products_order_id" = "po"."id"
+		LEFT JOIN "returns" "r" ON "r"."id" = "ri"."return_id"
+	WHERE "po"."order_id" = $1
+	GROUP BY "po"."id", "po"."qty";`, req.OrderId); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("get remaining items failed: %v", err)
+	}
+
+	remainingMap := make(map[string]int)
+	for _, line := range remaining {
+		remainingMap[line.Id] = line.Qty
+	}
+	for _, item := range req.Items {
+		qty, ok := remainingMap[item.ProductsOrderId]
+		if !ok {
+			tx.Rollback()
+			return "", fmt.Errorf("item %s is not in the order", item.ProductsOrderId)
+		}
+		if item.Qty > qty {
+			tx.Rollback()
+			return "", fmt.Errorf("item %s has only %d left to return", item.ProductsOrderId, qty)
+		}
+		remainingMap[item.ProductsOrderId] -= item.Qty
+	}
+
+	photos, err := json.Marshal(req.Photos)
+	if err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("marshal photos failed: %v", err)
+	}
+
+	var returnId string
+	if err := tx.QueryRowxContext(ctx, `
+	INSERT INTO "returns" (
+		"order_id",
+		"user_id",
+		"reason",
+		"photos"
+	)
+	VALUES ($1, $2, $3, $4::jsonb)
+	RETURNING "id";`, req.OrderId, req.UserId, req.Reason, string(photos)).Scan(&returnId); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("insert return failed: %v", err)
+	}
+
+	for _, item := range req.Items {
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO "returns_items" (
+			"return_id",
+			"products_order_id",
+			"qty"
+		)
+		VALUES ($1, $2, $3);`, returnId, item.ProductsOrderId, item.Qty); err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("insert return item failed: %v", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+	UPDATE "orders" SET
+		"status" = 'return_requested'
+	WHERE "id" = $1;`, req.OrderId); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("update order status failed: %v", err)
+	}
+	if err := insertStatusHistory(ctx, tx, req.OrderId, status, "return_requested", req.UserId); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return returnId, nil
+}
+
+// lockReturn locks the return and its order, it returns status of both
+func lockReturn(ctx context.Context, tx *sqlx.Tx, returnId string) (orderId, returnStatus, orderStatus string, err error) {
+	if err := tx.QueryRowxContext(ctx, `
+	SELECT
+		"r"."order_id",
+		"r"."status",
+		"o"."status"
+	FROM "returns" "r"
+		JOIN "orders" "o" ON "o"."id" = "r"."order_id"
+	WHERE "r"."id" = $1
+	FOR UPDATE;`, returnId).Scan(&orderId, &returnStatus, &orderStatus); err != nil {
+		return "", "", "", fmt.Errorf("return not found")
+	}
+	return orderId, returnStatus, orderStatus, nil
+}
+
+// ReviewReturn approves or rejects a requested return, a rejected return puts the order back to its status before the request.
+// An approved or received return can also be rejected, it closes a return that is not refunded (nothing arrived, nothing to refund).
+func (r *returnsRepository) ReviewReturn(req *returns.ReturnReviewReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	orderId, returnStatus, orderStatus, err := lockReturn(ctx, tx, req.ReturnId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	canReview := returnStatus == "requested"
+	if !req.IsApproved {
+		canReview = canReview || returnStatus == "approved" || returnStatus == "received"
+	}
+	if !canReview {
+		tx.Rollback()
+		return fmt.Errorf("cannot review %s return", returnStatus)
+	}
+
+	status := "approved"
+	if !req.IsApproved {
+		status = "rejected"
+	}
+	if _, err := tx.ExecContext(ctx, `
+	UPDATE "returns" SET
+		"status" = $1,
+		"admin_note" = NULLIF($2, ''),
+		"reviewed_by" = $3,
+		"reviewed_at" = now()
+	WHERE "id" = $4;`, status, req.Note, req.ReviewedBy, req.ReturnId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update return failed: %v", err)
+	}
+
+	if !req.IsApproved && orderStatus == "return_requested" {
+		var toStatus string
+		if err := tx.QueryRowxContext(ctx, `
+		UPDATE "orders" SET
+			"status" = (CASE WHEN "refunded" > 0 THEN 'refunded' ELSE 'completed' END)::order_status
+		WHERE "id" = $1
+		RETURNING "status";`, orderId).Scan(&toStatus); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("update order status failed: %v", err)
+		}
+		if err := insertStatusHistory(ctx, tx, orderId, orderStatus, toStatus, req.ReviewedBy); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return nil
+}
+
+// ReceiveReturn records items that arrived back, received items can be put back to stock
+func (r *returnsRepository) ReceiveReturn(req *returns.ReturnReceiveReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	orderId, returnStatus, _, err := lockReturn(ctx, tx, req.ReturnId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if returnStatus != "approved" {
+		tx.Rollback()
+		return fmt.Errorf("cannot receive %s return", returnStatus)
+	}
+
+	for _, item := range req.Items {
+		var productId string
+		var variantId *string
+		if err := tx.QueryRowxContext(ctx, `
+		UPDATE "returns_items" "ri" SET
+			"received_qty" = $1
+		FROM "products_orders" "po"
+		WHERE "po"."id" = "ri"."products_order_id"
+		AND "ri"."return_id" = $2
+		AND "ri"."products_order_id" = $3
+		AND "ri"."qty" >= $1
+		RETURNING COALESCE("po"."product"->>'id', ''), "po"."variant_id";`, item.Qty, req.ReturnId, item.ProductsOrderId).Scan(&productId, &variantId); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("item %s is not in the return or received qty is more than returned qty", item.ProductsOrderId)
+		}
+
+		if !req.Restock || item.Qty == 0 || productId == "" {
+			continue
+		}
+
+		// a variant gets back its own stock, the line of a deleted variant has no variant_id and
+		// is restocked to the product. The product may be deleted after the order, then nothing is restocked
+		restockQuery := `
+		WITH "restocked" AS (
+			UPDATE "products" SET
+				"stock" = "stock" + $1
+			WHERE "id" = $2
+			RETURNING "id" AS "product_id", NULL::uuid AS "variant_id", "stock"
+		)`
+		restockArgs := []any{item.Qty, productId}
+		if variantId != nil {
+			restockQuery = `
+		WITH "restocked" AS (
+			UPDATE "product_variants" SET
+				"stock" = "stock" + $1
+			WHERE "id" = $2
+			RETURNING "product_id", "id" AS "variant_id", "stock"
+		)`
+			restockArgs = []any{item.Qty, *variantId}
+		}
+
+		if _, err := tx.ExecContext(ctx, restockQuery+`
+		INSERT INTO "stock_movements" (
+			"product_id",
+			"variant_id",
+			"order_id",
+			"type",
+			"qty",
+			"balance",
+			"note",
+			"created_by"
+		)
+		SELECT
+			"product_id",
+			"variant_id",
+			$3,
+			'return',
+			$1,
+			"stock",
+			$4,
+			$5
+		FROM "restocked";`, append(restockArgs, orderId, fmt.Sprintf("return %s", req.ReturnId), req.ReceivedBy)...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("restock failed: %v", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+	UPDATE "returns" SET
+		"status" = 'received',
+		"received_at" = now()
+	WHERE "id" = $1;`, req.ReturnId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update return failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return nil
+}
+
+// RefundReturn issues the refund of a received return, the amount is added to "orders"."refunded"
+func (r *returnsRepository) RefundReturn(req *returns.ReturnRefundReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	orderId, returnStatus, orderStatus, err := lockReturn(ctx, tx, req.ReturnId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if returnStatus != "received" {
+		tx.Rollback()
+		return fmt.Errorf("cannot refund %s return", returnStatus)
+	}
+
+	// received items are refunded at what was paid for them, gross of the line is after the coupon discount
+	var receivedValue, refundable riMoney.Money
+	if err := tx.QueryRowxContext(ctx, `
+	SELECT
+		COALESCE((
+			SELECT
+				SUM(ROUND("po"."gross" * "ri"."received_qty" / "po"."qty", 2))
+			FROM "returns_items" "ri"
+				JOIN "products_orders" "po" ON "po"."id" = "ri"."products_order_id"
+			WHERE "ri"."return_id" = $1
+		), 0),
+		"o"."total_paid" - "o"."refunded"
+	FROM "orders" "o"
+	WHERE "o"."id" = $2;`, req.ReturnId, orderId).Scan(&receivedValue, &refundable); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("get refund amount failed: %v", err)
+	}
+
+	amount := riMoney.Min(receivedValue, refundable)
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	if amount <= 0 || amount > refundable {
+		tx.Rollback()
+		return fmt.Errorf("refund amount must be more than 0 and not more than %s", refundable)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+	UPDATE "returns" SET
+		"status" = 'refunded',
+		"refund_amount" = $1,
+		"refunded_at" = now()
+	WHERE "id" = $2;`, amount, req.ReturnId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update return failed: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+	UPDATE "orders" SET
+		"status" = 'refunded',
+		"refunded" = "refunded" + $1
+	WHERE "id" = $2;`, amount, orderId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update order refunded failed: %v", err)
+	}
+	if orderStatus != "refunded" {
+		if err := insertStatusHistory(ctx, tx, orderId, orderStatus, "refunded", req.RefundedBy); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package returnsUsecases
+
+import (
+	"github.com/NatthawutSK/ri-shop/modules/returns"
+	"github.com/NatthawutSK/ri-shop/modules/returns/returnsRepositories"
+)
+
+type IReturnsUsecase interface {
+	FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error)
+	FindOrderReturn(userId, orderId string) ([]*returns.Return, error)
+	FindOneReturn(returnId string) (*returns.Return, error)
+	InsertReturn(req *returns.ReturnReq) (*returns.Return, error)
+	ReviewReturn(req *returns.ReturnReviewReq) (*returns.Return, error)
+	ReceiveReturn(req *returns.ReturnReceiveReq) (*returns.Return, error)
+	RefundReturn(req *returns.ReturnRefundReq) (*returns.Return, error)
+}
+
+type returnsUsecase struct {
+	returnsRepository returnsRepositories.IReturnsRepository
+}
+
+func ReturnsUsecase(returnsRepository returnsRepositories.IReturnsRepository) IReturnsUsecase {
+	return &returnsUsecase{
+		returnsRepository: returnsRepository,
+	}
+}
+
+func (u *returnsUsecase) FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error) {
+	return u.returnsRepository.FindReturn(req)
+}
+
+func (u *returnsUsecase) FindOrderReturn(userId, orderId string) ([]*returns.Return, error) {
+	return u.returnsRepository.FindOrderReturn(userId, orderId)
+}
+
+func (u *returnsUsecase) FindOneReturn(returnId string) (*returns.Return, error) {
+	return u.returnsRepository.FindOneReturn(returnId)
+}
+
+func (u *returnsUsecase) InsertReturn(req *returns.ReturnReq) (*returns.Return, error) {
+	returnId, err := u.returnsRepository.InsertReturn(req)
+	if err != nil {
+		return nil, err
+	}
+	return u.returnsRepository.FindOneReturn(returnId)
+}
+
+func (u *returnsUsecase) ReviewReturn(req *returns.ReturnReviewReq) (*returns.Return, error) {
+	if err := u.returnsRepository.ReviewReturn(req); err != nil {
+		return nil, err
+	}
+	return u.returnsRepository.FindOneReturn(req.ReturnId)
+}
+
+func (u *returnsUsecase) ReceiveReturn(req *returns.ReturnReceiveReq) (*returns.Return, error) {
+	if err := u.returnsRepository.ReceiveReturn(req); err != nil {
+		return nil, err
+	}
+	return u.returnsRepository.FindOneReturn(req.ReturnId)
+}
+
+func (u *returnsUsecase) RefundReturn(req *returns.ReturnRefundReq) (*returns.Return, error) {
+	if err := u.returnsRepository.RefundReturn(req); err != nil {
+		return nil, err
+	}
+	return u.returnsRepository.FindOneReturn(req.ReturnId)
+}
@@ -1,21 +1,42 @@
 package servers
 
 import (
+	"context"
+	"log"
+	"time"
+
 	"github.com/NatthawutSK/ri-shop/modules/appinfo/appinfoHandlers"
 	"github.com/NatthawutSK/ri-shop/modules/appinfo/appinfoRepositories"
 	"github.com/NatthawutSK/ri-shop/modules/appinfo/appinfoUsecases"
-	"github.com/NatthawutSK/ri-shop/modules/files/filesUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/carts/cartsHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/carts/cartsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/carts/cartsUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/coupons/couponsHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/coupons/couponsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/coupons/couponsUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/messages/messagesHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/messages/messagesRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/messages/messagesUsecases"
 	"github.com/NatthawutSK/ri-shop/modules/middlewares/middlewaresHandlers"
 	"github.com/NatthawutSK/ri-shop/modules/middlewares/middlewaresRepositories"
 	"github.com/NatthawutSK/ri-shop/modules/middlewares/middlewaresUsecases"
 	"github.com/NatthawutSK/ri-shop/modules/monitor/monitorHandlers"
-	"github.com/NatthawutSK/ri-shop/modules/orders/ordersHandlers"
-	"github.com/NatthawutSK/ri-shop/modules/orders/ordersRepositories"
-	"github.com/NatthawutSK/ri-shop/modules/orders/ordersUsecases"
-	"github.com/NatthawutSK/ri-shop/modules/products/productsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/reports/reportsHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/reports/reportsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/reports/reportsUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/returns/returnsHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/returns/returnsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/returns/returnsUsecases"
+	"github.com/NatthawutSK/ri-shop/modules/shipments/shipmentsHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/shipments/shipmentsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/shipments/shipmentsUsecases"
 	"github.com/NatthawutSK/ri-shop/modules/users/usersHandlers"
 	"github.com/NatthawutSK/ri-shop/modules/users/usersRepositories"
 	"github.com/NatthawutSK/ri-shop/modules/users/usersUsecases"
+	riScheduler "github.com/NatthawutSK/ri-shop/pkg/rischeduler"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -25,7 +46,15 @@ type IModuleFactory interface {
 	AppinfoModule()
 	FilesModule() IFilesModule
 	ProductsModule() IProductModule
-	OrdersModule()
+	OrdersModule() IOrdersModule
+	CouponsModule()
+	CurrenciesModule()
+	CartsModule()
+	ShipmentsModule()
+	ReturnsModule()
+	ReportsModule()
+	MessagesModule()
+	JobsModule(scheduler riScheduler.IScheduler)
 }
 
 type moduleFactory struct {
@@ -61,13 +90,19 @@ func (m *moduleFactory) UsersModule() {
 
 	router := m.r.Group("/users")
 
-	router.Post("/signup", m.mid.ApiKeyAuth(), handler.SignUpCustomer)
+	router.Post("/signup", m.mid.ApiKeyAuth(), m.mid.Idempotency(), handler.SignUpCustomer)
 	router.Post("/signin", handler.SignIn)
 	router.Post("/refresh", m.mid.ApiKeyAuth(), handler.RefreshPassport)
 	router.Post("/signout", m.mid.ApiKeyAuth(), handler.SignOut)
 	router.Post("/signup-admin", m.mid.JwtAuth(), m.mid.Authorize(2), handler.SignUpAdmin)
 	router.Get("/admin/secret", m.mid.JwtAuth(), m.mid.Authorize(2), handler.GenerateAdminToken)
 	router.Get("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.GetUserProfile)
+
+	router.Get("/:user_id/addresses", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindAddress)
+	router.Post("/:user_id/addresses", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.InsertAddress)
+	router.Get("/:user_id/addresses/:address_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindOneAddress)
+	router.Patch("/:user_id/addresses/:address_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.UpdateAddress)
+	router.Delete("/:user_id/addresses/:address_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.DeleteAddress)
 }
 
 func (m *moduleFactory) AppinfoModule() {
@@ -110,21 +145,132 @@ func (m *moduleFactory) AppinfoModule() {
 
 // }
 
-func (m *moduleFactory) OrdersModule() {
-	fileUsecase := filesUsecases.FilesUsecase(m.s.cfg)
-	productRepository := productsRepositories.ProductsRepository(m.s.db, m.s.cfg, fileUsecase)
+func (m *moduleFactory) CouponsModule() {
+	repository := couponsRepositories.CouponsRepository(m.s.db)
+	usecase := couponsUsecases.CouponsUsecase(repository)
+	handler := couponsHandlers.CouponsHandler(usecase, m.s.cfg)
+
+	router := m.r.Group("/coupons")
+
+	router.Get("/", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindCoupon)
+	router.Post("/", m.mid.JwtAuth(), m.mid.Authorize(2), handler.InsertCoupon)
+	router.Get("/:couponId", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindOneCoupon)
+	router.Patch("/:couponId", m.mid.JwtAuth(), m.mid.Authorize(2), handler.UpdateCoupon)
+	router.Delete("/:couponId", m.mid.JwtAuth(), m.mid.Authorize(2), handler.DeleteCoupon)
+}
+
+func (m *moduleFactory) CurrenciesModule() {
+	repository := currenciesRepositories.CurrenciesRepository(m.s.db)
+	usecase := currenciesUsecases.CurrenciesUsecase(repository)
+	handler := currenciesHandlers.CurrenciesHandler(usecase, m.s.cfg)
+
+	router := m.r.Group("/currencies")
+
+	router.Get("/", m.mid.ApiKeyAuth(), handler.FindCurrency)
+	router.Get("/:code", m.mid.ApiKeyAuth(), handler.FindOneCurrency)
+	router.Post("/", m.mid.JwtAuth(), m.mid.Authorize(2), handler.InsertCurrency)
+	router.Patch("/:code", m.mid.JwtAuth(), m.mid.Authorize(2), handler.UpdateCurrency)
+}
+
+func (m *moduleFactory) CartsModule() {
+	repository := cartsRepositories.CartsRepository(m.s.db)
+	usecase := cartsUsecases.CartsUsecase(repository, m.ProductsModule().Repository(), m.OrdersModule().Usecase())
+	handler := cartsHandlers.CartsHandler(usecase, m.s.cfg)
+
+	router := m.r.Group("/cart")
+
+	router.Get("/", m.mid.JwtAuth(), handler.FindCart)
+	router.Post("/items", m.mid.JwtAuth(), handler.AddItem)
+	router.Patch("/items/:productId", m.mid.JwtAuth(), handler.UpdateItem)
+	router.Delete("/items/:productId", m.mid.JwtAuth(), handler.DeleteItem)
+	router.Post("/merge", m.mid.JwtAuth(), handler.MergeCart)
+	router.Post("/checkout", m.mid.JwtAuth(), m.mid.Idempotency(), handler.Checkout)
+}
 
-	ordersRepository := ordersRepositories.OrdersRepository(m.s.db)
-	ordersUsecase := ordersUsecases.OrdersUsecase(ordersRepository, productRepository)
-	ordersHandler := ordersHandlers.OrdersHandler(ordersUsecase, m.s.cfg)
+func (m *moduleFactory) ShipmentsModule() {
+	repository := shipmentsRepositories.ShipmentsRepository(m.s.db)
+	usecase := shipmentsUsecases.ShipmentsUsecase(repository)
+	handler := shipmentsHandlers.ShipmentsHandler(usecase, m.s.cfg)
 
+	// shipments are read with the order, see FindOneOrder
 	router := m.r.Group("/orders")
 
-	router.Post("/", m.mid.JwtAuth(), ordersHandler.InsertOrder)
-	router.Get("/", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.FindOrder)
-	router.Get("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.FindOneOrder)
+	router.Post("/:user_id/:order_id/shipments", m.mid.JwtAuth(), m.mid.Authorize(2), handler.InsertShipment)
+}
+
+func (m *moduleFactory) ReturnsModule() {
+	fileUsecase := m.FilesModule().Usecase()
+	repository := returnsRepositories.ReturnsRepository(m.s.db)
+	usecase := returnsUsecases.ReturnsUsecase(repository)
+	handler := returnsHandlers.ReturnsHandler(usecase, m.s.cfg, fileUsecase)
+
+	// customer requests a return of their own order
+	ordersRouter := m.r.Group("/orders")
 
-	//admin แก้ได้ทั้งหมด แต่ customer แก้ได้แค่ status เป็น cancel
-	router.Patch("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.UpdateOrder)
+	ordersRouter.Post("/:user_id/:order_id/returns", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.InsertReturn)
+	ordersRouter.Get("/:user_id/:order_id/returns", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindOrderReturn)
 
+	router := m.r.Group("/returns")
+
+	router.Get("/", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindReturn)
+	router.Get("/:returnId", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindOneReturn)
+	router.Post("/:returnId/approve", m.mid.JwtAuth(), m.mid.Authorize(2), handler.ApproveReturn)
+	router.Post("/:returnId/reject", m.mid.JwtAuth(), m.mid.Authorize(2), handler.RejectReturn)
+	router.Post("/:returnId/receive", m.mid.JwtAuth(), m.mid.Authorize(2), handler.ReceiveReturn)
+	router.Post("/:returnId/refund", m.mid.JwtAuth(), m.mid.Authorize(2), handler.RefundReturn)
+}
+
+func (m *moduleFactory) ReportsModule() {
+	repository := reportsRepositories.ReportsRepository(m.s.db)
+	usecase := reportsUsecases.ReportsUsecase(repository)
+	handler := reportsHandlers.ReportsHandler(usecase, m.s.cfg)
+
+	router := m.r.Group("/reports")
+
+	router.Get("/summary", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindSummary)
+	router.Get("/revenue", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindRevenue)
+	router.Get("/status", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindStatusCount)
+	router.Get("/top-products", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindTopProduct)
+	router.Get("/top-categories", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindTopCategory)
+}
+
+func (m *moduleFactory) MessagesModule() {
+	fileUsecase := m.FilesModule().Usecase()
+	repository := messagesRepositories.MessagesRepository(m.s.db)
+	usecase := messagesUsecases.MessagesUsecase(repository)
+	handler := messagesHandlers.MessagesHandler(usecase, m.s.cfg, fileUsecase)
+
+	// thread of an order, admin sees internal notes
+	ordersRouter := m.r.Group("/orders")
+
+	ordersRouter.Get("/:user_id/messages/unread", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindUnread)
+	ordersRouter.Get("/:user_id/:order_id/messages", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindMessage)
+	ordersRouter.Post("/:user_id/:order_id/messages", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.InsertMessage)
+
+	// admin inbox, unread messages of customers on every order
+	router := m.r.Group("/messages")
+
+	router.Get("/unread", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindUnread)
+}
+
+// JobsModule adds periodic jobs to the scheduler, the scheduler is started by server
+func (m *moduleFactory) JobsModule(scheduler riScheduler.IScheduler) {
+	ordersUsecase := m.OrdersModule().Usecase()
+
+	if ttl := m.s.cfg.Shop().UnpaidOrderTtl(); ttl > 0 {
+		scheduler.Add(&riScheduler.Job{
+			Name:     "cancel-unpaid-orders",
+			Interval: time.Minute,
+			Run: func(ctx context.Context) error {
+				canceled, err := ordersUsecase.CancelExpiredOrder(ctx, ttl)
+				if err != nil {
+					return err
+				}
+				if canceled > 0 {
+					log.Printf("canceled %d unpaid orders", canceled)
+				}
+				return nil
+			},
+		})
+	}
 }
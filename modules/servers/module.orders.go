@@ -0,0 +1,68 @@
+package servers
+
+import (
+	"github.com/NatthawutSK/ri-shop/modules/coupons/couponsRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersHandlers"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersPricing"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersRepositories"
+	"github.com/NatthawutSK/ri-shop/modules/orders/ordersUsecases"
+)
+
+type IOrdersModule interface {
+	Init()
+	Repository() ordersRepositories.IOrdersRepository
+	Usecase() ordersUsecases.IOrdersUsecase
+	Handler() ordersHandlers.IOrdersHandler
+}
+
+type OrdersModule struct {
+	*moduleFactory
+	repository ordersRepositories.IOrdersRepository
+	usecase    ordersUsecases.IOrdersUsecase
+	handler    ordersHandlers.IOrdersHandler
+}
+
+func (m *moduleFactory) OrdersModule() IOrdersModule {
+	productsRepository := m.ProductsModule().Repository()
+	couponsRepository := couponsRepositories.CouponsRepository(m.s.db)
+	currenciesRepository := currenciesRepositories.CurrenciesRepository(m.s.db)
+	pricingEngine := ordersPricing.PricingEngine(m.s.cfg, productsRepository, couponsRepository, currenciesRepository)
+
+	repository := ordersRepositories.OrdersRepository(m.s.db)
+	usecase := ordersUsecases.OrdersUsecase(repository, productsRepository, pricingEngine, m.s.cfg)
+	handler := ordersHandlers.OrdersHandler(usecase, m.s.cfg, m.FilesModule().Usecase())
+
+	return &OrdersModule{
+		moduleFactory: m,
+		repository:    repository,
+		usecase:       usecase,
+		handler:       handler,
+	}
+}
+
+func (o *OrdersModule) Init() {
+	router := o.r.Group("/orders")
+
+	router.Post("/", o.mid.JwtAuth(), o.mid.Idempotency(), o.handler.InsertOrder)
+	router.Post("/quote", o.mid.JwtAuth(), o.handler.QuoteOrder)
+	router.Get("/", o.mid.JwtAuth(), o.mid.Authorize(2), o.handler.FindOrder)
+	router.Get("/export", o.mid.JwtAuth(), o.mid.Authorize(2), o.handler.ExportOrder)
+	router.Get("/:user_id", o.mid.JwtAuth(), o.mid.ParamsCheck(), o.handler.FindMyOrder)
+	router.Get("/:user_id/:order_id", o.mid.JwtAuth(), o.mid.ParamsCheck(), o.handler.FindOneOrder)
+
+	//admin แก้ได้ทั้งหมด แต่ customer แก้ได้แค่ status เป็น cancel
+	router.Patch("/:user_id/:order_id", o.mid.JwtAuth(), o.mid.ParamsCheck(), o.handler.UpdateOrder)
+	// แก้รายการสินค้าได้เฉพาะ order ที่ยังเป็น waiting และยังไม่ได้ส่ง slip
+	router.Put("/:user_id/:order_id/items", o.mid.JwtAuth(), o.mid.ParamsCheck(), o.handler.UpdateOrderItems)
+
+	router.Post("/:user_id/:order_id/transfer-slip", o.mid.JwtAuth(), o.mid.ParamsCheck(), o.handler.UploadTransferSlip)
+	router.Get("/:user_id/:order_id/invoice.pdf", o.mid.JwtAuth(), o.mid.ParamsCheck(), o.handler.GenerateInvoice)
+	router.Get("/:user_id/:order_id/payment/promptpay", o.mid.JwtAuth(), o.mid.ParamsCheck(), o.handler.GeneratePromptPay)
+	router.Post("/:user_id/:order_id/payment/approve", o.mid.JwtAuth(), o.mid.Authorize(2), o.handler.ApprovePayment)
+	router.Post("/:user_id/:order_id/payment/reject", o.mid.JwtAuth(), o.mid.Authorize(2), o.handler.RejectPayment)
+}
+
+func (o *OrdersModule) Repository() ordersRepositories.IOrdersRepository { return o.repository }
+func (o *OrdersModule) Usecase() ordersUsecases.IOrdersUsecase           { return o.usecase }
+func (o *OrdersModule) Handler() ordersHandlers.IOrdersHandler           { return o.handler }
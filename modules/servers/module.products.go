@@ -1,6 +1,7 @@
 package servers
 
 import (
+	"github.com/NatthawutSK/ri-shop/modules/currencies/currenciesRepositories"
 	"github.com/NatthawutSK/ri-shop/modules/products/productsHandlers"
 	"github.com/NatthawutSK/ri-shop/modules/products/productsRepositories"
 	"github.com/NatthawutSK/ri-shop/modules/products/productsUsecases"
@@ -22,7 +23,8 @@ type ProductsModule struct {
 
 func (m *moduleFactory) ProductsModule() IProductModule {
 	repository := productsRepositories.ProductsRepository(m.s.db, m.s.cfg, m.FilesModule().Usecase())
-	usecase := productsUsecases.ProductsUsecase(repository)
+	currenciesRepository := currenciesRepositories.CurrenciesRepository(m.s.db)
+	usecase := productsUsecases.ProductsUsecase(repository, currenciesRepository)
 	handler := productsHandlers.ProductsHandler(usecase, m.s.cfg, m.FilesModule().Usecase())
 
 	return &ProductsModule{
@@ -41,6 +43,14 @@ func (p *ProductsModule) Init() {
 	router.Get("/", p.mid.ApiKeyAuth(), p.handler.FindProduct)
 	router.Get("/:productId", p.mid.ApiKeyAuth(), p.handler.FindOneProduct)
 	router.Delete("/:productId", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.DeleteProduct)
+
+	router.Get("/:productId/stock", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.FindStock)
+	router.Patch("/:productId/stock", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.AdjustStock)
+
+	router.Get("/:productId/variants", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.FindVariants)
+	router.Post("/:productId/variants", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.AddVariant)
+	router.Patch("/:productId/variants/:variantId", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.UpdateVariant)
+	router.Delete("/:productId/variants/:variantId", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.DeleteVariant)
 }
 
 func (p *ProductsModule) Repository() productsRepositories.IProductsRepository { return p.repository }
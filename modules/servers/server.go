@@ -7,6 +7,7 @@ import (
 	"os/signal"
 
 	"github.com/NatthawutSK/ri-shop/config"
+	riScheduler "github.com/NatthawutSK/ri-shop/pkg/rischeduler"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 )
@@ -55,16 +56,29 @@ func (s *server) Start() {
 	modules.AppinfoModule()
 	modules.FilesModule().Init()
 	modules.ProductsModule().Init()
-	modules.OrdersModule()
+	modules.OrdersModule().Init()
+	modules.CouponsModule()
+	modules.CurrenciesModule()
+	modules.CartsModule()
+	modules.ShipmentsModule()
+	modules.ReturnsModule()
+	modules.ReportsModule()
+	modules.MessagesModule()
 
 	s.app.Use(middleware.RouterCheck())
 
+	// Background jobs
+	scheduler := riScheduler.NewScheduler(s.db)
+	modules.JobsModule(scheduler)
+	scheduler.Start()
+
 	//Graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		<-c
 		log.Println("server is shutting down...")
+		scheduler.Stop()
 		_ = s.app.Shutdown()
 	}()
 
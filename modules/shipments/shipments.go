@@ -0,0 +1,34 @@
+package shipments
+
+type Shipment struct {
+	Id             string          `json:"id" db:"id"`
+	OrderId        string          `json:"order_id" db:"order_id"`
+	UserId         string          `json:"-"` // owner of the order, from the url
+	Carrier        string          `json:"carrier" db:"carrier"`
+	TrackingNumber string          `json:"tracking_number" db:"tracking_number"`
+	ShippedAt      string          `json:"shipped_at" db:"shipped_at"`
+	Items          []*ShipmentItem `json:"items"`
+	CreatedBy      *string         `json:"created_by" db:"created_by"`
+	CreatedAt      string          `json:"created_at" db:"created_at"`
+	UpdatedAt      string          `json:"updated_at" db:"updated_at"`
+}
+
+// ShipmentItem is a part of an order line, a line can be split into many shipments
+type ShipmentItem struct {
+	Id              string `json:"id" db:"id"`
+	ProductsOrderId string `json:"products_order_id" db:"products_order_id"`
+	ProductId       string `json:"product_id" db:"product_id"`
+	Title           string `json:"title" db:"title"`
+	Qty             int    `json:"qty" db:"qty"`
+}
+
+// Carriers maps the carrier code that can be sent by admin to its name
+var Carriers = map[string]string{
+	"thailand_post": "Thailand Post",
+	"kerry":         "Kerry Express",
+	"flash":         "Flash Express",
+	"jt":            "J&T Express",
+	"ninja_van":     "Ninja Van",
+	"dhl":           "DHL",
+	"other":         "Other",
+}
@@ -0,0 +1,114 @@
+package shipmentsHandlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/config"
+	"github.com/NatthawutSK/ri-shop/modules/entities"
+	"github.com/NatthawutSK/ri-shop/modules/shipments"
+	"github.com/NatthawutSK/ri-shop/modules/shipments/shipmentsUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type shipmentsHandlerErrCode string
+
+const (
+	insertShipmentErr shipmentsHandlerErrCode = "shipments-001"
+)
+
+type IShipmentsHandler interface {
+	InsertShipment(c *fiber.Ctx) error
+}
+
+type shipmentsHandler struct {
+	cfg              config.IConfig
+	shipmentsUsecase shipmentsUsecases.IShipmentsUsecase
+}
+
+func ShipmentsHandler(shipmentsUsecase shipmentsUsecases.IShipmentsUsecase, cfg config.IConfig) IShipmentsHandler {
+	return &shipmentsHandler{
+		shipmentsUsecase: shipmentsUsecase,
+		cfg:              cfg,
+	}
+}
+
+func (h *shipmentsHandler) InsertShipment(c *fiber.Ctx) error {
+	req := &shipments.Shipment{
+		Items: make([]*shipments.ShipmentItem, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertShipmentErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.OrderId = strings.Trim(c.Params("order_id"), " ")
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	userId := c.Locals("userId").(string)
+	req.CreatedBy = &userId
+
+	req.Carrier = strings.ToLower(strings.Trim(req.Carrier, " "))
+	if shipments.Carriers[req.Carrier] == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertShipmentErr),
+			"carrier is invalid",
+		).Res()
+	}
+
+	req.TrackingNumber = strings.Trim(req.TrackingNumber, " ")
+	if req.TrackingNumber == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertShipmentErr),
+			"tracking number is required",
+		).Res()
+	}
+
+	// Date	YYYY-MM-DD, empty is now
+	if req.ShippedAt != "" {
+		shippedAt, err := time.Parse("2006-01-02", req.ShippedAt)
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertShipmentErr),
+				"shipped at is invalid",
+			).Res()
+		}
+		req.ShippedAt = shippedAt.Format("2006-01-02")
+	}
+
+	for _, item := range req.Items {
+		if item == nil || item.ProductsOrderId == "" || item.Qty < 1 {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertShipmentErr),
+				"items must have products_order_id and qty more than 0",
+			).Res()
+		}
+	}
+
+	shipment, err := h.shipmentsUsecase.InsertShipment(req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "cannot ship") ||
+			strings.HasPrefix(err.Error(), "item ") ||
+			err.Error() == "every item has been shipped" ||
+			err.Error() == "order not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertShipmentErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertShipmentErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, shipment).Res()
+}
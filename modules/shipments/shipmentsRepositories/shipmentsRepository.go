@@ -0,0 +1,228 @@
+package shipmentsRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NatthawutSK/ri-shop/modules/shipments"
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+	"github.com/jmoiron/sqlx"
+)
+
+type IShipmentsRepository interface {
+	FindOneShipment(shipmentId string) (*shipments.Shipment, error)
+	InsertShipment(req *shipments.Shipment) (string, error)
+}
+
+type shipmentsRepository struct {
+	db *sqlx.DB
+}
+
+func ShipmentsRepository(db *sqlx.DB) IShipmentsRepository {
+	return &shipmentsRepository{
+		db: db,
+	}
+}
+
+const selectShipmentQuery = `
+	SELECT
+		"s"."id",
+		"s"."order_id",
+		"s"."carrier",
+		"s"."tracking_number",
+		"s"."shipped_at",
+		(
+			SELECT
+				COALESCE(array_to_json(array_agg("it")), '[]'::json)
+			FROM (
+				SELECT
+					"si"."id",
+					"si"."products_order_id",
+					"po"."product"->>'id' AS "product_id",
+					"po"."product"->>'title' AS "title",
+					"si"."qty"
+				FROM "shipments_items" "si"
+					JOIN "products_orders" "po" ON "po"."id" = "si"."products_order_id"
+				WHERE "si"."shipment_id" = "s"."id"
+			) AS "it"
+		) AS "items",
+		"s"."created_by",
+		"s"."created_at",
+		"s"."updated_at"
+	FROM "shipments" "s"`
+
+func (r *shipmentsRepository) FindOneShipment(shipmentId string) (*shipments.Shipment, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (` + selectShipmentQuery + `
+	WHERE "s"."id" = $1
+	) AS "t";`
+
+	bytes := make([]byte, 0)
+	shipment := &shipments.Shipment{
+		Items: make([]*shipments.ShipmentItem, 0),
+	}
+
+	if err := r.db.Get(&bytes, query, shipmentId); err != nil {
+		return nil, fmt.Errorf("get shipment failed: %v", err)
+	}
+	if err := json.Unmarshal(bytes, &shipment); err != nil {
+		return nil, fmt.Errorf("unmarshal shipment failed: %v", err)
+	}
+	return shipment, nil
+}
+
+// InsertShipment ships items of an order, when no item is sent every remaining qty is shipped.
+// A waiting order is moved to shipping in the same transaction.
+func (r *shipmentsRepository) InsertShipment(req *shipments.Shipment) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	// lock the order so two shipments can't ship the same qty
+	var status, paymentStatus string
+	var totalPaid riMoney.Money
+	if err := tx.QueryRowxContext(ctx, `
+	SELECT
+		"status",
+		"payment_status",
+		"total_paid"
+	FROM "orders"
+	WHERE "id" = $1
+	AND "user_id" = $2
+	FOR UPDATE;`, req.OrderId, req.UserId).Scan(&status, &paymentStatus, &totalPaid); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("order not found")
+		}
+		return "", fmt.Errorf("get order failed: %v", err)
+	}
+
+	if status != "waiting" && status != "shipping" {
+		tx.Rollback()
+		return "", fmt.Errorf("cannot ship %s order", status)
+	}
+	if status == "waiting" && totalPaid > 0 && paymentStatus != "approved" {
+		tx.Rollback()
+		return "", fmt.Errorf("cannot ship order, the transfer slip must be approved")
+	}
+
+	remaining := make([]struct {
+		Id  string `db:"id"`
+		Qty int    `db:"qty"`
+	}, 0)
+	if err := tx.SelectContext(ctx, &remaining, `
+	SELECT
+		"po"."id",
+		("po"."qty" - COALESCE(SUM("si"."qty"), 0))::INT AS "qty"
+	FROM "products_orders" "po"
+		LEFT JOIN "shipments_items" "si" ON "si"."products_order_id" = "po"."id"
+	WHERE "po"."order_id" = $1
+	GROUP BY "po"."id", "po"."qty";`, req.OrderId); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("get remaining items failed: %v", err)
+	}
+
+	remainingMap := make(map[string]int)
+	for _, line := range remaining {
+		remainingMap[line.Id] = line.Qty
+	}
+
+	if len(req.Items) == 0 {
+		for _, line := range remaining {
+			if line.Qty > 0 {
+				req.Items = append(req.Items, &shipments.ShipmentItem{
+					ProductsOrderId: line.Id,
+					Qty:             line.Qty,
+				})
+			}
+		}
+		if len(req.Items) == 0 {
+			tx.Rollback()
+			return "", fmt.Errorf("every item has been shipped")
+		}
+	} else {
+		for _, item := range req.Items {
+			qty, ok := remainingMap[item.ProductsOrderId]
+			if !ok {
+				tx.Rollback()
+				return "", fmt.Errorf("item %s is not in the order", item.ProductsOrderId)
+			}
+			if item.Qty > qty {
+				tx.Rollback()
+				return "", fmt.Errorf("item %s has only %d left to ship", item.ProductsOrderId, qty)
+			}
+			// the same line can be sent twice
+			remainingMap[item.ProductsOrderId] -= item.Qty
+		}
+	}
+
+	if err := tx.QueryRowxContext(ctx, `
+	INSERT INTO "shipments" (
+		"order_id",
+		"carrier",
+		"tracking_number",
+		"shipped_at",
+		"created_by"
+	)
+	VALUES ($1, $2, $3, COALESCE(NULLIF($4, '')::TIMESTAMP, now()), $5)
+	RETURNING "id";`,
+		req.OrderId,
+		req.Carrier,
+		req.TrackingNumber,
+		req.ShippedAt,
+		req.CreatedBy,
+	).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("insert shipment failed: %v", err)
+	}
+
+	for _, item := range req.Items {
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO "shipments_items" (
+			"shipment_id",
+			"products_order_id",
+			"qty"
+		)
+		VALUES ($1, $2, $3);`, req.Id, item.ProductsOrderId, item.Qty); err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("insert shipment item failed: %v", err)
+		}
+	}
+
+	if status == "waiting" {
+		if _, err := tx.ExecContext(ctx, `
+		UPDATE "orders" SET
+			"status" = 'shipping'
+		WHERE "id" = $1;`, req.OrderId); err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("update order status failed: %v", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO "order_status_history" (
+			"order_id",
+			"from_status",
+			"to_status",
+			"changed_by"
+		)
+		VALUES ($1, $2, 'shipping', $3);`, req.OrderId, status, req.CreatedBy); err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("insert order status history failed: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return req.Id, nil
+}
@@ -0,0 +1,33 @@
+package shipmentsUsecases
+
+import (
+	"github.com/NatthawutSK/ri-shop/modules/shipments"
+	"github.com/NatthawutSK/ri-shop/modules/shipments/shipmentsRepositories"
+)
+
+type IShipmentsUsecase interface {
+	InsertShipment(req *shipments.Shipment) (*shipments.Shipment, error)
+}
+
+type shipmentsUsecase struct {
+	shipmentsRepository shipmentsRepositories.IShipmentsRepository
+}
+
+func ShipmentsUsecase(shipmentsRepository shipmentsRepositories.IShipmentsRepository) IShipmentsUsecase {
+	return &shipmentsUsecase{
+		shipmentsRepository: shipmentsRepository,
+	}
+}
+
+func (u *shipmentsUsecase) InsertShipment(req *shipments.Shipment) (*shipments.Shipment, error) {
+	shipmentId, err := u.shipmentsRepository.InsertShipment(req)
+	if err != nil {
+		return nil, err
+	}
+
+	shipment, err := u.shipmentsRepository.FindOneShipment(shipmentId)
+	if err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
@@ -3,6 +3,7 @@ package users
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -81,4 +82,51 @@ type Oauth struct {
 
 type UserRemoveCredential struct {
 	OauthId string `db:"id" json:"oauth_id" form:"oauth_id"`
-}
\ No newline at end of file
+}
+// UserAddress is a saved shipping address, it is copied to the order at checkout
+type UserAddress struct {
+	Id          string `db:"id" json:"id"`
+	UserId      string `db:"user_id" json:"user_id"`
+	Recipient   string `db:"recipient" json:"recipient" form:"recipient"`
+	Phone       string `db:"phone" json:"phone" form:"phone"`
+	Line1       string `db:"line1" json:"line1" form:"line1"`
+	Line2       string `db:"line2" json:"line2" form:"line2"`
+	Subdistrict string `db:"subdistrict" json:"subdistrict" form:"subdistrict"`
+	District    string `db:"district" json:"district" form:"district"`
+	Province    string `db:"province" json:"province" form:"province"`
+	PostalCode  string `db:"postal_code" json:"postal_code" form:"postal_code"`
+	IsDefault   bool   `db:"is_default" json:"is_default" form:"is_default"`
+	CreatedAt   string `db:"created_at" json:"created_at"`
+	UpdatedAt   string `db:"updated_at" json:"updated_at"`
+}
+
+func (obj *UserAddress) IsValid() error {
+	if obj.Recipient == "" ||
+		obj.Phone == "" ||
+		obj.Line1 == "" ||
+		obj.Subdistrict == "" ||
+		obj.District == "" ||
+		obj.Province == "" {
+		return fmt.Errorf("recipient, phone, line1, subdistrict, district and province are required")
+	}
+	if match, _ := regexp.MatchString(`^\d{5}$`, obj.PostalCode); !match {
+		return fmt.Errorf("postal_code must be 5 digits")
+	}
+	return nil
+}
+
+// Text is the address in one line, it is kept in "orders"."address"
+func (obj *UserAddress) Text() string {
+	parts := make([]string, 0)
+	for _, part := range []string{obj.Line1, obj.Line2, obj.Subdistrict, obj.District, obj.Province, obj.PostalCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Contact is kept in "orders"."contact"
+func (obj *UserAddress) Contact() string {
+	return fmt.Sprintf("%s %s", obj.Recipient, obj.Phone)
+}
@@ -21,6 +21,11 @@ const (
 	signUpAdminErr        userHandlerErrCode = "users-005"
 	generateAdminTokenErr userHandlerErrCode = "users-006"
 	getUserProfileErr     userHandlerErrCode = "users-007"
+	findAddressErr        userHandlerErrCode = "users-008"
+	findOneAddressErr     userHandlerErrCode = "users-009"
+	insertAddressErr      userHandlerErrCode = "users-010"
+	updateAddressErr      userHandlerErrCode = "users-011"
+	deleteAddressErr      userHandlerErrCode = "users-012"
 )
 
 type IUsersHandler interface {
@@ -31,6 +36,11 @@ type IUsersHandler interface {
 	SignOut(c *fiber.Ctx) error
 	GenerateAdminToken(c *fiber.Ctx) error
 	GetUserProfile(c *fiber.Ctx) error
+	FindAddress(c *fiber.Ctx) error
+	FindOneAddress(c *fiber.Ctx) error
+	InsertAddress(c *fiber.Ctx) error
+	UpdateAddress(c *fiber.Ctx) error
+	DeleteAddress(c *fiber.Ctx) error
 }
 
 type usersHandler struct {
@@ -260,3 +270,152 @@ func (h *usersHandler) GetUserProfile(c *fiber.Ctx) error {
 
 	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
 }
+
+func (h *usersHandler) FindAddress(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	addresses, err := h.userUsecase.FindAddress(userId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, addresses).Res()
+}
+
+func (h *usersHandler) FindOneAddress(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	addressId := strings.Trim(c.Params("address_id"), " ")
+
+	address, err := h.userUsecase.FindOneAddress(userId, addressId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(findOneAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, address).Res()
+}
+
+func (h *usersHandler) InsertAddress(c *fiber.Ctx) error {
+	req := new(users.UserAddress)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertAddressErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	trimAddress(req)
+
+	if err := req.IsValid(); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	address, err := h.userUsecase.InsertAddress(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusCreated, address).Res()
+}
+
+// UpdateAddress only changes fields in the body, the others are kept
+func (h *usersHandler) UpdateAddress(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	addressId := strings.Trim(c.Params("address_id"), " ")
+
+	req, err := h.userUsecase.FindOneAddress(userId, addressId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(updateAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateAddressErr),
+			err.Error(),
+		).Res()
+	}
+	// ไม่ให้ body เปลี่ยนเจ้าของหรือ id ได้
+	req.Id = addressId
+	req.UserId = userId
+	trimAddress(req)
+
+	if err := req.IsValid(); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	address, err := h.userUsecase.UpdateAddress(req)
+	if err != nil {
+		if err.Error() == "address not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateAddressErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, address).Res()
+}
+
+func (h *usersHandler) DeleteAddress(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	addressId := strings.Trim(c.Params("address_id"), " ")
+
+	if err := h.userUsecase.DeleteAddress(userId, addressId); err != nil {
+		if err.Error() == "address not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteAddressErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteAddressErr),
+			err.Error(),
+		).Res()
+	}
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func trimAddress(req *users.UserAddress) {
+	req.Recipient = strings.Trim(req.Recipient, " ")
+	req.Phone = strings.Trim(req.Phone, " ")
+	req.Line1 = strings.Trim(req.Line1, " ")
+	req.Line2 = strings.Trim(req.Line2, " ")
+	req.Subdistrict = strings.Trim(req.Subdistrict, " ")
+	req.District = strings.Trim(req.District, " ")
+	req.Province = strings.Trim(req.Province, " ")
+	req.PostalCode = strings.Trim(req.PostalCode, " ")
+}
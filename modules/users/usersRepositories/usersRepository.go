@@ -18,6 +18,11 @@ type IUsersRepository interface {
 	UpdateOauth(req *users.UserToken) error
 	GetProfile(userId string) (*users.User, error)
 	DeleteOauth(oauthId string) error
+	FindAddress(userId string) ([]*users.UserAddress, error)
+	FindOneAddress(userId, addressId string) (*users.UserAddress, error)
+	InsertAddress(req *users.UserAddress) (*users.UserAddress, error)
+	UpdateAddress(req *users.UserAddress) (*users.UserAddress, error)
+	DeleteAddress(userId, addressId string) error
 }
 
 type usersRepository struct {
@@ -150,3 +155,223 @@ func (r *usersRepository) DeleteOauth(oauthId string) error {
 	}
 	return nil
 }
+
+const selectAddressQuery = `
+	SELECT
+		"id",
+		"user_id",
+		"recipient",
+		"phone",
+		"line1",
+		"line2",
+		"subdistrict",
+		"district",
+		"province",
+		"postal_code",
+		"is_default",
+		"created_at",
+		"updated_at"
+	FROM "user_addresses"`
+
+func (r *usersRepository) FindAddress(userId string) ([]*users.UserAddress, error) {
+	query := selectAddressQuery + `
+	WHERE "user_id" = $1
+	ORDER BY "is_default" DESC, "created_at" DESC;`
+
+	addresses := make([]*users.UserAddress, 0)
+	if err := r.db.Select(&addresses, query, userId); err != nil {
+		return nil, fmt.Errorf("get addresses failed: %v", err)
+	}
+	return addresses, nil
+}
+
+func (r *usersRepository) FindOneAddress(userId, addressId string) (*users.UserAddress, error) {
+	query := selectAddressQuery + `
+	WHERE "user_id" = $1
+	AND "id" = $2;`
+
+	address := new(users.UserAddress)
+	if err := r.db.Get(address, query, userId, addressId); err != nil {
+		return nil, fmt.Errorf("address not found")
+	}
+	return address, nil
+}
+
+// unsetDefaultAddress must be called before another address becomes the default, there is only one default per user
+func unsetDefaultAddress(ctx context.Context, tx *sqlx.Tx, userId, exceptId string) error {
+	if _, err := tx.ExecContext(ctx, `
+	UPDATE "user_addresses" SET
+		"is_default" = FALSE
+	WHERE "user_id" = $1
+	AND "id"::TEXT <> $2
+	AND "is_default";`, userId, exceptId); err != nil {
+		return fmt.Errorf("unset default address failed: %v", err)
+	}
+	return nil
+}
+
+// InsertAddress saves a new address, the first address of the user is always the default
+func (r *usersRepository) InsertAddress(req *users.UserAddress) (*users.UserAddress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	var count int
+	if err := tx.GetContext(ctx, &count, `
+	SELECT
+		COUNT(*)
+	FROM "user_addresses"
+	WHERE "user_id" = $1;`, req.UserId); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("count addresses failed: %v", err)
+	}
+	if count == 0 {
+		req.IsDefault = true
+	}
+
+	if req.IsDefault {
+		if err := unsetDefaultAddress(ctx, tx, req.UserId, ""); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	address := new(users.UserAddress)
+	if err := tx.QueryRowxContext(ctx, `
+	INSERT INTO "user_addresses" (
+		"user_id",
+		"recipient",
+		"phone",
+		"line1",
+		"line2",
+		"subdistrict",
+		"district",
+		"province",
+		"postal_code",
+		"is_default"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING *;`,
+		req.UserId,
+		req.Recipient,
+		req.Phone,
+		req.Line1,
+		req.Line2,
+		req.Subdistrict,
+		req.District,
+		req.Province,
+		req.PostalCode,
+		req.IsDefault,
+	).StructScan(address); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("insert address failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return address, nil
+}
+
+func (r *usersRepository) UpdateAddress(req *users.UserAddress) (*users.UserAddress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	if req.IsDefault {
+		if err := unsetDefaultAddress(ctx, tx, req.UserId, req.Id); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	address := new(users.UserAddress)
+	if err := tx.QueryRowxContext(ctx, `
+	UPDATE "user_addresses" SET
+		"recipient" = $1,
+		"phone" = $2,
+		"line1" = $3,
+		"line2" = $4,
+		"subdistrict" = $5,
+		"district" = $6,
+		"province" = $7,
+		"postal_code" = $8,
+		"is_default" = $9
+	WHERE "id" = $10
+	AND "user_id" = $11
+	RETURNING *;`,
+		req.Recipient,
+		req.Phone,
+		req.Line1,
+		req.Line2,
+		req.Subdistrict,
+		req.District,
+		req.Province,
+		req.PostalCode,
+		req.IsDefault,
+		req.Id,
+		req.UserId,
+	).StructScan(address); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("address not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return address, nil
+}
+
+// DeleteAddress removes the address, the newest address left becomes the default when the default is removed
+func (r *usersRepository) DeleteAddress(userId, addressId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+
+	var isDefault bool
+	if err := tx.QueryRowxContext(ctx, `
+	DELETE FROM "user_addresses"
+	WHERE "id" = $1
+	AND "user_id" = $2
+	RETURNING "is_default";`, addressId, userId).Scan(&isDefault); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("address not found")
+	}
+
+	if isDefault {
+		if _, err := tx.ExecContext(ctx, `
+		UPDATE "user_addresses" SET
+			"is_default" = TRUE
+		WHERE "id" = (
+			SELECT
+				"id"
+			FROM "user_addresses"
+			WHERE "user_id" = $1
+			ORDER BY "created_at" DESC
+			LIMIT 1
+		);`, userId); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("set default address failed: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+	return nil
+}
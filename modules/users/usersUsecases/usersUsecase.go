@@ -17,6 +17,11 @@ type IUserUsecase interface {
 	RefreshPassport(req *users.UserRefreshCredential) (*users.UserPassport, error)
 	DeleteOauth(oauthId string) error
 	GetUserProfile(userId string) (*users.User, error)
+	FindAddress(userId string) ([]*users.UserAddress, error)
+	FindOneAddress(userId, addressId string) (*users.UserAddress, error)
+	InsertAddress(req *users.UserAddress) (*users.UserAddress, error)
+	UpdateAddress(req *users.UserAddress) (*users.UserAddress, error)
+	DeleteAddress(userId, addressId string) error
 }
 
 type UserUsecase struct {
@@ -168,3 +173,23 @@ func (u *UserUsecase) GetUserProfile(userId string) (*users.User, error) {
 	return profile, nil
 
 }
+
+func (u *UserUsecase) FindAddress(userId string) ([]*users.UserAddress, error) {
+	return u.usersRepository.FindAddress(userId)
+}
+
+func (u *UserUsecase) FindOneAddress(userId, addressId string) (*users.UserAddress, error) {
+	return u.usersRepository.FindOneAddress(userId, addressId)
+}
+
+func (u *UserUsecase) InsertAddress(req *users.UserAddress) (*users.UserAddress, error) {
+	return u.usersRepository.InsertAddress(req)
+}
+
+func (u *UserUsecase) UpdateAddress(req *users.UserAddress) (*users.UserAddress, error) {
+	return u.usersRepository.UpdateAddress(req)
+}
+
+func (u *UserUsecase) DeleteAddress(userId, addressId string) error {
+	return u.usersRepository.DeleteAddress(userId, addressId)
+}
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "stock_movements" CASCADE;
+
+DROP TYPE IF EXISTS "stock_movement_type";
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "stock";
+
+COMMIT;
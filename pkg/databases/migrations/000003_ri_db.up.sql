@@ -0,0 +1,53 @@
+BEGIN;
+
+--Stock on hand, never negative
+ALTER TABLE "products" ADD COLUMN "stock" INT NOT NULL DEFAULT 0 CHECK ("stock" >= 0);
+
+--Create enum
+CREATE TYPE "stock_movement_type" AS ENUM (
+    'adjust',
+    'reserve',
+    'release'
+);
+
+--Every change of "products"."stock" is recorded here
+CREATE TABLE "stock_movements" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "product_id" VARCHAR NOT NULL,
+  "order_id" VARCHAR,
+  "type" stock_movement_type NOT NULL,
+  "qty" INT NOT NULL,
+  "balance" INT NOT NULL,
+  "note" VARCHAR NOT NULL DEFAULT '',
+  "created_by" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "stock_movements" ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+ALTER TABLE "stock_movements" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE SET NULL;
+ALTER TABLE "stock_movements" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+CREATE INDEX "stock_movements_product_id_idx" ON "stock_movements" ("product_id", "created_at");
+
+--Opening stock of the products that already exist, set ri.opening_stock before migrating (see README)
+--otherwise they start at 0 and can't be ordered until their stock is adjusted
+UPDATE "products" SET
+  "stock" = GREATEST(COALESCE(NULLIF(current_setting('ri.opening_stock', true), ''), '0')::INT, 0);
+
+INSERT INTO "stock_movements" (
+  "product_id",
+  "type",
+  "qty",
+  "balance",
+  "note"
+)
+SELECT
+  "id",
+  'adjust',
+  "stock",
+  "stock",
+  'opening stock'
+FROM "products"
+WHERE "stock" > 0;
+
+COMMIT;
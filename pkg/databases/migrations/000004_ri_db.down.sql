@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "order_status_history" CASCADE;
+
+COMMIT;
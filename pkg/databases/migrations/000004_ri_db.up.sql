@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE "order_status_history" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "from_status" order_status,
+  "to_status" order_status NOT NULL,
+  "changed_by" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "order_status_history" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "order_status_history" ADD FOREIGN KEY ("changed_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+CREATE INDEX "order_status_history_order_id_idx" ON "order_status_history" ("order_id", "created_at");
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "subtotal";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "discount";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "shipping_fee";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "tax";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "total_paid";
+
+COMMIT;
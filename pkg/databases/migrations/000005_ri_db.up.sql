@@ -0,0 +1,23 @@
+BEGIN;
+
+--Price breakdown computed by the server when the order is placed
+ALTER TABLE "orders" ADD COLUMN "subtotal" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN "discount" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN "shipping_fee" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN "tax" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN "total_paid" FLOAT NOT NULL DEFAULT 0;
+
+--Existing orders only have the products snapshot
+UPDATE "orders" "o" SET
+  "subtotal" = "t"."subtotal",
+  "total_paid" = "t"."subtotal"
+FROM (
+  SELECT
+    "po"."order_id",
+    SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0)) AS "subtotal"
+  FROM "products_orders" "po"
+  GROUP BY "po"."order_id"
+) AS "t"
+WHERE "o"."id" = "t"."order_id";
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_coupons_table ON "coupons";
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "coupon_code";
+
+DROP TABLE IF EXISTS "coupon_usages" CASCADE;
+DROP TABLE IF EXISTS "coupons_categories" CASCADE;
+DROP TABLE IF EXISTS "coupons" CASCADE;
+
+DROP TYPE IF EXISTS "coupon_type";
+
+COMMIT;
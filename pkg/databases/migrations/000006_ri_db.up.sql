@@ -0,0 +1,54 @@
+BEGIN;
+
+--Create enum
+CREATE TYPE "coupon_type" AS ENUM (
+    'percentage',
+    'fixed'
+);
+
+CREATE TABLE "coupons" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "code" VARCHAR UNIQUE NOT NULL,
+  "type" coupon_type NOT NULL,
+  "value" FLOAT NOT NULL CHECK ("value" > 0),
+  "max_discount" FLOAT NOT NULL DEFAULT 0,
+  "min_order" FLOAT NOT NULL DEFAULT 0,
+  "usage_limit" INT NOT NULL DEFAULT 0,
+  "usage_limit_per_user" INT NOT NULL DEFAULT 0,
+  "used_count" INT NOT NULL DEFAULT 0,
+  "starts_at" TIMESTAMP,
+  "expires_at" TIMESTAMP,
+  "is_active" BOOLEAN NOT NULL DEFAULT TRUE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+--Empty = every category
+CREATE TABLE "coupons_categories" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "coupon_id" uuid NOT NULL,
+  "category_id" INT NOT NULL
+);
+
+CREATE TABLE "coupon_usages" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "coupon_id" uuid NOT NULL,
+  "order_id" VARCHAR NOT NULL,
+  "user_id" VARCHAR NOT NULL,
+  "discount" FLOAT NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "orders" ADD COLUMN "coupon_code" VARCHAR;
+
+ALTER TABLE "coupons_categories" ADD FOREIGN KEY ("coupon_id") REFERENCES "coupons" ("id") ON DELETE CASCADE;
+ALTER TABLE "coupons_categories" ADD FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON DELETE CASCADE;
+ALTER TABLE "coupon_usages" ADD FOREIGN KEY ("coupon_id") REFERENCES "coupons" ("id") ON DELETE CASCADE;
+ALTER TABLE "coupon_usages" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "coupon_usages" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "coupon_usages_coupon_id_user_id_idx" ON "coupon_usages" ("coupon_id", "user_id");
+
+CREATE TRIGGER set_updated_at_timestamp_coupons_table BEFORE UPDATE ON "coupons" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
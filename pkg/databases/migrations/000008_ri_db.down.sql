@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "orders_payment_status_idx";
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "payment_reviewed_at";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "payment_reviewed_by";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "payment_note";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "payment_status";
+
+DROP TYPE IF EXISTS "payment_status";
+
+COMMIT;
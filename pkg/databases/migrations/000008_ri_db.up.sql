@@ -0,0 +1,27 @@
+BEGIN;
+
+CREATE TYPE "payment_status" AS ENUM (
+  'unpaid',
+  'pending_review',
+  'approved',
+  'rejected'
+);
+
+ALTER TABLE "orders" ADD COLUMN "payment_status" payment_status NOT NULL DEFAULT 'unpaid';
+ALTER TABLE "orders" ADD COLUMN "payment_note" VARCHAR;
+ALTER TABLE "orders" ADD COLUMN "payment_reviewed_by" VARCHAR;
+ALTER TABLE "orders" ADD COLUMN "payment_reviewed_at" TIMESTAMP;
+
+ALTER TABLE "orders" ADD FOREIGN KEY ("payment_reviewed_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+-- orders that already left waiting were paid before the review flow existed
+UPDATE "orders" SET
+  "payment_status" = CASE
+    WHEN "status" IN ('shipping', 'completed') THEN 'approved'::payment_status
+    WHEN "status" = 'waiting' AND "transfer_slip" IS NOT NULL THEN 'pending_review'::payment_status
+    ELSE 'unpaid'::payment_status
+  END;
+
+CREATE INDEX "orders_payment_status_idx" ON "orders" ("payment_status");
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "promptpay_amount";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "promptpay_payload";
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "orders" ADD COLUMN "promptpay_payload" VARCHAR;
+ALTER TABLE "orders" ADD COLUMN "promptpay_amount" FLOAT;
+
+COMMIT;
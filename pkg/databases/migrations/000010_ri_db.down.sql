@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_shipments_table ON "shipments";
+DROP TABLE IF EXISTS "shipments_items" CASCADE;
+DROP TABLE IF EXISTS "shipments" CASCADE;
+
+COMMIT;
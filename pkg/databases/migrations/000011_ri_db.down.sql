@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "invoices" CASCADE;
+DROP SEQUENCE IF EXISTS invoices_no_seq;
+
+COMMIT;
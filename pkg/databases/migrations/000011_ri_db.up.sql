@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE SEQUENCE invoices_no_seq START WITH 1 INCREMENT BY 1;
+
+CREATE TABLE "invoices" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "invoice_no" VARCHAR UNIQUE NOT NULL DEFAULT CONCAT('INV', LPAD(NEXTVAL('invoices_no_seq')::TEXT, 8, '0')),
+  "order_id" VARCHAR UNIQUE NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "invoices" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+
+COMMIT;
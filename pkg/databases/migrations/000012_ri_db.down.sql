@@ -0,0 +1,35 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_returns_table ON "returns";
+DROP TABLE IF EXISTS "returns_items" CASCADE;
+DROP TABLE IF EXISTS "returns" CASCADE;
+DROP TYPE IF EXISTS "return_status";
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "refunded";
+
+--Enum values can't be dropped, the types are created again without them
+DELETE FROM "stock_movements" WHERE "type" = 'return';
+ALTER TYPE "stock_movement_type" RENAME TO "stock_movement_type_old";
+CREATE TYPE "stock_movement_type" AS ENUM (
+    'adjust',
+    'reserve',
+    'release'
+);
+ALTER TABLE "stock_movements" ALTER COLUMN "type" TYPE stock_movement_type USING "type"::TEXT::stock_movement_type;
+DROP TYPE "stock_movement_type_old";
+
+UPDATE "orders" SET "status" = 'completed' WHERE "status" IN ('return_requested', 'refunded');
+DELETE FROM "order_status_history" WHERE "from_status" IN ('return_requested', 'refunded') OR "to_status" IN ('return_requested', 'refunded');
+ALTER TYPE "order_status" RENAME TO "order_status_old";
+CREATE TYPE "order_status" AS ENUM (
+    'waiting',
+    'shipping',
+    'completed',
+    'canceled'
+);
+ALTER TABLE "orders" ALTER COLUMN "status" TYPE order_status USING "status"::TEXT::order_status;
+ALTER TABLE "order_status_history" ALTER COLUMN "from_status" TYPE order_status USING "from_status"::TEXT::order_status;
+ALTER TABLE "order_status_history" ALTER COLUMN "to_status" TYPE order_status USING "to_status"::TEXT::order_status;
+DROP TYPE "order_status_old";
+
+COMMIT;
@@ -0,0 +1,57 @@
+BEGIN;
+
+--New values can't be used in this transaction, they are only added here
+ALTER TYPE "order_status" ADD VALUE IF NOT EXISTS 'return_requested';
+ALTER TYPE "order_status" ADD VALUE IF NOT EXISTS 'refunded';
+
+ALTER TYPE "stock_movement_type" ADD VALUE IF NOT EXISTS 'return';
+
+--Sum of every refund of the order
+ALTER TABLE "orders" ADD COLUMN "refunded" FLOAT NOT NULL DEFAULT 0;
+
+CREATE TYPE "return_status" AS ENUM (
+  'requested',
+  'approved',
+  'rejected',
+  'received',
+  'refunded'
+);
+
+CREATE TABLE "returns" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "user_id" VARCHAR NOT NULL,
+  "status" return_status NOT NULL DEFAULT 'requested',
+  "reason" VARCHAR NOT NULL,
+  "photos" jsonb NOT NULL DEFAULT '[]',
+  "admin_note" VARCHAR,
+  "refund_amount" FLOAT NOT NULL DEFAULT 0,
+  "reviewed_by" VARCHAR,
+  "reviewed_at" TIMESTAMP,
+  "received_at" TIMESTAMP,
+  "refunded_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "returns_items" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "return_id" uuid NOT NULL,
+  "products_order_id" uuid NOT NULL,
+  "qty" INT NOT NULL CHECK ("qty" > 0),
+  "received_qty" INT NOT NULL DEFAULT 0 CHECK ("received_qty" >= 0 AND "received_qty" <= "qty")
+);
+
+ALTER TABLE "returns" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "returns" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "returns" ADD FOREIGN KEY ("reviewed_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+ALTER TABLE "returns_items" ADD FOREIGN KEY ("return_id") REFERENCES "returns" ("id") ON DELETE CASCADE;
+ALTER TABLE "returns_items" ADD FOREIGN KEY ("products_order_id") REFERENCES "products_orders" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "returns_order_id_idx" ON "returns" ("order_id");
+CREATE INDEX "returns_status_idx" ON "returns" ("status", "created_at");
+CREATE INDEX "returns_items_return_id_idx" ON "returns_items" ("return_id");
+
+CREATE TRIGGER set_updated_at_timestamp_returns_table BEFORE UPDATE ON "returns" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
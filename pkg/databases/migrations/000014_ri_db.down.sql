@@ -0,0 +1,18 @@
+BEGIN;
+
+ALTER TABLE "invoices" DROP COLUMN IF EXISTS "tax_invoice_no";
+DROP SEQUENCE IF EXISTS tax_invoices_no_seq;
+
+ALTER TABLE "products_orders" DROP COLUMN IF EXISTS "gross";
+ALTER TABLE "products_orders" DROP COLUMN IF EXISTS "vat";
+ALTER TABLE "products_orders" DROP COLUMN IF EXISTS "net";
+ALTER TABLE "products_orders" DROP COLUMN IF EXISTS "discount";
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "buyer_branch";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "buyer_tax_id";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "gross_amount";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "net_amount";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "vat_inclusive";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "vat_rate";
+
+COMMIT;
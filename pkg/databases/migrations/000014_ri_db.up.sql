@@ -0,0 +1,35 @@
+BEGIN;
+
+--VAT breakdown of the order, "tax" is the VAT amount
+ALTER TABLE "orders" ADD COLUMN "vat_rate" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN "vat_inclusive" BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE "orders" ADD COLUMN "net_amount" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN "gross_amount" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN "buyer_tax_id" VARCHAR(13);
+ALTER TABLE "orders" ADD COLUMN "buyer_branch" VARCHAR(5);
+
+--VAT of every line, discount is the part of the order discount given to the line
+ALTER TABLE "products_orders" ADD COLUMN "discount" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "products_orders" ADD COLUMN "net" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "products_orders" ADD COLUMN "vat" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "products_orders" ADD COLUMN "gross" FLOAT NOT NULL DEFAULT 0;
+
+--Old orders always added VAT on top of the price
+UPDATE "orders" SET
+  "net_amount" = "subtotal" - "discount",
+  "gross_amount" = "subtotal" - "discount" + "tax",
+  "vat_rate" = CASE
+    WHEN "subtotal" - "discount" > 0 THEN ROUND(("tax" * 100 / ("subtotal" - "discount"))::NUMERIC, 2)
+    ELSE 0
+  END;
+
+UPDATE "products_orders" SET
+  "net" = COALESCE(("product"->>'price')::FLOAT, 0) * "qty",
+  "gross" = COALESCE(("product"->>'price')::FLOAT, 0) * "qty";
+
+--Tax invoice numbers run separately from invoice numbers and order ids
+CREATE SEQUENCE tax_invoices_no_seq START WITH 1 INCREMENT BY 1;
+
+ALTER TABLE "invoices" ADD COLUMN "tax_invoice_no" VARCHAR UNIQUE;
+
+COMMIT;
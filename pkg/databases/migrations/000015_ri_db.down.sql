@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_idempotency_keys_table ON "idempotency_keys";
+DROP TABLE IF EXISTS "idempotency_keys" CASCADE;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+--Response of a request sent with Idempotency-Key header, "status_code" is NULL while the request is running
+CREATE TABLE "idempotency_keys" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "key" VARCHAR(255) NOT NULL,
+  "user_id" VARCHAR NOT NULL CHECK ("user_id" <> ''),
+  "method" VARCHAR NOT NULL,
+  "path" VARCHAR NOT NULL,
+  "request_hash" VARCHAR NOT NULL,
+  "status_code" INT,
+  "content_type" VARCHAR NOT NULL DEFAULT '',
+  "response_body" TEXT NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+--Anonymous requests use anonymous:<client ip> as user, so the key is unique per client
+CREATE UNIQUE INDEX "idempotency_keys_user_id_key_idx" ON "idempotency_keys" ("user_id", "key");
+CREATE INDEX "idempotency_keys_created_at_idx" ON "idempotency_keys" ("created_at");
+
+CREATE TRIGGER set_updated_at_timestamp_idempotency_keys_table BEFORE UPDATE ON "idempotency_keys" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "orders_status_created_at_idx";
+ALTER TABLE "order_status_history" DROP COLUMN IF EXISTS "note";
+
+COMMIT;
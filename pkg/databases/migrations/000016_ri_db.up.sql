@@ -0,0 +1,9 @@
+BEGIN;
+
+--Why the status was changed, e.g. the order was canceled by the system because it was not paid in time
+ALTER TABLE "order_status_history" ADD COLUMN "note" VARCHAR;
+
+--Used by the job that cancels unpaid orders
+CREATE INDEX "orders_status_created_at_idx" ON "orders" ("status", "created_at");
+
+COMMIT;
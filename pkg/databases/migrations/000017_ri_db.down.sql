@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_order_messages_table ON "order_messages";
+DROP TABLE IF EXISTS "order_message_reads" CASCADE;
+DROP TABLE IF EXISTS "order_messages" CASCADE;
+
+COMMIT;
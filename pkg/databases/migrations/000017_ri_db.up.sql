@@ -0,0 +1,33 @@
+BEGIN;
+
+--Conversation of an order, internal notes are only shown to admins
+CREATE TABLE "order_messages" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "user_id" VARCHAR NOT NULL,
+  "is_admin" BOOLEAN NOT NULL DEFAULT FALSE,
+  "is_internal" BOOLEAN NOT NULL DEFAULT FALSE,
+  "message" VARCHAR NOT NULL DEFAULT '',
+  "attachments" jsonb NOT NULL DEFAULT '[]',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+--Last time a user opened the messages of an order, newer messages are unread
+CREATE TABLE "order_message_reads" (
+  "order_id" VARCHAR NOT NULL,
+  "user_id" VARCHAR NOT NULL,
+  "last_read_at" TIMESTAMP NOT NULL DEFAULT now(),
+  PRIMARY KEY ("order_id", "user_id")
+);
+
+ALTER TABLE "order_messages" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "order_messages" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "order_message_reads" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "order_message_reads" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "order_messages_order_id_idx" ON "order_messages" ("order_id", "created_at");
+
+CREATE TRIGGER set_updated_at_timestamp_order_messages_table BEFORE UPDATE ON "order_messages" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "order_item_changes" CASCADE;
+
+COMMIT;
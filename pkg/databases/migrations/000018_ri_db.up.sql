@@ -0,0 +1,21 @@
+BEGIN;
+
+--Audit trail of lines edited on a waiting order, lines are kept as they were before and after the edit
+CREATE TABLE "order_item_changes" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "changed_by" VARCHAR,
+  "note" VARCHAR,
+  "items_before" jsonb NOT NULL DEFAULT '[]',
+  "items_after" jsonb NOT NULL DEFAULT '[]',
+  "total_before" FLOAT NOT NULL DEFAULT 0,
+  "total_after" FLOAT NOT NULL DEFAULT 0,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "order_item_changes" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "order_item_changes" ADD FOREIGN KEY ("changed_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+CREATE INDEX "order_item_changes_order_id_idx" ON "order_item_changes" ("order_id", "created_at");
+
+COMMIT;
@@ -0,0 +1,30 @@
+BEGIN;
+
+ALTER TABLE "products" ALTER COLUMN "price" TYPE FLOAT USING "price"::FLOAT;
+
+ALTER TABLE "orders" ALTER COLUMN "subtotal" TYPE FLOAT USING "subtotal"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "discount" TYPE FLOAT USING "discount"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "shipping_fee" TYPE FLOAT USING "shipping_fee"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "tax" TYPE FLOAT USING "tax"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "total_paid" TYPE FLOAT USING "total_paid"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "refunded" TYPE FLOAT USING "refunded"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "net_amount" TYPE FLOAT USING "net_amount"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "gross_amount" TYPE FLOAT USING "gross_amount"::FLOAT;
+ALTER TABLE "orders" ALTER COLUMN "promptpay_amount" TYPE FLOAT USING "promptpay_amount"::FLOAT;
+
+ALTER TABLE "products_orders" ALTER COLUMN "discount" TYPE FLOAT USING "discount"::FLOAT;
+ALTER TABLE "products_orders" ALTER COLUMN "net" TYPE FLOAT USING "net"::FLOAT;
+ALTER TABLE "products_orders" ALTER COLUMN "vat" TYPE FLOAT USING "vat"::FLOAT;
+ALTER TABLE "products_orders" ALTER COLUMN "gross" TYPE FLOAT USING "gross"::FLOAT;
+
+ALTER TABLE "coupons" ALTER COLUMN "value" TYPE FLOAT USING "value"::FLOAT;
+ALTER TABLE "coupons" ALTER COLUMN "max_discount" TYPE FLOAT USING "max_discount"::FLOAT;
+ALTER TABLE "coupons" ALTER COLUMN "min_order" TYPE FLOAT USING "min_order"::FLOAT;
+ALTER TABLE "coupon_usages" ALTER COLUMN "discount" TYPE FLOAT USING "discount"::FLOAT;
+
+ALTER TABLE "returns" ALTER COLUMN "refund_amount" TYPE FLOAT USING "refund_amount"::FLOAT;
+
+ALTER TABLE "order_item_changes" ALTER COLUMN "total_before" TYPE FLOAT USING "total_before"::FLOAT;
+ALTER TABLE "order_item_changes" ALTER COLUMN "total_after" TYPE FLOAT USING "total_after"::FLOAT;
+
+COMMIT;
@@ -0,0 +1,49 @@
+BEGIN;
+
+--Money is kept as NUMERIC with 2 digits (satang), FLOAT values are rounded to satang once here
+ALTER TABLE "products" ALTER COLUMN "price" TYPE NUMERIC(14, 2) USING ROUND("price"::NUMERIC, 2);
+
+ALTER TABLE "orders" ALTER COLUMN "subtotal" TYPE NUMERIC(14, 2) USING ROUND("subtotal"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "discount" TYPE NUMERIC(14, 2) USING ROUND("discount"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "shipping_fee" TYPE NUMERIC(14, 2) USING ROUND("shipping_fee"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "tax" TYPE NUMERIC(14, 2) USING ROUND("tax"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "total_paid" TYPE NUMERIC(14, 2) USING ROUND("total_paid"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "refunded" TYPE NUMERIC(14, 2) USING ROUND("refunded"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "net_amount" TYPE NUMERIC(14, 2) USING ROUND("net_amount"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "gross_amount" TYPE NUMERIC(14, 2) USING ROUND("gross_amount"::NUMERIC, 2);
+ALTER TABLE "orders" ALTER COLUMN "promptpay_amount" TYPE NUMERIC(14, 2) USING ROUND("promptpay_amount"::NUMERIC, 2);
+
+ALTER TABLE "products_orders" ALTER COLUMN "discount" TYPE NUMERIC(14, 2) USING ROUND("discount"::NUMERIC, 2);
+ALTER TABLE "products_orders" ALTER COLUMN "net" TYPE NUMERIC(14, 2) USING ROUND("net"::NUMERIC, 2);
+ALTER TABLE "products_orders" ALTER COLUMN "vat" TYPE NUMERIC(14, 2) USING ROUND("vat"::NUMERIC, 2);
+ALTER TABLE "products_orders" ALTER COLUMN "gross" TYPE NUMERIC(14, 2) USING ROUND("gross"::NUMERIC, 2);
+
+--value of a percentage coupon is the percent
+ALTER TABLE "coupons" ALTER COLUMN "value" TYPE NUMERIC(14, 2) USING ROUND("value"::NUMERIC, 2);
+ALTER TABLE "coupons" ALTER COLUMN "max_discount" TYPE NUMERIC(14, 2) USING ROUND("max_discount"::NUMERIC, 2);
+ALTER TABLE "coupons" ALTER COLUMN "min_order" TYPE NUMERIC(14, 2) USING ROUND("min_order"::NUMERIC, 2);
+ALTER TABLE "coupon_usages" ALTER COLUMN "discount" TYPE NUMERIC(14, 2) USING ROUND("discount"::NUMERIC, 2);
+
+ALTER TABLE "returns" ALTER COLUMN "refund_amount" TYPE NUMERIC(14, 2) USING ROUND("refund_amount"::NUMERIC, 2);
+
+ALTER TABLE "order_item_changes" ALTER COLUMN "total_before" TYPE NUMERIC(14, 2) USING ROUND("total_before"::NUMERIC, 2);
+ALTER TABLE "order_item_changes" ALTER COLUMN "total_after" TYPE NUMERIC(14, 2) USING ROUND("total_after"::NUMERIC, 2);
+
+--Price in the product snapshot of order lines and in the item audit trail
+UPDATE "products_orders" SET
+  "product" = jsonb_set("product", '{price}', to_jsonb(ROUND(("product"->>'price')::NUMERIC, 2)))
+WHERE "product" ? 'price';
+
+UPDATE "order_item_changes" SET
+  "items_before" = (
+    SELECT
+      COALESCE(jsonb_agg(jsonb_set("it", '{unit_price}', to_jsonb(ROUND(("it"->>'unit_price')::NUMERIC, 2)))), '[]'::jsonb)
+    FROM jsonb_array_elements("items_before") AS "it"
+  ),
+  "items_after" = (
+    SELECT
+      COALESCE(jsonb_agg(jsonb_set("it", '{unit_price}', to_jsonb(ROUND(("it"->>'unit_price')::NUMERIC, 2)))), '[]'::jsonb)
+    FROM jsonb_array_elements("items_after") AS "it"
+  );
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "currency_total";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "exchange_rate";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "currency";
+
+DROP TABLE IF EXISTS "currencies" CASCADE;
+
+COMMIT;
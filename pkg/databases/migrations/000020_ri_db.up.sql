@@ -0,0 +1,41 @@
+BEGIN;
+
+--Rate is baht of 1 unit of the currency, decimals is the minor unit that converted prices are rounded to
+CREATE TABLE "currencies" (
+  "code" VARCHAR(3) NOT NULL UNIQUE PRIMARY KEY,
+  "name" VARCHAR NOT NULL,
+  "symbol" VARCHAR NOT NULL DEFAULT '',
+  "decimals" INT NOT NULL DEFAULT 2 CHECK ("decimals" BETWEEN 0 AND 2),
+  "rate" NUMERIC(20, 8) NOT NULL CHECK ("rate" > 0),
+  "is_active" BOOLEAN NOT NULL DEFAULT TRUE,
+  "updated_by" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "currencies" ADD FOREIGN KEY ("updated_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+CREATE TRIGGER set_updated_at_timestamp_currencies_table BEFORE UPDATE ON "currencies" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+--Baht is the base currency, every amount in the database is baht
+INSERT INTO "currencies" (
+  "code",
+  "name",
+  "symbol",
+  "decimals",
+  "rate"
+)
+VALUES ('THB', 'Thai Baht', '฿', 2, 1);
+
+--Currency the customer checked out with, totals of the order stay in baht
+ALTER TABLE "orders" ADD COLUMN "currency" VARCHAR(3) NOT NULL DEFAULT 'THB';
+ALTER TABLE "orders" ADD COLUMN "exchange_rate" NUMERIC(20, 8) NOT NULL DEFAULT 1;
+ALTER TABLE "orders" ADD COLUMN "currency_total" NUMERIC(14, 2);
+
+UPDATE "orders" SET "currency_total" = "total_paid";
+ALTER TABLE "orders" ALTER COLUMN "currency_total" SET NOT NULL;
+ALTER TABLE "orders" ALTER COLUMN "currency_total" SET DEFAULT 0;
+
+ALTER TABLE "orders" ADD FOREIGN KEY ("currency") REFERENCES "currencies" ("code") ON UPDATE CASCADE;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS "pg_trgm";
+
+--English words are stemmed, simple keeps every word as it is so words of other languages can still be matched.
+--Thai has no space between words, it is matched by the trigram indexes below.
+ALTER TABLE "products" ADD COLUMN "search_vector" tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('english', COALESCE("title", '')), 'A') ||
+  setweight(to_tsvector('simple', COALESCE("title", '')), 'A') ||
+  setweight(to_tsvector('english', COALESCE("description", '')), 'B') ||
+  setweight(to_tsvector('simple', COALESCE("description", '')), 'B')
+) STORED;
+
+CREATE INDEX "products_search_vector_idx" ON "products" USING GIN ("search_vector");
+
+--Used by LIKE and by similarity of misspelled words
+CREATE INDEX "products_title_trgm_idx" ON "products" USING GIN (LOWER("title") gin_trgm_ops);
+CREATE INDEX "products_description_trgm_idx" ON "products" USING GIN (LOWER("description") gin_trgm_ops);
+
+COMMIT;
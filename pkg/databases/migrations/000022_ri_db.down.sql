@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE "products_orders" DROP COLUMN IF EXISTS "variant_id";
+
+DROP TABLE IF EXISTS "product_variant_images" CASCADE;
+DROP TABLE IF EXISTS "product_variants" CASCADE;
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "options";
+
+COMMIT;
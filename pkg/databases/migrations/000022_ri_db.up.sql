@@ -0,0 +1,36 @@
+BEGIN;
+
+--Option types of the product such as [{"name": "size", "values": ["S", "M"]}], a variant picks one value of every option
+ALTER TABLE "products" ADD COLUMN "options" jsonb NOT NULL DEFAULT '[]';
+
+--Price is the product price when it is null, options is {"size": "M", "colour": "red"}
+CREATE TABLE "product_variants" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "product_id" VARCHAR NOT NULL,
+  "sku" VARCHAR NOT NULL UNIQUE,
+  "options" jsonb NOT NULL DEFAULT '{}',
+  "price" NUMERIC(14, 2) CHECK ("price" >= 0),
+  "is_active" BOOLEAN NOT NULL DEFAULT TRUE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE ("product_id", "options")
+);
+
+CREATE TABLE "product_variant_images" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "filename" VARCHAR NOT NULL,
+  "url" VARCHAR NOT NULL,
+  "variant_id" uuid NOT NULL
+);
+
+ALTER TABLE "product_variants" ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+ALTER TABLE "product_variant_images" ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_product_variants_table BEFORE UPDATE ON "product_variants" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+--Variant that was bought, the product snapshot keeps the variant in "variant" so the line is still readable when the variant is deleted
+ALTER TABLE "products_orders" ADD COLUMN "variant_id" uuid;
+
+ALTER TABLE "products_orders" ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id") ON DELETE SET NULL;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+--Only the primary category is kept, the old reads expect one category per product
+DELETE FROM "products_categories" WHERE "position" > 0;
+
+ALTER TABLE "products_categories" DROP CONSTRAINT IF EXISTS "products_categories_product_id_category_id_key";
+ALTER TABLE "products_categories" DROP COLUMN IF EXISTS "position";
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+--Position keeps the order of the categories of a product, the first category (position 0) is the primary category
+ALTER TABLE "products_categories" ADD COLUMN "position" INT NOT NULL DEFAULT 0;
+
+--A product was in one category only, a repeated row is removed before the unique rule is added
+DELETE FROM "products_categories" "a"
+USING "products_categories" "b"
+WHERE "a"."product_id" = "b"."product_id"
+AND "a"."category_id" = "b"."category_id"
+AND "a"."id" > "b"."id";
+
+ALTER TABLE "products_categories" ADD CONSTRAINT "products_categories_product_id_category_id_key" UNIQUE ("product_id", "category_id");
+
+COMMIT;
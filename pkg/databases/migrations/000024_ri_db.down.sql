@@ -0,0 +1,10 @@
+BEGIN;
+
+--Only one line of a product can be kept
+DELETE FROM "carts_items" WHERE "variant_id" IS NOT NULL;
+
+ALTER TABLE "carts_items" DROP CONSTRAINT IF EXISTS "carts_items_cart_id_product_id_variant_id_key";
+ALTER TABLE "carts_items" DROP COLUMN IF EXISTS "variant_id";
+ALTER TABLE "carts_items" ADD CONSTRAINT "carts_items_cart_id_product_id_key" UNIQUE ("cart_id", "product_id");
+
+COMMIT;
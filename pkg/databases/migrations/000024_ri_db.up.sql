@@ -0,0 +1,12 @@
+BEGIN;
+
+--Variant of the product in the cart, null when the product has no variant
+ALTER TABLE "carts_items" ADD COLUMN "variant_id" uuid;
+
+ALTER TABLE "carts_items" ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id") ON DELETE CASCADE;
+
+--Every variant is its own line, null is the same value so a product without variant is still one line
+ALTER TABLE "carts_items" DROP CONSTRAINT IF EXISTS "carts_items_cart_id_product_id_key";
+ALTER TABLE "carts_items" ADD CONSTRAINT "carts_items_cart_id_product_id_variant_id_key" UNIQUE NULLS NOT DISTINCT ("cart_id", "product_id", "variant_id");
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "stock_movements_variant_id_idx";
+
+ALTER TABLE "stock_movements" DROP COLUMN IF EXISTS "variant_id";
+ALTER TABLE "product_variants" DROP COLUMN IF EXISTS "stock";
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+--A variant keeps its own stock, "products"."stock" is only used by products without variants
+ALTER TABLE "product_variants" ADD COLUMN "stock" INT NOT NULL DEFAULT 0 CHECK ("stock" >= 0);
+
+--Movement of a variant stock, null when the stock of the product is changed
+ALTER TABLE "stock_movements" ADD COLUMN "variant_id" uuid;
+
+--Movements are the stock history and hold what open orders reserved, a variant with movements is deactivated instead of deleted
+ALTER TABLE "stock_movements" ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id");
+
+CREATE INDEX "stock_movements_variant_id_idx" ON "stock_movements" ("variant_id", "created_at");
+
+COMMIT;
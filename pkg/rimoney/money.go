@@ -0,0 +1,204 @@
+package riMoney
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in satang (1/100 baht), it is written to json and database as a decimal with 2 digits
+// so columns are NUMERIC(14, 2) and the jsonb snapshots keep the same number as before.
+type Money int64
+
+// Zero is 0.00
+const Zero Money = 0
+
+// FromFloat converts baht to satang, half is rounded away from zero
+func FromFloat(amount float64) Money {
+	return Money(math.Round(amount * 100))
+}
+
+// Parse reads a decimal such as "1250", "12.5" or "-0.75", digits after the 2nd are rounded half away from zero
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Zero, fmt.Errorf("amount is empty")
+	}
+	// exponent form is not sent by postgres or encoding/json, fall back to float for it
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Zero, fmt.Errorf("amount %s is invalid", s)
+		}
+		return FromFloat(f), nil
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return Zero, fmt.Errorf("amount is invalid")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return Zero, fmt.Errorf("amount %s is invalid", s)
+		}
+	}
+
+	baht, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Zero, fmt.Errorf("amount %s is invalid", s)
+	}
+
+	roundUp := len(fraction) > 2 && fraction[2] >= '5'
+	fraction = (fraction + "00")[:2]
+	satang, _ := strconv.ParseInt(fraction, 10, 64)
+
+	m := Money(baht*100 + satang)
+	if roundUp {
+		m++
+	}
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// Satang returns the amount in satang
+func (m Money) Satang() int64 {
+	return int64(m)
+}
+
+// Float is only for display and third party libraries, never calculate with it
+func (m Money) Float() float64 {
+	return float64(m) / 100
+}
+
+// String formats the amount as 1234.50
+func (m Money) String() string {
+	sign := ""
+	satang := int64(m)
+	if satang < 0 {
+		sign = "-"
+		satang = -satang
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, satang/100, satang%100)
+}
+
+// Mul multiplies the amount by qty
+func (m Money) Mul(qty int) Money {
+	return m * Money(qty)
+}
+
+// MulDiv is m * num / den rounded half away from zero, it is calculated with big integers so it never overflows
+func (m Money) MulDiv(num, den int64) Money {
+	if den == 0 {
+		return Zero
+	}
+	n := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(num))
+	d := big.NewInt(den)
+	if d.Sign() < 0 {
+		n.Neg(n)
+		d.Neg(d)
+	}
+
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	// |r| * 2 >= d means the fraction is half or more
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(d) >= 0 {
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Money(q.Int64())
+}
+
+// Percent is percent% of the amount, percent has 2 digits like money (7.00 = 7%)
+func (m Money) Percent(percent Money) Money {
+	return m.MulDiv(int64(percent), 10000)
+}
+
+// Share is m * part / total, it is used to split an amount by the weight of every line
+func (m Money) Share(part, total Money) Money {
+	return m.MulDiv(int64(part), int64(total))
+}
+
+// Min returns the smaller amount
+func Min(a, b Money) Money {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the bigger amount
+func Max(a, b Money) Money {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string, null is 0
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		*m = Zero
+		return nil
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value writes the amount as a decimal string, postgres casts it to NUMERIC exactly
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Zero
+	case int64:
+		*m = Money(v * 100)
+	case float64:
+		*m = FromFloat(v)
+	case []byte:
+		parsed, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into money", src)
+	}
+	return nil
+}
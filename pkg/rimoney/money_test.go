@@ -0,0 +1,140 @@
+package riMoney
+
+import "testing"
+
+type testParse struct {
+	input    string
+	isError  bool
+	expected Money
+}
+
+func TestParse(t *testing.T) {
+	tests := []testParse{
+		{input: "1250", expected: 125000},
+		{input: "12.5", expected: 1250},
+		{input: "12.50", expected: 1250},
+		{input: ".5", expected: 50},
+		{input: " 7 ", expected: 700},
+		{input: "+3.10", expected: 310},
+		{input: "-0.75", expected: -75},
+		{input: "-0", expected: 0},
+		// digits after the 2nd are rounded half away from zero
+		{input: "1.004", expected: 100},
+		{input: "1.005", expected: 101},
+		{input: "1.0049", expected: 100},
+		{input: "1.999", expected: 200},
+		{input: "-1.005", expected: -101},
+		{input: "-1.004", expected: -100},
+		{input: "1e2", expected: 10000},
+		{input: "", isError: true},
+		{input: "-", isError: true},
+		{input: ".", isError: true},
+		{input: "abc", isError: true},
+		{input: "1,000", isError: true},
+		{input: "1.2.3", isError: true},
+		{input: "--1", isError: true},
+		{input: "1 000", isError: true},
+		{input: "99999999999999999999", isError: true},
+	}
+
+	for _, test := range tests {
+		result, err := Parse(test.input)
+		if test.isError {
+			if err == nil {
+				t.Errorf("%q expected: error, got: %v", test.input, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q expected: %v, got: %v", test.input, nil, err.Error())
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("%q expected: %v, got: %v", test.input, test.expected, result)
+		}
+	}
+}
+
+type testMulDiv struct {
+	amount   Money
+	num      int64
+	den      int64
+	expected Money
+}
+
+func TestMulDiv(t *testing.T) {
+	tests := []testMulDiv{
+		{amount: 5, num: 1, den: 2, expected: 3},
+		{amount: -5, num: 1, den: 2, expected: -3},
+		{amount: 5, num: 1, den: -2, expected: -3},
+		{amount: -5, num: -1, den: 2, expected: 3},
+		{amount: 7, num: 1, den: 3, expected: 2},
+		{amount: 8, num: 1, den: 3, expected: 3},
+		{amount: -8, num: 1, den: 3, expected: -3},
+		{amount: 1000, num: 7, den: 107, expected: 65},
+		{amount: 100, num: 3, den: 3, expected: 100},
+		{amount: 100, num: 1, den: 0, expected: 0},
+		// the product is bigger than int64 but the result is not
+		{amount: 4000000000000000000, num: 4, den: 8, expected: 2000000000000000000},
+	}
+
+	for _, test := range tests {
+		if result := test.amount.MulDiv(test.num, test.den); result != test.expected {
+			t.Errorf("%d*%d/%d expected: %d, got: %d", test.amount, test.num, test.den, test.expected, result)
+		}
+	}
+}
+
+type testScanValue struct {
+	input    any
+	expected Money
+	value    string
+}
+
+// columns are NUMERIC(14, 2), the driver sends them back as text
+func TestScanValue(t *testing.T) {
+	tests := []testScanValue{
+		{input: "0.00", expected: 0, value: "0.00"},
+		{input: "1234.50", expected: 123450, value: "1234.50"},
+		{input: []byte("1234.50"), expected: 123450, value: "1234.50"},
+		{input: "-0.75", expected: -75, value: "-0.75"},
+		{input: "-12.05", expected: -1205, value: "-12.05"},
+		{input: "999999999999.99", expected: 99999999999999, value: "999999999999.99"},
+		{input: int64(5), expected: 500, value: "5.00"},
+		{input: float64(12.5), expected: 1250, value: "12.50"},
+		{input: nil, expected: 0, value: "0.00"},
+	}
+
+	for _, test := range tests {
+		var m Money
+		if err := m.Scan(test.input); err != nil {
+			t.Errorf("%v expected: %v, got: %v", test.input, nil, err.Error())
+			continue
+		}
+		if m != test.expected {
+			t.Errorf("%v expected: %d, got: %d", test.input, test.expected, m)
+		}
+
+		value, err := m.Value()
+		if err != nil {
+			t.Errorf("%v expected: %v, got: %v", test.input, nil, err.Error())
+			continue
+		}
+		if value != test.value {
+			t.Errorf("%v expected: %v, got: %v", test.input, test.value, value)
+		}
+
+		// the written value is read back as the same amount
+		var back Money
+		if err := back.Scan(value); err != nil || back != m {
+			t.Errorf("%v expected: %d, got: %d (%v)", value, m, back, err)
+		}
+	}
+
+	var m Money
+	for _, input := range []any{"abc", []byte("1.2.3"), true} {
+		if err := m.Scan(input); err == nil {
+			t.Errorf("%v expected: error, got: %v", input, m)
+		}
+	}
+}
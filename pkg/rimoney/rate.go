@@ -0,0 +1,118 @@
+package riMoney
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RateScale is how many units of Rate make 1, rates keep 8 digits like NUMERIC(20, 8)
+const RateScale = 100000000
+
+// Rate is an exchange rate with 8 digits, it is kept as an integer so converting money stays exact
+type Rate int64
+
+// ParseRate reads a decimal such as "36.25" or "0.2412", digits after the 8th are cut
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("rate %s is invalid", s)
+		}
+		s = strconv.FormatFloat(f, 'f', 8, 64)
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if len(fraction) > 8 {
+		fraction = fraction[:8]
+	}
+	fraction = (fraction + "00000000")[:8]
+
+	w, err := strconv.ParseUint(whole, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("rate %s is invalid", s)
+	}
+	f, err := strconv.ParseUint(fraction, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("rate %s is invalid", s)
+	}
+	return Rate(w*RateScale + f), nil
+}
+
+// String formats the rate with 8 digits, trailing zeros are removed
+func (r Rate) String() string {
+	s := fmt.Sprintf("%d.%08d", int64(r)/RateScale, int64(r)%RateScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert changes baht to the currency of the rate (baht of 1 unit), the result is rounded half away
+// from zero to decimals digits (0 to 2) so it can be shown as it is
+func (m Money) Convert(rate Rate, decimals int) Money {
+	if rate <= 0 {
+		return Zero
+	}
+	step := int64(1)
+	for i := decimals; i < 2; i++ {
+		step *= 10
+	}
+	return m.MulDiv(RateScale, int64(rate)*step) * Money(step)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string, null is 0
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		*r = 0
+		return nil
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func (r *Rate) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r = 0
+	case int64:
+		*r = Rate(v * RateScale)
+	case float64:
+		parsed, err := ParseRate(strconv.FormatFloat(v, 'f', 8, 64))
+		if err != nil {
+			return err
+		}
+		*r = parsed
+	case []byte:
+		parsed, err := ParseRate(string(v))
+		if err != nil {
+			return err
+		}
+		*r = parsed
+	case string:
+		parsed, err := ParseRate(v)
+		if err != nil {
+			return err
+		}
+		*r = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into rate", src)
+	}
+	return nil
+}
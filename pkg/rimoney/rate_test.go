@@ -0,0 +1,123 @@
+package riMoney
+
+import "testing"
+
+type testParseRate struct {
+	input    string
+	isError  bool
+	expected Rate
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []testParseRate{
+		{input: "36.25", expected: 3625000000},
+		{input: "0.2412", expected: 24120000},
+		{input: "1", expected: RateScale},
+		{input: ".5", expected: 50000000},
+		{input: "35.12345678", expected: 3512345678},
+		// digits after the 8th are cut
+		{input: "35.123456789", expected: 3512345678},
+		{input: "1e-3", expected: 100000},
+		{input: "abc", isError: true},
+		{input: "-1", isError: true},
+		{input: "1.2.3", isError: true},
+	}
+
+	for _, test := range tests {
+		result, err := ParseRate(test.input)
+		if test.isError {
+			if err == nil {
+				t.Errorf("%q expected: error, got: %v", test.input, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q expected: %v, got: %v", test.input, nil, err.Error())
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("%q expected: %d, got: %d", test.input, test.expected, result)
+		}
+	}
+}
+
+type testConvert struct {
+	amount   Money
+	rate     string
+	decimals int
+	expected Money
+}
+
+func TestConvert(t *testing.T) {
+	tests := []testConvert{
+		// 1000 / 36.25 = 27.586...
+		{amount: 100000, rate: "36.25", decimals: 2, expected: 2759},
+		// 1000 / 35.12345678 = 28.4709...
+		{amount: 100000, rate: "35.12345678", decimals: 2, expected: 2847},
+		// 1 / 0.00012345 = 8100.4455...
+		{amount: 100, rate: "0.00012345", decimals: 2, expected: 810045},
+		{amount: 100, rate: "0.00012345", decimals: 1, expected: 810040},
+		{amount: 100, rate: "0.00012345", decimals: 0, expected: 810000},
+		// 4146 / 0.2412 = 17189.054...
+		{amount: 414600, rate: "0.2412", decimals: 0, expected: 1718900},
+		// 0.05 / 2 = 0.025 is rounded half away from zero
+		{amount: 5, rate: "2", decimals: 2, expected: 3},
+		{amount: -5, rate: "2", decimals: 2, expected: -3},
+		{amount: 100000, rate: "0", decimals: 2, expected: 0},
+	}
+
+	for _, test := range tests {
+		rate, err := ParseRate(test.rate)
+		if err != nil {
+			t.Errorf("%q expected: %v, got: %v", test.rate, nil, err.Error())
+			continue
+		}
+		if result := test.amount.Convert(rate, test.decimals); result != test.expected {
+			t.Errorf("%v at %v (%d) expected: %v, got: %v", test.amount, test.rate, test.decimals, test.expected, result)
+		}
+	}
+}
+
+type testRateScanValue struct {
+	input    any
+	expected Rate
+	value    string
+}
+
+// rates are NUMERIC(20, 8), trailing zeros are not written back
+func TestRateScanValue(t *testing.T) {
+	tests := []testRateScanValue{
+		{input: "36.25000000", expected: 3625000000, value: "36.25"},
+		{input: []byte("0.00012345"), expected: 12345, value: "0.00012345"},
+		{input: "35.12345678", expected: 3512345678, value: "35.12345678"},
+		{input: "1.00000000", expected: RateScale, value: "1"},
+		{input: int64(2), expected: 2 * RateScale, value: "2"},
+		{input: float64(0.2412), expected: 24120000, value: "0.2412"},
+		{input: nil, expected: 0, value: "0"},
+	}
+
+	for _, test := range tests {
+		var r Rate
+		if err := r.Scan(test.input); err != nil {
+			t.Errorf("%v expected: %v, got: %v", test.input, nil, err.Error())
+			continue
+		}
+		if r != test.expected {
+			t.Errorf("%v expected: %d, got: %d", test.input, test.expected, r)
+		}
+
+		value, err := r.Value()
+		if err != nil {
+			t.Errorf("%v expected: %v, got: %v", test.input, nil, err.Error())
+			continue
+		}
+		if value != test.value {
+			t.Errorf("%v expected: %v, got: %v", test.input, test.value, value)
+		}
+
+		var back Rate
+		if err := back.Scan(value); err != nil || back != r {
+			t.Errorf("%v expected: %d, got: %d (%v)", value, r, back, err)
+		}
+	}
+}
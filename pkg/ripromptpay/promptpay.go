@@ -0,0 +1,82 @@
+package riPromptPay
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+const (
+	idPayloadFormat      = "00"
+	idPointOfInitiation  = "01"
+	idMerchantAccount    = "29"
+	idCountryCode        = "58"
+	idCurrency           = "53"
+	idAmount             = "54"
+	idChecksum           = "63"
+	merchantAID          = "A000000677010111"
+	merchantMobileNumber = "01"
+	merchantTaxId        = "02"
+	merchantEWalletId    = "03"
+)
+
+var nonDigit = regexp.MustCompile(`[^0-9]`)
+
+// Payload builds the EMVCo PromptPay payload, id is a mobile number, tax id (13 digits) or e-wallet id (15 digits).
+// The payload is dynamic (usable once) when amount is more than 0.
+func Payload(id string, amount riMoney.Money) (string, error) {
+	id = nonDigit.ReplaceAllString(id, "")
+
+	var account string
+	switch {
+	case len(id) == 15:
+		account = field(merchantEWalletId, id)
+	case len(id) == 13:
+		account = field(merchantTaxId, id)
+	case len(id) == 10 && strings.HasPrefix(id, "0"):
+		// 0812345678 -> 0066812345678
+		account = field(merchantMobileNumber, fmt.Sprintf("0066%s", id[1:]))
+	default:
+		return "", fmt.Errorf("promptpay id is invalid")
+	}
+
+	initiation := "11"
+	if amount > 0 {
+		initiation = "12"
+	}
+
+	payload := field(idPayloadFormat, "01") +
+		field(idPointOfInitiation, initiation) +
+		field(idMerchantAccount, field("00", merchantAID)+account) +
+		field(idCountryCode, "TH") +
+		field(idCurrency, "764")
+	if amount > 0 {
+		payload += field(idAmount, amount.String())
+	}
+
+	// checksum covers its own id and length
+	payload += idChecksum + "04"
+	return payload + fmt.Sprintf("%04X", crc16(payload)), nil
+}
+
+func field(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// crc16 is CRC-16/CCITT-FALSE
+func crc16(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,89 @@
+package riPromptPay
+
+import (
+	"testing"
+
+	riMoney "github.com/NatthawutSK/ri-shop/pkg/rimoney"
+)
+
+type testPayload struct {
+	id       string
+	amount   riMoney.Money
+	isError  bool
+	expected string
+}
+
+func TestPayload(t *testing.T) {
+	tests := []testPayload{
+		// mobile number, static payload
+		{
+			id:       "0801234567",
+			expected: "00020101021129370016A000000677010111011300668012345675802TH530376463046197",
+		},
+		// the mobile number is normalised to 0066 and digits only
+		{
+			id:       "080-123-4567",
+			expected: "00020101021129370016A000000677010111011300668012345675802TH530376463046197",
+		},
+		{
+			id:       "1111111111111",
+			expected: "00020101021129370016A000000677010111021311111111111115802TH530376463047B5A",
+		},
+		// tax id is normalised to digits only
+		{
+			id:       "1-1111-11111-11-1",
+			expected: "00020101021129370016A000000677010111021311111111111115802TH530376463047B5A",
+		},
+		{
+			id:       "012345678901234",
+			expected: "00020101021129390016A00000067701011103150123456789012345802TH530376463049781",
+		},
+		// amount makes the payload dynamic (12) and adds tag 54
+		{
+			id:       "000-000-0000",
+			amount:   422,
+			expected: "00020101021229370016A000000677010111011300660000000005802TH530376454044.226304E469",
+		},
+		{id: "812345678", isError: true},
+		{id: "1812345678", isError: true},
+		{id: "12345678901234", isError: true},
+		{id: "", isError: true},
+	}
+
+	for _, test := range tests {
+		result, err := Payload(test.id, test.amount)
+		if test.isError {
+			if err == nil {
+				t.Errorf("%q expected: error, got: %v", test.id, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q expected: %v, got: %v", test.id, nil, err.Error())
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("%q expected: %v, got: %v", test.id, test.expected, result)
+		}
+	}
+}
+
+type testCrc16 struct {
+	input    string
+	expected uint16
+}
+
+func TestCrc16(t *testing.T) {
+	tests := []testCrc16{
+		// check value of CRC-16/CCITT-FALSE
+		{input: "123456789", expected: 0x29B1},
+		{input: "", expected: 0xFFFF},
+		{input: "A", expected: 0xB915},
+	}
+
+	for _, test := range tests {
+		if result := crc16(test.input); result != test.expected {
+			t.Errorf("%q expected: %04X, got: %04X", test.input, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package riScheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Job is run every interval, Run gets a context which is canceled when the scheduler stops
+// or the run takes longer than the interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type IScheduler interface {
+	Add(job *Job)
+	Start()
+	Stop()
+}
+
+type scheduler struct {
+	db     *sqlx.DB
+	jobs   []*Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+func NewScheduler(db *sqlx.DB) IScheduler {
+	return &scheduler{
+		db:   db,
+		jobs: make([]*Job, 0),
+	}
+}
+
+// Add must be called before Start
+func (s *scheduler) Add(job *Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+func (s *scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+	log.Printf("scheduler is running %d jobs", len(s.jobs))
+}
+
+// Stop waits until the running jobs return
+func (s *scheduler) Stop() {
+	s.once.Do(func() {
+		if s.cancel == nil {
+			return
+		}
+		s.cancel()
+		s.wg.Wait()
+		log.Println("scheduler is stopped")
+	})
+}
+
+func (s *scheduler) loop(ctx context.Context, job *Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(ctx, job)
+		}
+	}
+}
+
+// run takes a postgres advisory lock of the job first, so only one instance of the api runs the job at a time.
+// The lock belongs to the connection, so the same connection is used to unlock.
+func (s *scheduler) run(ctx context.Context, job *Job) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		log.Printf("job %s: get connection failed: %v\n", job.Name, err)
+		return
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowxContext(ctx, `SELECT pg_try_advisory_lock(hashtext('job:' || $1));`, job.Name).Scan(&locked); err != nil {
+		log.Printf("job %s: lock failed: %v\n", job.Name, err)
+		return
+	}
+	// another instance is running this job
+	if !locked {
+		return
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext('job:' || $1));`, job.Name); err != nil {
+			log.Printf("job %s: unlock failed: %v\n", job.Name, err)
+		}
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, job.Interval)
+	defer cancel()
+
+	if err := job.Run(runCtx); err != nil {
+		log.Printf("job %s failed: %v\n", job.Name, err)
+	}
+}